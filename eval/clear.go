@@ -0,0 +1,56 @@
+// This file contains the CLEAR MOT metrics, where the boxes of each frame are matched separately,
+// favoring the track each annotated object was matched with on the previous frames.
+
+package eval
+
+// continuityBonus is added to the score of each annotated object and the track it was last matched
+// with, so that they stay matched whenever their IOU is above MatchThreshold.
+const continuityBonus = 1000
+
+// clear computes TP, FP, FN, IDSwitches, Fragmentations, MOTA and MOTP.
+func (m *Metrics) clear(frames []frame) {
+	// the track each annotated object was last matched with, whether it was matched on the last
+	// frame it was annotated on, and whether it was ever matched
+	lastTrack := make(map[int]int)
+	lastMatched := make(map[int]bool)
+	everMatched := make(map[int]bool)
+	var iouSum float64
+	for _, f := range frames {
+		score := make([][]float64, len(f.gt))
+		for i, g := range f.gt {
+			score[i] = make([]float64, len(f.tracked))
+			for j, t := range f.tracked {
+				if f.iou[i][j] < MatchThreshold-epsilon {
+					continue
+				}
+				score[i][j] = f.iou[i][j]
+				if last, ok := lastTrack[g]; ok && last == t {
+					score[i][j] += continuityBonus
+				}
+			}
+		}
+		matched := make(map[int]bool)
+		for _, p := range assign(score) {
+			g, t := f.gt[p[0]], f.tracked[p[1]]
+			m.TP++
+			iouSum += f.iou[p[0]][p[1]]
+			if last, ok := lastTrack[g]; ok && last != t {
+				m.IDSwitches++
+			}
+			lastTrack[g] = t
+			matched[g] = true
+		}
+		for _, g := range f.gt {
+			// the first match of an object is not a fragmentation
+			if matched[g] && !lastMatched[g] && everMatched[g] {
+				m.Fragmentations++
+			}
+			lastMatched[g] = matched[g]
+			everMatched[g] = everMatched[g] || matched[g]
+		}
+	}
+	m.FP = m.Tracked - m.TP
+	m.FN = m.GroundTruth - m.TP
+	m.MOTA = 1 - ratio(float64(m.FN+m.FP+m.IDSwitches), float64(m.GroundTruth))
+	m.MOTP = ratio(iouSum, float64(m.TP))
+}
@@ -0,0 +1,18 @@
+// Package eval scores the output of a tracker against ground-truth annotations, in the
+// MOTChallenge format, with the usual multi-object tracking metrics:
+//
+//   - the CLEAR MOT metrics: MOTA, MOTP, ID switches and fragmentations
+//   - the identity metrics: IDF1
+//   - HOTA, along with its detection, association and localization components
+//
+// Ground truth and tracks are read with ReadGroundTruth and ReadMOT, and scored with Evaluate:
+//
+//	gt, err := eval.ReadGroundTruth(gtFile)
+//	...
+//	tracks, err := eval.ReadMOT(tracksFile)
+//	...
+//	fmt.Println(eval.Evaluate(gt, tracks).MOTA)
+//
+// The metrics follow the definitions of the TrackEval reference implementation, without its
+// handling of distractor classes.
+package eval
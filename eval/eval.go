@@ -0,0 +1,147 @@
+// This file contains the evaluation of a sequence, which gathers the metrics computed in the other
+// files.
+
+package eval
+
+import (
+	"slices"
+
+	"github.com/viam-modules/object-tracking/tracking"
+)
+
+const (
+	// MatchThreshold is the IOU from which a track and an annotated object can be matched, for the
+	// CLEAR MOT and identity metrics.
+	MatchThreshold = 0.5
+	epsilon        = 1e-10
+)
+
+// Metrics are the tracking metrics of a sequence. Counts are in boxes, over every frame.
+type Metrics struct {
+	Frames int
+	// GroundTruth and Tracked are the number of annotated boxes and of boxes given by the tracker
+	GroundTruth int
+	Tracked     int
+
+	// TP, FP and FN are the boxes matched, the tracker boxes not matched and the annotated boxes
+	// not matched, with the CLEAR MOT matching
+	TP int
+	FP int
+	FN int
+	// IDSwitches is the number of times an annotated object is matched with a different track than
+	// the last time it was matched
+	IDSwitches int
+	// Fragmentations is the number of times an annotated object is matched again after it was
+	// missed
+	Fragmentations int
+	// MOTA is 1 minus the ratio of misses, false positives and ID switches to annotated boxes
+	MOTA float64
+	// MOTP is the average IOU of the matched boxes
+	MOTP float64
+
+	// IDTP, IDFP and IDFN are the boxes matched, the tracker boxes not matched and the annotated
+	// boxes not matched, when each annotated object is matched with at most one track for the
+	// whole sequence
+	IDTP int
+	IDFP int
+	IDFN int
+	// IDF1 is the F1 score of the identity matching
+	IDF1 float64
+
+	// HOTA is the geometric mean of DetA, the detection accuracy, and AssA, the association
+	// accuracy. LocA is the average IOU of the matched boxes. All four are averaged over IOU
+	// thresholds from 0.05 to 0.95.
+	HOTA float64
+	DetA float64
+	AssA float64
+	LocA float64
+}
+
+// frame holds the objects of both sequences on a frame, by index in idIndex, and the IOU of every
+// pair of them.
+type frame struct {
+	gt, tracked []int
+	iou         [][]float64
+}
+
+// idIndex gives indices from 0 to the IDs of a sequence, in the order they first appear.
+type idIndex map[int]int
+
+func (ids idIndex) index(id int) int {
+	i, ok := ids[id]
+	if !ok {
+		i = len(ids)
+		ids[id] = i
+	}
+	return i
+}
+
+// Evaluate scores the tracks against the ground truth, over every frame either of them has objects
+// on.
+func Evaluate(gt, tracks Sequence) Metrics {
+	seen := make(map[int]bool)
+	var numbers []int
+	for _, f := range append(gt.Frames(), tracks.Frames()...) {
+		if !seen[f] {
+			seen[f] = true
+			numbers = append(numbers, f)
+		}
+	}
+	slices.Sort(numbers)
+
+	gtIDs, trackIDs := make(idIndex), make(idIndex)
+	frames := make([]frame, len(numbers))
+	var m Metrics
+	for k, n := range numbers {
+		f := &frames[k]
+		f.iou = make([][]float64, len(gt[n]))
+		for i, g := range gt[n] {
+			f.gt = append(f.gt, gtIDs.index(g.ID))
+			f.iou[i] = make([]float64, len(tracks[n]))
+			for j, t := range tracks[n] {
+				f.iou[i][j] = IOU(g, t)
+			}
+		}
+		for _, t := range tracks[n] {
+			f.tracked = append(f.tracked, trackIDs.index(t.ID))
+		}
+		m.GroundTruth += len(f.gt)
+		m.Tracked += len(f.tracked)
+	}
+	m.Frames = len(frames)
+
+	m.clear(frames)
+	m.identity(frames, len(gtIDs), len(trackIDs))
+	m.hota(frames, len(gtIDs), len(trackIDs))
+	return m
+}
+
+// assign matches the objects of a frame to maximize the total score, and returns the pairs whose
+// score is positive.
+func assign(score [][]float64) [][2]int {
+	if len(score) == 0 || len(score[0]) == 0 {
+		return nil
+	}
+	cost := make([][]float64, len(score))
+	for i, row := range score {
+		cost[i] = make([]float64, len(row))
+		for j, s := range row {
+			cost[i][j] = -s
+		}
+	}
+	var pairs [][2]int
+	for i, j := range tracking.SolveAssignment(cost) {
+		if j >= 0 && score[i][j] > 0 {
+			pairs = append(pairs, [2]int{i, j})
+		}
+	}
+	return pairs
+}
+
+// ratio returns a / b, or 0 if b is 0.
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
@@ -0,0 +1,96 @@
+package eval
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+// walker returns the box of an object walking right by 5 pixels a frame.
+func walker(id, frame int) Object {
+	return Object{ID: id, Left: float64(10 + 5*frame), Top: 20, Width: 30, Height: 60}
+}
+
+func TestEvaluatePerfect(t *testing.T) {
+	gt := make(Sequence)
+	for f := 1; f <= 10; f++ {
+		gt[f] = []Object{walker(1, f), {ID: 2, Left: 200, Top: 20, Width: 30, Height: 60}}
+	}
+	m := Evaluate(gt, gt)
+	test.That(t, m.Frames, test.ShouldEqual, 10)
+	test.That(t, m.TP, test.ShouldEqual, 20)
+	test.That(t, m.FP+m.FN+m.IDSwitches+m.Fragmentations, test.ShouldEqual, 0)
+	test.That(t, m.MOTA, test.ShouldEqual, 1)
+	test.That(t, m.MOTP, test.ShouldEqual, 1)
+	test.That(t, m.IDF1, test.ShouldEqual, 1)
+	test.That(t, m.HOTA, test.ShouldAlmostEqual, 1)
+	test.That(t, m.LocA, test.ShouldAlmostEqual, 1)
+}
+
+func TestEvaluateIDSwitch(t *testing.T) {
+	gt, tracks := make(Sequence), make(Sequence)
+	for f := 1; f <= 4; f++ {
+		gt[f] = []Object{walker(1, f)}
+		// the tracker loses the object half way and starts a new track
+		id := 7
+		if f > 2 {
+			id = 8
+		}
+		tracks[f] = []Object{walker(id, f)}
+	}
+	m := Evaluate(gt, tracks)
+	test.That(t, m.TP, test.ShouldEqual, 4)
+	test.That(t, m.IDSwitches, test.ShouldEqual, 1)
+	test.That(t, m.Fragmentations, test.ShouldEqual, 0)
+	test.That(t, m.MOTA, test.ShouldEqual, 0.75)
+	test.That(t, m.IDTP, test.ShouldEqual, 2)
+	test.That(t, m.IDF1, test.ShouldEqual, 0.5)
+	test.That(t, m.DetA, test.ShouldAlmostEqual, 1)
+	test.That(t, m.AssA, test.ShouldAlmostEqual, 0.5)
+	test.That(t, m.HOTA, test.ShouldAlmostEqual, math.Sqrt(0.5))
+}
+
+func TestEvaluateMissesAndFalsePositives(t *testing.T) {
+	gt, tracks := make(Sequence), make(Sequence)
+	for f := 1; f <= 5; f++ {
+		gt[f] = []Object{walker(1, f)}
+		// the object is missed on frame 3
+		if f != 3 {
+			tracks[f] = []Object{walker(1, f)}
+		}
+	}
+	// a box that barely overlaps the object is a false positive, and the object is missed
+	tracks[5] = []Object{{ID: 1, Left: walker(1, 5).Left + 20, Top: 20, Width: 30, Height: 60}}
+	m := Evaluate(gt, tracks)
+	test.That(t, m.GroundTruth, test.ShouldEqual, 5)
+	test.That(t, m.Tracked, test.ShouldEqual, 4)
+	test.That(t, m.TP, test.ShouldEqual, 3)
+	test.That(t, m.FN, test.ShouldEqual, 2)
+	test.That(t, m.FP, test.ShouldEqual, 1)
+	test.That(t, m.Fragmentations, test.ShouldEqual, 1)
+	test.That(t, m.MOTA, test.ShouldAlmostEqual, 0.4)
+	test.That(t, m.IDF1, test.ShouldAlmostEqual, 6.0/9)
+	// the box still counts for HOTA at low thresholds
+	test.That(t, m.HOTA, test.ShouldBeGreaterThan, 0)
+	test.That(t, m.HOTA, test.ShouldBeLessThan, 1)
+
+	test.That(t, Evaluate(gt, nil).MOTA, test.ShouldEqual, 0)
+	test.That(t, Evaluate(nil, nil).Frames, test.ShouldEqual, 0)
+}
+
+func TestReadMOT(t *testing.T) {
+	content := "1,1,10.5,20,30,60,1,1,1\n1,2,100,20,30,60,0,1,1\n3,1,12,20,30,60,1,1,1\n"
+	gt, err := ReadGroundTruth(strings.NewReader(content))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, gt.Frames(), test.ShouldResemble, []int{1, 3})
+	test.That(t, gt[1], test.ShouldResemble, []Object{{ID: 1, Left: 10.5, Top: 20, Width: 30, Height: 60}})
+
+	tracks, err := ReadMOT(strings.NewReader(content))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(tracks[1]), test.ShouldEqual, 2)
+
+	_, err = ReadMOT(strings.NewReader("1,1,10,20\n"))
+	test.That(t, err, test.ShouldNotBeNil)
+}
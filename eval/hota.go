@@ -0,0 +1,108 @@
+// This file contains the HOTA metrics, which score detection and association separately at each
+// of a range of IOU thresholds.
+
+package eval
+
+import (
+	"math"
+)
+
+// hotaThresholds is the number of IOU thresholds, from 0.05 to 0.95 by 0.05.
+const hotaThresholds = 19
+
+// hota computes HOTA, DetA, AssA and LocA.
+func (m *Metrics) hota(frames []frame, numGT, numTracks int) {
+	// how well each annotated object and each track are aligned over the whole sequence, from the
+	// IOU of their boxes normalized by the other boxes they overlap
+	potential := newMatrix(numGT, numTracks)
+	gtCount := make([]float64, numGT)
+	trackCount := make([]float64, numTracks)
+	for _, f := range frames {
+		rowSum := make([]float64, len(f.gt))
+		colSum := make([]float64, len(f.tracked))
+		for i := range f.gt {
+			for j := range f.tracked {
+				rowSum[i] += f.iou[i][j]
+				colSum[j] += f.iou[i][j]
+			}
+		}
+		for i, g := range f.gt {
+			for j, t := range f.tracked {
+				if denom := rowSum[i] + colSum[j] - f.iou[i][j]; denom > epsilon {
+					potential[g][t] += f.iou[i][j] / denom
+				}
+			}
+			gtCount[g]++
+		}
+		for _, t := range f.tracked {
+			trackCount[t]++
+		}
+	}
+	alignment := newMatrix(numGT, numTracks)
+	for g := range alignment {
+		for t := range alignment[g] {
+			alignment[g][t] = ratio(potential[g][t], gtCount[g]+trackCount[t]-potential[g][t])
+		}
+	}
+
+	// the boxes of each frame are matched once, by alignment and IOU, and each match counts at
+	// the thresholds its IOU reaches
+	var tp, loc [hotaThresholds]float64
+	var matches [hotaThresholds][][]float64
+	for a := range matches {
+		matches[a] = newMatrix(numGT, numTracks)
+	}
+	for _, f := range frames {
+		score := make([][]float64, len(f.gt))
+		for i, g := range f.gt {
+			score[i] = make([]float64, len(f.tracked))
+			for j, t := range f.tracked {
+				score[i][j] = alignment[g][t] * f.iou[i][j]
+			}
+		}
+		for _, p := range assign(score) {
+			iou := f.iou[p[0]][p[1]]
+			g, t := f.gt[p[0]], f.tracked[p[1]]
+			for a := range hotaThresholds {
+				if iou >= hotaThreshold(a)-epsilon {
+					tp[a]++
+					loc[a] += iou
+					matches[a][g][t]++
+				}
+			}
+		}
+	}
+
+	for a := range hotaThresholds {
+		fn, fp := float64(m.GroundTruth)-tp[a], float64(m.Tracked)-tp[a]
+		detA := ratio(tp[a], tp[a]+fn+fp)
+		// each match is weighted by how well its pair of annotated object and track are associated
+		// over the whole sequence
+		var assSum float64
+		for g, row := range matches[a] {
+			for t, n := range row {
+				if n > 0 {
+					assSum += n * n / (gtCount[g] + trackCount[t] - n)
+				}
+			}
+		}
+		assA := ratio(assSum, tp[a])
+		m.DetA += detA / hotaThresholds
+		m.AssA += assA / hotaThresholds
+		m.HOTA += math.Sqrt(detA*assA) / hotaThresholds
+		m.LocA += ratio(loc[a], tp[a]) / hotaThresholds
+	}
+}
+
+// hotaThreshold returns the a-th IOU threshold of HOTA.
+func hotaThreshold(a int) float64 {
+	return 0.05 * float64(a+1)
+}
+
+func newMatrix(rows, cols int) [][]float64 {
+	out := make([][]float64, rows)
+	for i := range out {
+		out[i] = make([]float64, cols)
+	}
+	return out
+}
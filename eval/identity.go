@@ -0,0 +1,25 @@
+// This file contains the identity metrics, where each annotated object is matched with at most one
+// track for the whole sequence.
+
+package eval
+
+// identity computes IDTP, IDFP, IDFN and IDF1.
+func (m *Metrics) identity(frames []frame, numGT, numTracks int) {
+	// the number of frames each pair of an annotated object and a track could be matched on
+	overlaps := newMatrix(numGT, numTracks)
+	for _, f := range frames {
+		for i, g := range f.gt {
+			for j, t := range f.tracked {
+				if f.iou[i][j] >= MatchThreshold-epsilon {
+					overlaps[g][t]++
+				}
+			}
+		}
+	}
+	for _, p := range assign(overlaps) {
+		m.IDTP += int(overlaps[p[0]][p[1]])
+	}
+	m.IDFP = m.Tracked - m.IDTP
+	m.IDFN = m.GroundTruth - m.IDTP
+	m.IDF1 = ratio(float64(2*m.IDTP), float64(m.GroundTruth+m.Tracked))
+}
@@ -0,0 +1,86 @@
+// This file contains the reading of sequences in the MOTChallenge CSV format.
+
+package eval
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Object is the box of a track, or of an annotated object, on a frame.
+type Object struct {
+	ID                       int
+	Left, Top, Width, Height float64
+}
+
+// IOU returns the intersection over union of the boxes of 2 objects.
+func IOU(a, b Object) float64 {
+	w := min(a.Left+a.Width, b.Left+b.Width) - max(a.Left, b.Left)
+	h := min(a.Top+a.Height, b.Top+b.Height) - max(a.Top, b.Top)
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	inter := w * h
+	return inter / (a.Width*a.Height + b.Width*b.Height - inter)
+}
+
+// Sequence holds the objects of each frame, by frame number.
+type Sequence map[int][]Object
+
+// Frames returns the numbers of the frames with objects, in order.
+func (s Sequence) Frames() []int {
+	frames := make([]int, 0, len(s))
+	for f, objs := range s {
+		if len(objs) > 0 {
+			frames = append(frames, f)
+		}
+	}
+	slices.Sort(frames)
+	return frames
+}
+
+// ReadMOT reads tracks in the MOTChallenge CSV format: frame, id, left, top, width and height,
+// followed by columns that are not used.
+func ReadMOT(r io.Reader) (Sequence, error) {
+	return readMOT(r, false)
+}
+
+// ReadGroundTruth reads annotations in the MOTChallenge CSV format. Unlike ReadMOT, the rows
+// whose 7th column is 0, which MOTChallenge uses to mark objects that are not scored, are left out.
+func ReadGroundTruth(r io.Reader) (Sequence, error) {
+	return readMOT(r, true)
+}
+
+func readMOT(r io.Reader, groundTruth bool) (Sequence, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read MOT file")
+	}
+	out := make(Sequence)
+	for i, rec := range records {
+		if len(rec) < 6 {
+			return nil, errors.Errorf("line %d of MOT file must have at least 6 columns", i+1)
+		}
+		if groundTruth && len(rec) > 6 && rec[6] == "0" {
+			continue
+		}
+		var values [6]float64
+		for k := range values {
+			if values[k], err = strconv.ParseFloat(rec[k], 64); err != nil {
+				return nil, errors.Wrapf(err, "line %d of MOT file", i+1)
+			}
+		}
+		frame := int(values[0])
+		out[frame] = append(out[frame], Object{
+			ID: int(values[1]), Left: values[2], Top: values[3], Width: values[4], Height: values[5],
+		})
+	}
+	return out, nil
+}
@@ -2,14 +2,330 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"text/tabwriter"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/services/vision"
 
 	"go.viam.com/rdk/module"
 
+	"github.com/viam-modules/object-tracking/eval"
 	"github.com/viam-modules/object-tracking/object_tracker"
 )
 
 func main() {
-	module.ModularMain(resource.APIModel{API: vision.API, Model: object_tracker.Model})
+	// the module is started with a socket path, so subcommands do not clash with it
+	var command func([]string) error
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "track":
+			command = track
+		case "replay":
+			command = replay
+		case "eval":
+			command = evaluate
+		case "tune":
+			command = tune
+		}
+	}
+	if command == nil {
+		module.ModularMain(
+			resource.APIModel{API: vision.API, Model: object_tracker.Model},
+			resource.APIModel{API: camera.API, Model: object_tracker.OverlayModel},
+		)
+		return
+	}
+	if err := command(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// track runs the tracker offline on recorded footage with precomputed detections.
+func track(args []string) error {
+	flags := flag.NewFlagSet("track", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: module track -input <images dir or .mjpeg> -detections <.jsonl or MOT det.txt> [options]")
+		flags.PrintDefaults()
+	}
+	input := flags.String("input", "", "directory of JPEG and PNG images, read in name order, or MJPEG file")
+	detections := flags.String("detections", "", "precomputed detections: JSONL with one {\"frame\": N, \"detections\": [...]} per frame, or MOTChallenge det.txt")
+	motClass := flags.String("mot-class", object_tracker.DefaultMOTClass, "class of the detections of a MOTChallenge file")
+	configPath := flags.String("config", "", "JSON file with the attributes of the tracker")
+	motPath := flags.String("mot", "", "output file for the tracks in MOTChallenge CSV format, - for stdout")
+	jsonlPath := flags.String("jsonl", "", "output file for the tracks in JSONL format, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *input == "" || *detections == "" {
+		flags.Usage()
+		return fmt.Errorf("-input and -detections are required")
+	}
+
+	opts := object_tracker.OfflineOptions{Input: *input, Detections: *detections, MOTClass: *motClass}
+	if err := readConfig(*configPath, &opts.Config); err != nil {
+		return err
+	}
+	var err error
+	if opts.MOTOutput, err = openOutput(*motPath); err != nil {
+		return err
+	}
+	if opts.JSONLOutput, err = openOutput(*jsonlPath); err != nil {
+		return err
+	}
+
+	logger := logging.NewLogger("object-tracker")
+	frames, err := object_tracker.RunOffline(context.Background(), opts, logger)
+	if err := closeOutputs(err, opts.MOTOutput, opts.JSONLOutput); err != nil {
+		return err
+	}
+	logger.Infof("tracked %d frames", frames)
+	return nil
+}
+
+// replay runs the tracker on a recording of its input.
+func replay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: module replay -recording <recording file or dir> [options]")
+		flags.PrintDefaults()
+	}
+	recording := flags.String("recording", "", "recording file, or directory of recording files replayed oldest first")
+	session := flags.String("session", "", "session to replay, the default session if empty")
+	configPath := flags.String("config", "", "JSON file with the attributes of the tracker")
+	motPath := flags.String("mot", "", "output file for the tracks in MOTChallenge CSV format, - for stdout")
+	jsonlPath := flags.String("jsonl", "", "output file for the tracks in JSONL format, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *recording == "" {
+		flags.Usage()
+		return fmt.Errorf("-recording is required")
+	}
+
+	opts := object_tracker.ReplayOptions{Recording: *recording, Session: *session}
+	if err := readConfig(*configPath, &opts.Config); err != nil {
+		return err
+	}
+	var err error
+	if opts.MOTOutput, err = openOutput(*motPath); err != nil {
+		return err
+	}
+	if opts.JSONLOutput, err = openOutput(*jsonlPath); err != nil {
+		return err
+	}
+
+	logger := logging.NewLogger("object-tracker")
+	frames, err := object_tracker.Replay(context.Background(), opts, logger)
+	if err := closeOutputs(err, opts.MOTOutput, opts.JSONLOutput); err != nil {
+		return err
+	}
+	logger.Infof("replayed %d frames", frames)
+	return nil
+}
+
+// readConfig reads the attributes of the tracker from a JSON file, if there is one.
+func readConfig(path string, cfg *object_tracker.Config) error {
+	if path == "" {
+		return nil
+	}
+	return readJSON(path, cfg)
+}
+
+// readJSON decodes a JSON file into v.
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid %v: %w", path, err)
+	}
+	return nil
+}
+
+// closeOutputs closes the output files, and returns err or the first error closing them.
+func closeOutputs(err error, outputs ...io.Writer) error {
+	for _, w := range outputs {
+		if f, ok := w.(*os.File); ok && f != os.Stdout {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+	return err
+}
+
+// openOutput returns the writer of an output path, or nil if there is none.
+func openOutput(path string) (io.Writer, error) {
+	switch path {
+	case "":
+		return nil, nil
+	case "-":
+		return os.Stdout, nil
+	default:
+		return os.Create(path)
+	}
+}
+
+// evaluate scores tracks against ground-truth annotations, both in the MOTChallenge format.
+func evaluate(args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: module eval -gt <gt.txt> -tracks <tracks.txt>")
+		flags.PrintDefaults()
+	}
+	gtPath := flags.String("gt", "", "ground-truth annotations in MOTChallenge CSV format")
+	tracksPath := flags.String("tracks", "", "tracks in MOTChallenge CSV format, such as the -mot output of track")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *gtPath == "" || *tracksPath == "" {
+		flags.Usage()
+		return fmt.Errorf("-gt and -tracks are required")
+	}
+	gt, err := readSequence(*gtPath, eval.ReadGroundTruth)
+	if err != nil {
+		return err
+	}
+	tracks, err := readSequence(*tracksPath, eval.ReadMOT)
+	if err != nil {
+		return err
+	}
+
+	m := eval.Evaluate(gt, tracks)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "frames\t%d\n", m.Frames)
+	fmt.Fprintf(w, "MOTA\t%.3f\n", m.MOTA)
+	fmt.Fprintf(w, "MOTP\t%.3f\n", m.MOTP)
+	fmt.Fprintf(w, "IDF1\t%.3f\n", m.IDF1)
+	fmt.Fprintf(w, "HOTA\t%.3f\t(DetA %.3f, AssA %.3f, LocA %.3f)\n", m.HOTA, m.DetA, m.AssA, m.LocA)
+	fmt.Fprintf(w, "ID switches\t%d\n", m.IDSwitches)
+	fmt.Fprintf(w, "fragmentations\t%d\n", m.Fragmentations)
+	fmt.Fprintf(w, "TP / FP / FN\t%d / %d / %d\n", m.TP, m.FP, m.FN)
+	return w.Flush()
+}
+
+// readSequence reads a MOTChallenge file with read.
+func readSequence(path string, read func(io.Reader) (eval.Sequence, error)) (eval.Sequence, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	seq, err := read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return seq, nil
+}
+
+// tune searches the attributes of the tracker that score best on sequences with ground truth.
+func tune(args []string) error {
+	flags := flag.NewFlagSet("tune", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: module tune -space <space.json> [options] <sequence dir>...")
+		flags.PrintDefaults()
+	}
+	spacePath := flags.String("space", "", `JSON file with the tuned attributes, each a list of values or a {"min": ..., "max": ...} range`)
+	configPath := flags.String("config", "", "JSON file with the attributes that are not tuned")
+	metric := flags.String("metric", object_tracker.TuneMetricHOTA, "metric to maximize: hota, mota or idf1")
+	trials := flags.Int("trials", object_tracker.DefaultTuneTrials, "number of attribute sets tried when an attribute has a range")
+	seed := flags.Int64("seed", 1, "seed of the random search")
+	motClass := flags.String("mot-class", object_tracker.DefaultMOTClass, "class of the detections of MOTChallenge files")
+	top := flags.Int("top", 10, "number of trials in the report")
+	outputPath := flags.String("output", "", "output file for the best attributes, stdout if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *spacePath == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("-space and at least one sequence directory are required")
+	}
+
+	opts := object_tracker.TuneOptions{Trials: *trials, Seed: *seed, Metric: *metric, MOTClass: *motClass}
+	if err := readJSON(*spacePath, &opts.Space); err != nil {
+		return err
+	}
+	if *configPath != "" {
+		if err := readJSON(*configPath, &opts.Base); err != nil {
+			return err
+		}
+	}
+	for _, dir := range flags.Args() {
+		seqs, err := object_tracker.FindTuneSequences(dir)
+		if err != nil {
+			return err
+		}
+		opts.Sequences = append(opts.Sequences, seqs...)
+	}
+
+	// the trackers of the trials would flood the output
+	logger := logging.NewLogger("object-tracker")
+	logger.SetLevel(logging.WARN)
+	result, err := object_tracker.Tune(context.Background(), opts, logger)
+	if err != nil {
+		return err
+	}
+	if err := printTuneReport(os.Stderr, result, *top); err != nil {
+		return err
+	}
+
+	best, err := json.MarshalIndent(result.Attributes, "", "  ")
+	if err != nil {
+		return err
+	}
+	best = append(best, '\n')
+	if *outputPath == "" {
+		_, err = os.Stdout.Write(best)
+		return err
+	}
+	return os.WriteFile(*outputPath, best, 0o644)
+}
+
+// printTuneReport writes the metrics of the best trial on each sequence, and the top trials.
+func printTuneReport(out io.Writer, result *object_tracker.TuneResult, top int) error {
+	best := result.Trials[0]
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "sequence\tframes\tMOTA\tIDF1\tHOTA\tIDSW")
+	names := make([]string, 0, len(best.Sequences))
+	for name := range best.Sequences {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	row := func(name string, m eval.Metrics) {
+		fmt.Fprintf(w, "%v\t%d\t%.3f\t%.3f\t%.3f\t%d\n", name, m.Frames, m.MOTA, m.IDF1, m.HOTA, m.IDSwitches)
+	}
+	for _, name := range names {
+		row(name, best.Sequences[name])
+	}
+	row("all", best.Metrics)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "rank\tscore\tattributes")
+	for k, trial := range result.Trials[:min(top, len(result.Trials))] {
+		attrs, err := json.Marshal(trial.Values)
+		if err != nil {
+			return err
+		}
+		if trial.Err != nil {
+			fmt.Fprintf(w, "%d\t-\t%s (%v)\n", k+1, attrs, trial.Err)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%.3f\t%s\n", k+1, trial.Score, attrs)
+	}
+	if failed := slices.IndexFunc(result.Trials, func(t object_tracker.TuneTrial) bool { return t.Err != nil }); failed >= 0 {
+		fmt.Fprintf(w, "\n%d of %d trials failed, such as %s\n", len(result.Trials)-failed, len(result.Trials), result.Trials[failed].Err)
+	}
+	return w.Flush()
 }
@@ -0,0 +1,42 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the configuration of the tracks, which are matched with new detections and
+// kept by the tracker of the tracking package.
+package object_tracker
+
+import (
+	"github.com/viam-modules/object-tracking/tracking"
+)
+
+// configureTracks creates the tracker of the tracking package that keeps the tracks, or applies the
+// configuration to it and keeps its tracks.
+func (t *myTracker) configureTracks() error {
+	if t.classCounter == nil {
+		t.classCounter = make(map[string]int)
+	}
+	if t.lastSeen == nil {
+		t.lastSeen = make(map[string]*track)
+	}
+	config := tracking.Config{
+		// the first detection of a track does not count towards its persistence
+		MinHits: max(t.minTrackPersistence, 1) + 1,
+		// lost tracks can be re-acquired for buffer_size frames
+		MaxMisses:  t.bufferSize,
+		MaxHistory: t.maxTrackHistory,
+	}
+	opts := []tracking.Option{
+		tracking.WithMotionModel(sortMotion{}),
+		tracking.WithIDFunc(t.nextTrackID),
+	}
+	if t.directionWeight > 0 {
+		opts = append(opts, tracking.WithCostFunction(directionCost{weight: t.directionWeight}))
+	}
+	if t.tracks == nil {
+		tracks, err := tracking.New(config, opts...)
+		if err != nil {
+			return err
+		}
+		t.tracks = tracks
+		return nil
+	}
+	return t.tracks.Reconfigure(config, opts...)
+}
@@ -0,0 +1,116 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the per-track class histogram, used to decide which class a track is reported as
+// when the detector gives it different classes over time.
+package object_tracker
+
+import (
+	"strings"
+)
+
+const (
+	// ClassPolicyLocked keeps the class the track was first detected as.
+	ClassPolicyLocked = "locked"
+	// ClassPolicyMajority reports the class with the highest total score over the track's life.
+	ClassPolicyMajority = "majority"
+	// ClassPolicyLatest reports the class of the latest detection.
+	ClassPolicyLatest = "latest"
+)
+
+// classVotes is the histogram of the classes a track was detected as, weighted by score.
+type classVotes struct {
+	scores map[string]float64
+	latest string
+	// labels holds the label the track was given for each class it was reported as
+	labels map[string]string
+}
+
+// add counts a detection of the given class and score.
+func (v *classVotes) add(class string, score float64) {
+	v.scores[class] += score
+	v.latest = class
+}
+
+// majority returns the class with the highest total score. Ties go to the latest class, and
+// then to the first class in alphabetical order.
+func (v *classVotes) majority() string {
+	best := v.latest
+	for class, score := range v.scores {
+		if score > v.scores[best] || (score == v.scores[best] && best != v.latest && class < best) {
+			best = class
+		}
+	}
+	return best
+}
+
+// voteClass adds the latest class of the track to its histogram. It returns the class the track
+// should be reported as under the given policy.
+func (t *myTracker) voteClass(tr *track, policy string) string {
+	if t.classVotes == nil {
+		t.classVotes = make(map[string]*classVotes)
+	}
+	id := getTrackingLabel(tr)
+	votes, ok := t.classVotes[id]
+	if !ok {
+		labelClass := strings.Split(tr.Det.Label(), "_")[0]
+		votes = &classVotes{
+			scores: make(map[string]float64),
+			labels: map[string]string{labelClass: tr.Det.Label()},
+		}
+		t.classVotes[id] = votes
+	}
+	votes.add(tr.class, tr.score)
+	switch policy {
+	case ClassPolicyMajority:
+		return votes.majority()
+	case ClassPolicyLatest:
+		return votes.latest
+	default:
+		return strings.Split(tr.Det.Label(), "_")[0]
+	}
+}
+
+// votedClass returns the class with the highest total score over the track's life, or "" if the
+// track was never detected.
+func (t *myTracker) votedClass(id string) string {
+	votes, ok := t.classVotes[id]
+	if !ok {
+		return ""
+	}
+	return votes.majority()
+}
+
+// classLabel returns the label of the track when it is reported as the given class. The first time
+// a track is reported as a class, it gets the next counter of that class, so that labels stay unique,
+// and keeps the time it was first seen. It is only called while stepping the tracker, since it
+// allocates counters.
+func (t *myTracker) classLabel(tr *track, class string) string {
+	label := tr.Det.Label()
+	votes, ok := t.classVotes[getTrackingLabel(tr)]
+	if !ok || strings.Split(label, "_")[0] == class {
+		return label
+	}
+	if classLabel, ok := votes.labels[class]; ok {
+		return classLabel
+	}
+	parts := strings.Split(label, "_")
+	classLabel := t.nextTrackID(class)
+	if len(parts) > 2 {
+		classLabel += "_" + strings.Join(parts[2:], "_")
+	}
+	votes.labels[class] = classLabel
+	return classLabel
+}
+
+// logClassChange adds the new label of a stable track that changed class to the object log.
+func (t *myTracker) logClassChange(previous, tr *track) {
+	to, err := newTrackedObjectFromLabel(tr.Det.Label())
+	if err != nil {
+		t.logger.Error(err)
+		return
+	}
+	to.PreviousLabel = previous.Det.Label()
+	t.logger.Debugf("track %v changed class, now labeled %v", previous.Det.Label(), tr.Det.Label())
+	t.allFreshObjects.mutex.Lock()
+	t.allFreshObjects.objects = append(t.allFreshObjects.objects, to)
+	t.allFreshObjects.mutex.Unlock()
+}
@@ -0,0 +1,77 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+func TestClassVotes(t *testing.T) {
+	votes := &classVotes{scores: make(map[string]float64)}
+	votes.add("dog", 0.9)
+	votes.add("cat", 0.5)
+	test.That(t, votes.majority(), test.ShouldEqual, "dog")
+	test.That(t, votes.latest, test.ShouldEqual, "cat")
+	votes.add("cat", 0.4)
+	// a tie goes to the latest class
+	test.That(t, votes.majority(), test.ShouldEqual, "cat")
+	votes.add("cat", 0.3)
+	votes.add("dog", 0.1)
+	test.That(t, votes.majority(), test.ShouldEqual, "cat")
+}
+
+func TestClassPolicy(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	box := image.Rect(10, 10, 30, 30)
+	run := func(policy string, classes ...string) *myTracker {
+		fakeTracker := &myTracker{
+			logger:              logging.NewTestLogger(t),
+			cancelContext:       context.Background(),
+			classCounter:        make(map[string]int),
+			bufferSize:          10,
+			minTrackPersistence: 1,
+			maxTrackHistory:     DefaultMaxTrackHistory,
+			classPolicy:         policy,
+		}
+		test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+		for _, class := range classes {
+			fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box, 0.8, class)}))
+		}
+		test.That(t, len(fakeTracker.lastDetections), test.ShouldEqual, 1)
+		return fakeTracker
+	}
+
+	locked := run(ClassPolicyLocked, "dog", "cat", "cat", "cat")
+	checkLabel(t, locked.lastDetections[0], "dog_0")
+	test.That(t, len(locked.allFreshObjects.objects), test.ShouldEqual, 1)
+
+	latest := run(ClassPolicyLatest, "dog", "dog", "cat")
+	checkLabel(t, latest.lastDetections[0], "cat_0")
+
+	majority := run(ClassPolicyMajority, "dog", "dog", "cat", "cat", "cat")
+	tr := majority.lastDetections[0]
+	checkLabel(t, tr, "cat_0")
+	// the track keeps its ID, and the time it was first seen
+	test.That(t, getTrackingLabel(tr), test.ShouldEqual, "dog_0")
+	et, ok := majority.tracks.Track("dog_0")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, len(et.History), test.ShouldEqual, 5)
+
+	// the change is logged, along with the label the track had before
+	objects := majority.allFreshObjects.objects
+	test.That(t, len(objects), test.ShouldEqual, 2)
+	test.That(t, objects[0].Label, test.ShouldEqual, "dog")
+	test.That(t, objects[0].PreviousLabel, test.ShouldBeEmpty)
+	test.That(t, objects[1].Label, test.ShouldEqual, "cat")
+	test.That(t, objects[1].Id, test.ShouldEqual, 0)
+	test.That(t, objects[1].Time, test.ShouldEqual, objects[0].Time)
+	test.That(t, objects[1].PreviousLabel, test.ShouldEqual, objects[0].FullLabel)
+
+	// switching back to a class gives back the label the track had for it
+	majority.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box, 0.8, "dog")}))
+	test.That(t, majority.lastDetections[0].Det.Label(), test.ShouldEqual, objects[0].FullLabel)
+}
@@ -0,0 +1,81 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the ensemble of detectors, which run concurrently on the same frame and whose
+// detections are fused into a single set before tracking.
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/services/vision"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+)
+
+// DetectorLabels holds a label filter per detector name, in the format of chosen_labels.
+type DetectorLabels map[string]map[string]float64
+
+// ensembleMember is a single detector of the ensemble.
+type ensembleMember struct {
+	name     string
+	detector vision.Service
+	// labels is the label filter of the detector, in the format of chosen_labels. Empty keeps every class.
+	labels  map[string]float64
+	latency *latencyHistogram
+}
+
+// ensemble runs several detectors on the same frame and fuses their detections.
+type ensemble struct {
+	members []*ensembleMember
+	fusion  DuplicateSuppression
+}
+
+// Detections runs every detector concurrently on the image, keeps the classes in the label filter
+// of each detector, and fuses the boxes that several detectors found for the same object.
+func (e *ensemble) Detections(ctx context.Context, img image.Image) ([]objdet.Detection, error) {
+	results := make([][]objdet.Detection, len(e.members))
+	errs := make([]error, len(e.members))
+	var wg sync.WaitGroup
+	for i, m := range e.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			dets, err := m.detector.Detections(ctx, img, nil)
+			m.latency.Record(time.Since(start))
+			if err != nil {
+				errs[i] = errors.Wrapf(err, "detector %v", m.name)
+				return
+			}
+			results[i] = NewAdvancedFilter(m.labels)(dets)
+		}()
+	}
+	wg.Wait()
+	var all []objdet.Detection
+	for i := range e.members {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		all = append(all, results[i]...)
+	}
+	return NewDuplicateSuppression(e.fusion)(all), nil
+}
+
+// benchmark returns the latency of each detector, by name.
+func (e *ensemble) benchmark() map[string]benchmark {
+	out := make(map[string]benchmark, len(e.members))
+	for _, m := range e.members {
+		out[m.name] = m.latency.Benchmark()
+	}
+	return out
+}
+
+// runDetector runs the ensemble on the image if one is configured, or the single detector.
+func (t *myTracker) runDetector(ctx context.Context, img image.Image) ([]objdet.Detection, error) {
+	if t.ensemble != nil {
+		return t.ensemble.Detections(ctx, img)
+	}
+	return t.detector.Detections(ctx, img, nil)
+}
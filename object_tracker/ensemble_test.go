@@ -0,0 +1,95 @@
+package object_tracker
+
+import (
+	"context"
+	"errors"
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/testutils/inject"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+func fakeDetector(dets ...objdet.Detection) *inject.VisionService {
+	return &inject.VisionService{
+		DetectionsFunc: func(ctx context.Context, img image.Image, extra map[string]interface{}) ([]objdet.Detection, error) {
+			return dets, nil
+		},
+	}
+}
+
+func TestEnsembleFusesDetectors(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	// both detectors see the person, only the vehicle detector sees the car
+	people := fakeDetector(
+		objdet.NewDetection(bounds, image.Rect(10, 10, 50, 90), 0.9, "person"),
+		objdet.NewDetection(bounds, image.Rect(150, 10, 190, 50), 0.8, "chair"),
+	)
+	vehicles := fakeDetector(
+		objdet.NewDetection(bounds, image.Rect(12, 10, 52, 90), 0.6, "person"),
+		objdet.NewDetection(bounds, image.Rect(100, 40, 140, 80), 0.7, "car"),
+	)
+	e := &ensemble{
+		members: []*ensembleMember{
+			{name: "people", detector: people, labels: map[string]float64{"person": 0.5}, latency: newLatencyHistogram()},
+			{name: "vehicles", detector: vehicles, latency: newLatencyHistogram()},
+		},
+		fusion: DuplicateSuppression{Method: DuplicatesWBF, IOUThreshold: DefaultDuplicateIOUThreshold},
+	}
+	dets, err := e.Detections(context.Background(), image.NewRGBA(bounds))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(dets), test.ShouldEqual, 2)
+	// the chair is not in the label filter of the people detector
+	test.That(t, dets[0].Label(), test.ShouldEqual, "person")
+	test.That(t, dets[0].Score(), test.ShouldEqual, 0.9)
+	// (0.9*10 + 0.6*12) / 1.5 = 10.8
+	test.That(t, *dets[0].BoundingBox(), test.ShouldResemble, image.Rect(11, 10, 51, 90))
+	test.That(t, dets[1].Label(), test.ShouldEqual, "car")
+
+	report := e.benchmark()
+	test.That(t, report["people"].NumberOfRuns, test.ShouldEqual, 1)
+	test.That(t, report["vehicles"].NumberOfRuns, test.ShouldEqual, 1)
+}
+
+func TestEnsembleReportsFailingDetector(t *testing.T) {
+	broken := &inject.VisionService{
+		DetectionsFunc: func(ctx context.Context, img image.Image, extra map[string]interface{}) ([]objdet.Detection, error) {
+			return nil, errors.New("no model")
+		},
+	}
+	e := &ensemble{members: []*ensembleMember{
+		{name: "fine", detector: fakeDetector(), latency: newLatencyHistogram()},
+		{name: "broken", detector: broken, latency: newLatencyHistogram()},
+	}}
+	_, err := e.Detections(context.Background(), image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "broken")
+}
+
+func TestValidateEnsemble(t *testing.T) {
+	cfg := Config{
+		CameraName:     "camera",
+		DetectorNames:  []string{"people", "vehicles"},
+		DetectorLabels: map[string]map[string]float64{"people": {"person": 0.5}},
+	}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"camera", "people", "vehicles"})
+
+	both := cfg
+	both.DetectorName = "detector"
+	_, _, err = both.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	repeated := cfg
+	repeated.DetectorNames = []string{"people", "people"}
+	_, _, err = repeated.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	unknown := cfg
+	unknown.DetectorLabels = map[string]map[string]float64{"animals": {"dog": 0.5}}
+	_, _, err = unknown.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "animals")
+}
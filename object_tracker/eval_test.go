@@ -0,0 +1,102 @@
+package object_tracker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+
+	"github.com/viam-modules/object-tracking/eval"
+)
+
+// motSequences are the synthetic sequences of test_files/mot, with the scores the tracker reaches
+// on them with its default attributes, changed by config. The scores are slightly below the
+// current ones, so that only regressions fail.
+var motSequences = []struct {
+	name string
+	// sequence is the directory of the sequence in test_files/mot
+	sequence      string
+	config        Config
+	minMOTA       float64
+	minIDF1       float64
+	minHOTA       float64
+	maxIDSwitches int
+}{
+	// three pedestrians walking in separate lanes, with missed and spurious detections
+	{name: "parallel", sequence: "parallel", minMOTA: 0.89, minIDF1: 0.93, minHOTA: 0.8, maxIDSwitches: 0},
+	// two pedestrians crossing paths, where the one behind is not detected while they overlap
+	{name: "crossing", sequence: "crossing", minMOTA: 0.85, minIDF1: 0.92, minHOTA: 0.78, maxIDSwitches: 0},
+	// the same, with direction consistency, which keeps them apart, and coasting through the occlusion
+	{name: "crossing_direction", sequence: "crossing", config: crossingConfig, minMOTA: 0.9, minIDF1: 0.95, minHOTA: 0.81, maxIDSwitches: 0},
+	// pedestrians entering and leaving the frame at different times
+	{name: "entering", sequence: "entering", minMOTA: 0.86, minIDF1: 0.91, minHOTA: 0.77, maxIDSwitches: 0},
+}
+
+var (
+	crossingDirectionWeight = 0.5
+	crossingConfig          = Config{DirectionWeight: &crossingDirectionWeight, CoastFrames: 3}
+)
+
+func TestTrackingQuality(t *testing.T) {
+	for _, seq := range motSequences {
+		t.Run(seq.name, func(t *testing.T) {
+			m := evaluateSequence(t, seq.sequence, seq.config)
+			t.Logf("MOTA %.3f MOTP %.3f IDF1 %.3f HOTA %.3f IDSW %d Frag %d",
+				m.MOTA, m.MOTP, m.IDF1, m.HOTA, m.IDSwitches, m.Fragmentations)
+			test.That(t, m.MOTA, test.ShouldBeGreaterThanOrEqualTo, seq.minMOTA)
+			test.That(t, m.IDF1, test.ShouldBeGreaterThanOrEqualTo, seq.minIDF1)
+			test.That(t, m.HOTA, test.ShouldBeGreaterThanOrEqualTo, seq.minHOTA)
+			test.That(t, m.IDSwitches, test.ShouldBeLessThanOrEqualTo, seq.maxIDSwitches)
+		})
+	}
+}
+
+// TestCrossingKeepsIDs checks that the pedestrian hidden for 5 frames while the two cross paths
+// keeps its ID, since a lost track is predicted to keep moving while it is missed.
+func TestCrossingKeepsIDs(t *testing.T) {
+	m := evaluateSequence(t, "crossing", crossingConfig)
+	test.That(t, m.IDSwitches, test.ShouldEqual, 0)
+	test.That(t, m.IDF1, test.ShouldBeGreaterThanOrEqualTo, 0.9)
+}
+
+// evaluateSequence runs the tracker with the given attributes on a sequence of test_files/mot, and
+// scores its tracks against the ground truth.
+func evaluateSequence(t *testing.T, name string, config Config) eval.Metrics {
+	t.Helper()
+	dir := filepath.Join("..", "test_files", "mot", name)
+	f, err := os.Open(filepath.Join(dir, "gt.txt"))
+	test.That(t, err, test.ShouldBeNil)
+	gt, err := eval.ReadGroundTruth(f)
+	f.Close()
+	test.That(t, err, test.ShouldBeNil)
+
+	// the detections are all the tracker sees, so the frames are blank
+	frames := t.TempDir()
+	for _, n := range gt.Frames() {
+		f, err := os.Create(filepath.Join(frames, fmt.Sprintf("%06d.png", n)))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, png.Encode(f, image.NewGray(image.Rect(0, 0, 640, 480))), test.ShouldBeNil)
+		test.That(t, f.Close(), test.ShouldBeNil)
+	}
+	test.That(t, slices.Max(gt.Frames()), test.ShouldEqual, len(gt.Frames()))
+
+	var mot bytes.Buffer
+	_, err = RunOffline(context.Background(), OfflineOptions{
+		Input:      frames,
+		Detections: filepath.Join(dir, "det.txt"),
+		Config:     config,
+		MOTOutput:  &mot,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	tracks, err := eval.ReadMOT(&mot)
+	test.That(t, err, test.ShouldBeNil)
+	return eval.Evaluate(gt, tracks)
+}
@@ -3,15 +3,212 @@
 package object_tracker
 
 import (
-	objdet "go.viam.com/rdk/vision/objectdetection"
+	"image"
+	"math"
+	"path"
+	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/pkg/errors"
+	objdet "go.viam.com/rdk/vision/objectdetection"
 )
 
+const (
+	// BorderKeep keeps boxes touching the image border like any other box.
+	BorderKeep = "keep"
+	// BorderSuppress removes boxes touching the image border.
+	BorderSuppress = "suppress"
+	// BorderTag keeps boxes touching the image border, but marks them as truncated.
+	BorderTag = "tag"
+
+	// allClasses is the key of the box filter that applies to classes without their own filter.
+	allClasses = "*"
+)
+
+// BoxFilter holds the geometric limits a detection must be within to be tracked. Limits left at
+// 0 are not checked. Areas are either in pixels or as a fraction of the frame, and the aspect ratio
+// is the width of the box over its height.
+type BoxFilter struct {
+	MinAreaPx       float64 `json:"min_area_px,omitempty"`
+	MaxAreaPx       float64 `json:"max_area_px,omitempty"`
+	MinAreaFraction float64 `json:"min_area_fraction,omitempty"`
+	MaxAreaFraction float64 `json:"max_area_fraction,omitempty"`
+	MinAspectRatio  float64 `json:"min_aspect_ratio,omitempty"`
+	MaxAspectRatio  float64 `json:"max_aspect_ratio,omitempty"`
+	Border          string  `json:"border,omitempty"`
+	BorderMarginPx  int     `json:"border_margin_px,omitempty"`
+}
+
+// BoxFilters holds a box filter per class name. The filter with the key "*" applies to
+// every class that does not have its own.
+type BoxFilters map[string]BoxFilter
+
+// Validate checks that the limits of every filter are consistent.
+func (bf BoxFilters) Validate() error {
+	for class, f := range bf {
+		if f.MinAreaPx < 0 || f.MaxAreaPx < 0 || f.MinAspectRatio < 0 || f.MaxAspectRatio < 0 || f.BorderMarginPx < 0 {
+			return errors.Errorf("box filter for %q cannot have limits less than 0", class)
+		}
+		if f.MinAreaFraction < 0 || f.MinAreaFraction > 1 || f.MaxAreaFraction < 0 || f.MaxAreaFraction > 1 {
+			return errors.Errorf("box filter for %q must have area fractions between 0 and 1", class)
+		}
+		if f.MaxAreaPx > 0 && f.MinAreaPx > f.MaxAreaPx {
+			return errors.Errorf("box filter for %q has min_area_px above max_area_px", class)
+		}
+		if f.MaxAreaFraction > 0 && f.MinAreaFraction > f.MaxAreaFraction {
+			return errors.Errorf("box filter for %q has min_area_fraction above max_area_fraction", class)
+		}
+		if f.MaxAspectRatio > 0 && f.MinAspectRatio > f.MaxAspectRatio {
+			return errors.Errorf("box filter for %q has min_aspect_ratio above max_aspect_ratio", class)
+		}
+		switch f.Border {
+		case "", BorderKeep, BorderSuppress, BorderTag:
+		default:
+			return errors.Errorf("box filter for %q must have a border of %q, %q or %q", class, BorderKeep, BorderSuppress, BorderTag)
+		}
+	}
+	return nil
+}
+
+// forDetection returns the filter that applies to the class of the detection.
+func (bf BoxFilters) forDetection(d objdet.Detection) (BoxFilter, bool) {
+	if len(bf) == 0 {
+		return BoxFilter{}, false
+	}
+	baseLabel := strings.ToLower(strings.Split(d.Label(), "_")[0])
+	if f, ok := bf[baseLabel]; ok {
+		return f, true
+	}
+	f, ok := bf[allClasses]
+	return f, ok
+}
+
+// Truncated returns whether the detection touches the image border and its filter tags such boxes.
+func (bf BoxFilters) Truncated(d objdet.Detection) bool {
+	f, ok := bf.forDetection(d)
+	return ok && f.Border == BorderTag && f.touchesBorder(d)
+}
+
+// touchesBorder returns whether the box is within the border margin of the edge of the image.
+// Detections without image bounds never touch it.
+func (f BoxFilter) touchesBorder(d objdet.Detection) bool {
+	bounds := ImageBoundsFromDet(d)
+	if bounds == nil {
+		return false
+	}
+	inner := bounds.Inset(f.BorderMarginPx + 1)
+	return !d.BoundingBox().In(inner)
+}
+
+// keep returns whether the detection is within the limits of the filter. Limits relative to the
+// frame are not checked for detections without image bounds.
+func (f BoxFilter) keep(d objdet.Detection) bool {
+	box := *d.BoundingBox()
+	area := float64(box.Dx() * box.Dy())
+	if (f.MinAreaPx > 0 && area < f.MinAreaPx) || (f.MaxAreaPx > 0 && area > f.MaxAreaPx) {
+		return false
+	}
+	if box.Dy() > 0 {
+		aspect := float64(box.Dx()) / float64(box.Dy())
+		if (f.MinAspectRatio > 0 && aspect < f.MinAspectRatio) || (f.MaxAspectRatio > 0 && aspect > f.MaxAspectRatio) {
+			return false
+		}
+	}
+	if bounds := ImageBoundsFromDet(d); bounds != nil && !bounds.Empty() {
+		fraction := area / float64(bounds.Dx()*bounds.Dy())
+		if (f.MinAreaFraction > 0 && fraction < f.MinAreaFraction) || (f.MaxAreaFraction > 0 && fraction > f.MaxAreaFraction) {
+			return false
+		}
+	}
+	if f.Border == BorderSuppress && f.touchesBorder(d) {
+		return false
+	}
+	return true
+}
+
+// NewBoxFilter returns a Detections->Detections filtering method to remove detections
+// that are outside the geometric limits of the box filter of their class.
+func NewBoxFilter(filters BoxFilters) objdet.Postprocessor {
+	return func(detections []objdet.Detection) []objdet.Detection {
+		if len(filters) == 0 {
+			return detections
+		}
+		out := make([]objdet.Detection, 0, len(detections))
+		for _, d := range detections {
+			if f, ok := filters.forDetection(d); ok && !f.keep(d) {
+				continue
+			}
+			out = append(out, d)
+		}
+		return out
+	}
+}
+
+// labelMatcher returns whether a class name matches a key of chosen_labels.
+type labelMatcher func(class string) bool
+
+// newLabelMatcher returns the matcher for a key of chosen_labels. Keys between slashes are regular
+// expressions, keys with any of the characters *?[ are glob patterns, and other keys are class names.
+// Class names are lower-cased, and every key matches them whatever its case.
+func newLabelMatcher(key string) (labelMatcher, error) {
+	switch {
+	case len(key) > 1 && strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/"):
+		re, err := regexp.Compile("(?i)" + key[1:len(key)-1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid regular expression %q in chosen_labels", key)
+		}
+		return re.MatchString, nil
+	case strings.ContainsAny(key, "*?["):
+		pattern := strings.ToLower(key)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern %q in chosen_labels", key)
+		}
+		return func(class string) bool {
+			ok, _ := path.Match(pattern, class)
+			return ok
+		}, nil
+	default:
+		name := strings.ToLower(key)
+		return func(class string) bool {
+			return class == name
+		}, nil
+	}
+}
+
+// ValidateChosenLabels checks that every pattern in chosenLabels is valid.
+func ValidateChosenLabels(chosenLabels map[string]float64) error {
+	for key := range chosenLabels {
+		if _, err := newLabelMatcher(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // NewAdvancedFilter returns a Detections->Detections filtering method to remove
 // detections that do not have a class name in chosenLabels and/or do not have the
 // associated minimum confidence. An empty input map will return all detections.
-// Input chosenLabels is the map with <"class_name": confidence> key-value pairs.
+// Input chosenLabels is the map with <"class_name": confidence> key-value pairs, where class
+// names can also be glob patterns or regular expressions between slashes. A class name is
+// matched exactly first, and then against the longest matching pattern.
 func NewAdvancedFilter(chosenLabels map[string]float64) objdet.Postprocessor {
+	type pattern struct {
+		key     string
+		matches labelMatcher
+	}
+	var patterns []pattern
+	for key := range chosenLabels {
+		if matches, err := newLabelMatcher(key); err == nil && strings.ContainsAny(key, "/*?[") {
+			patterns = append(patterns, pattern{key, matches})
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i].key) != len(patterns[j].key) {
+			return len(patterns[i].key) > len(patterns[j].key)
+		}
+		return patterns[i].key < patterns[j].key
+	})
 	return func(detections []objdet.Detection) []objdet.Detection {
 		// If it's empty, return the input.
 		if len(chosenLabels) < 1 {
@@ -21,6 +218,14 @@ func NewAdvancedFilter(chosenLabels map[string]float64) objdet.Postprocessor {
 		for _, d := range detections {
 			baseLabel := strings.ToLower(strings.Split(d.Label(), "_")[0])
 			minConf, ok := chosenLabels[baseLabel]
+			for _, p := range patterns {
+				if ok {
+					break
+				}
+				if p.matches(baseLabel) {
+					minConf, ok = chosenLabels[p.key], true
+				}
+			}
 			if ok {
 				if d.Score() > minConf {
 					out = append(out, d)
@@ -31,7 +236,168 @@ func NewAdvancedFilter(chosenLabels map[string]float64) objdet.Postprocessor {
 	}
 }
 
+// NewLabelAliases returns a Detections->Detections method that renames the classes given by the
+// detector to their canonical class, so that "car", "truck" and "bus" can all be tracked as
+// "vehicle". Aliases map lower-cased detector classes to canonical classes, where the class is the
+// part of the label before the first "_", like in the other filters.
+func NewLabelAliases(aliases map[string]string) objdet.Postprocessor {
+	return func(detections []objdet.Detection) []objdet.Detection {
+		if len(aliases) == 0 {
+			return detections
+		}
+		out := make([]objdet.Detection, 0, len(detections))
+		for _, d := range detections {
+			canonical, ok := aliases[strings.ToLower(strings.Split(d.Label(), "_")[0])]
+			if !ok {
+				out = append(out, d)
+				continue
+			}
+			if imageBounds := ImageBoundsFromDet(d); imageBounds != nil {
+				out = append(out, objdet.NewDetection(*imageBounds, *d.BoundingBox(), d.Score(), canonical))
+			} else {
+				out = append(out, objdet.NewDetectionWithoutImgBounds(*d.BoundingBox(), d.Score(), canonical))
+			}
+		}
+		return out
+	}
+}
+
+// DuplicateSuppression describes how overlapping boxes of the same object are merged, whatever
+// their classes. The zero value keeps every box.
+type DuplicateSuppression struct {
+	// Method is DuplicatesNone, DuplicatesNMS or DuplicatesWBF
+	Method string
+	// IOUThreshold is the overlap above which two boxes are considered the same object
+	IOUThreshold float64
+	// ClassPolicy is DuplicateClassBest or DuplicateClassVote
+	ClassPolicy string
+}
+
+const (
+	// DuplicatesNone keeps every box.
+	DuplicatesNone = "none"
+	// DuplicatesNMS keeps the box with the highest score of each group of duplicates.
+	DuplicatesNMS = "nms"
+	// DuplicatesWBF replaces each group of duplicates with the average of its boxes, weighted by score.
+	DuplicatesWBF = "wbf"
+
+	// DuplicateClassBest gives a merged box the class of the duplicate with the highest score.
+	DuplicateClassBest = "best"
+	// DuplicateClassVote gives a merged box the class with the highest total score among the duplicates.
+	DuplicateClassVote = "vote"
+
+	// DefaultDuplicateIOUThreshold is the overlap above which two boxes are considered the same object.
+	DefaultDuplicateIOUThreshold = 0.6
+)
+
+// NewDuplicateSuppression returns a Detections->Detections method that merges the boxes overlapping
+// a box with a higher score by more than the IOU threshold, whatever their class. Merged boxes keep
+// the highest score of their group.
+func NewDuplicateSuppression(ds DuplicateSuppression) objdet.Postprocessor {
+	return func(detections []objdet.Detection) []objdet.Detection {
+		if ds.Method != DuplicatesNMS && ds.Method != DuplicatesWBF {
+			return detections
+		}
+		order := make([]int, len(detections))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return detections[order[a]].Score() > detections[order[b]].Score()
+		})
+		merged := make([]bool, len(detections))
+		out := make([]objdet.Detection, 0, len(detections))
+		for _, i := range order {
+			if merged[i] {
+				continue
+			}
+			leader := detections[i]
+			group := []objdet.Detection{leader}
+			for _, j := range order {
+				if j == i || merged[j] {
+					continue
+				}
+				if IOU(leader.BoundingBox(), detections[j].BoundingBox()) > ds.IOUThreshold {
+					merged[j] = true
+					group = append(group, detections[j])
+				}
+			}
+			merged[i] = true
+			out = append(out, mergeDuplicates(group, ds))
+		}
+		return out
+	}
+}
+
+// mergeDuplicates merges a group of duplicates, led by the one with the highest score.
+func mergeDuplicates(group []objdet.Detection, ds DuplicateSuppression) objdet.Detection {
+	leader := group[0]
+	if len(group) == 1 {
+		return leader
+	}
+	box := *leader.BoundingBox()
+	if ds.Method == DuplicatesWBF {
+		var sum [4]float64
+		total := 0.0
+		for _, d := range group {
+			b := d.BoundingBox()
+			w := d.Score()
+			sum[0] += w * float64(b.Min.X)
+			sum[1] += w * float64(b.Min.Y)
+			sum[2] += w * float64(b.Max.X)
+			sum[3] += w * float64(b.Max.Y)
+			total += w
+		}
+		if total > 0 {
+			box = image.Rect(
+				int(math.Round(sum[0]/total)), int(math.Round(sum[1]/total)),
+				int(math.Round(sum[2]/total)), int(math.Round(sum[3]/total)),
+			)
+		}
+	}
+	label := leader.Label()
+	if ds.ClassPolicy == DuplicateClassVote {
+		scores := make(map[string]float64)
+		for _, d := range group {
+			scores[d.Label()] += d.Score()
+		}
+		for _, d := range group {
+			// ties go to the class of the box with the highest score
+			if scores[d.Label()] > scores[label] {
+				label = d.Label()
+			}
+		}
+	}
+	if imageBounds := ImageBoundsFromDet(leader); imageBounds != nil {
+		return objdet.NewDetection(*imageBounds, box, leader.Score(), label)
+	}
+	return objdet.NewDetectionWithoutImgBounds(box, leader.Score(), label)
+}
+
+// FilterOptions describes which detections FilterDetectionsWithOptions keeps. The zero value
+// keeps every detection.
+type FilterOptions struct {
+	// ChosenLabels maps the labels to keep to their minimum confidence, and keeps every label when empty
+	ChosenLabels map[string]float64
+	// MinConfidence is the confidence every kept detection is above
+	MinConfidence float64
+	// BoxFilters are the geometric limits of the boxes of each class
+	BoxFilters BoxFilters
+	// Duplicates is how overlapping boxes of the same object are merged
+	Duplicates DuplicateSuppression
+}
+
+// FilterDetections keeps the detections in the chosen labels and above the minimum confidence.
 func FilterDetections(chosenLabels map[string]float64, dets []objdet.Detection, conf float64) []objdet.Detection {
-	firstPass := NewAdvancedFilter(chosenLabels)(dets)
-	return objdet.NewScoreFilter(conf)(firstPass)
+	return FilterDetectionsWithOptions(dets, FilterOptions{ChosenLabels: chosenLabels, MinConfidence: conf})
+}
+
+// FilterDetectionsWithOptions keeps the detections in the chosen labels and above the minimum
+// confidence, merges duplicates, and keeps those within the geometric limits of the box filter of
+// their class. Classes must already be canonical.
+func FilterDetectionsWithOptions(dets []objdet.Detection, opts FilterOptions) []objdet.Detection {
+	firstPass := NewAdvancedFilter(opts.ChosenLabels)(dets)
+	secondPass := objdet.NewScoreFilter(opts.MinConfidence)(firstPass)
+	thirdPass := NewDuplicateSuppression(opts.Duplicates)(secondPass)
+	return NewBoxFilter(opts.BoxFilters)(thirdPass)
 }
@@ -0,0 +1,203 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+func TestBoxFilters(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	det := func(box image.Rectangle, label string) objdet.Detection {
+		return objdet.NewDetection(bounds, box, 0.9, label)
+	}
+	filters := BoxFilters{
+		"*":      {MinAreaPx: 100, MaxAreaFraction: 0.25},
+		"person": {MinAspectRatio: 0.2, MaxAspectRatio: 0.8, Border: BorderSuppress},
+	}
+	test.That(t, filters.Validate(), test.ShouldBeNil)
+
+	tiny := det(image.Rect(50, 50, 55, 55), "dog")
+	huge := det(image.Rect(10, 10, 190, 90), "dog")
+	dog := det(image.Rect(50, 50, 70, 70), "dog")
+	// people are taller than wide, and the per class filter replaces the global one
+	lyingPerson := det(image.Rect(20, 40, 60, 50), "person")
+	person := det(image.Rect(100, 20, 104, 30), "person")
+	clippedPerson := det(image.Rect(150, 0, 160, 20), "person")
+	dets := []objdet.Detection{tiny, huge, dog, lyingPerson, person, clippedPerson}
+	test.That(t, FilterDetectionsWithOptions(dets, FilterOptions{MinConfidence: 0.5, BoxFilters: filters}), test.ShouldResemble, []objdet.Detection{dog, person})
+
+	// without filters every detection is kept
+	test.That(t, FilterDetections(nil, dets, 0.5), test.ShouldResemble, dets)
+
+	// border handling with a margin
+	tag := BoxFilters{"*": {Border: BorderTag, BorderMarginPx: 5}}
+	test.That(t, tag.Truncated(det(image.Rect(4, 40, 20, 60), "dog")), test.ShouldBeTrue)
+	test.That(t, tag.Truncated(det(image.Rect(6, 40, 20, 60), "dog")), test.ShouldBeFalse)
+	test.That(t, tag.Truncated(det(image.Rect(150, 40, 195, 60), "dog")), test.ShouldBeTrue)
+	test.That(t, FilterDetectionsWithOptions(dets, FilterOptions{MinConfidence: 0.5, BoxFilters: tag}), test.ShouldResemble, dets)
+
+	bad := BoxFilters{"*": {MinAspectRatio: 2, MaxAspectRatio: 1}}
+	test.That(t, bad.Validate(), test.ShouldNotBeNil)
+	bad = BoxFilters{"*": {Border: "crop"}}
+	test.That(t, bad.Validate(), test.ShouldNotBeNil)
+}
+
+func TestTruncatedBoxesDoNotReidentify(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+		boxFilters:          BoxFilters{"*": {Border: BorderTag}},
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	step := func(box image.Rectangle) {
+		fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box, 0.9, LabelDet0)}))
+	}
+	// an active track can move to the border
+	step(image.Rect(10, 40, 30, 60))
+	step(image.Rect(5, 40, 25, 60))
+	step(image.Rect(0, 40, 20, 60))
+	label := fakeTracker.lastDetections[0].Det.Label()
+	id := getTrackingLabel(fakeTracker.lastDetections[0])
+	test.That(t, fakeTracker.lastDetections[0].truncated, test.ShouldBeTrue)
+	test.That(t, getTruncatedLabels(fakeTracker.lastDetections), test.ShouldResemble, []string{label})
+
+	// but once lost, a box clipped at the border does not bring it back, even when the object
+	// comes back where it left
+	fakeTracker.step(nil)
+	lost, ok := fakeTracker.tracks.Track(id)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, lost.Misses, test.ShouldEqual, 1)
+	step(image.Rect(0, 40, 18, 60))
+	lost, ok = fakeTracker.tracks.Track(id)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, lost.Misses, test.ShouldEqual, 2)
+	test.That(t, fakeTracker.lastDetections[0].Det.Label(), test.ShouldNotEqual, label)
+}
+
+func TestChosenLabelPatterns(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	box := image.Rect(10, 10, 30, 30)
+	det := func(label string, score float64) objdet.Detection {
+		return objdet.NewDetection(bounds, box, score, label)
+	}
+	chosen := map[string]float64{
+		"person*":       0.5,
+		"personal":      0.1,
+		"/^(cat|dog)$/": 0.3,
+		"/^Bird$/":      0.3,
+	}
+	test.That(t, ValidateChosenLabels(chosen), test.ShouldBeNil)
+	pedestrian := det("person", 0.6)
+	unsure := det("person_walking", 0.4)
+	// exact names take precedence over patterns
+	personal := det("personal", 0.2)
+	cat := det("Cat", 0.4)
+	hotdog := det("hotdog", 0.9)
+	// regular expressions match whatever the case
+	bird := det("bird", 0.4)
+	dets := []objdet.Detection{pedestrian, unsure, personal, cat, hotdog, bird}
+	test.That(t, NewAdvancedFilter(chosen)(dets), test.ShouldResemble, []objdet.Detection{pedestrian, personal, cat, bird})
+
+	test.That(t, ValidateChosenLabels(map[string]float64{"/(/": 0.5}), test.ShouldNotBeNil)
+	test.That(t, ValidateChosenLabels(map[string]float64{"[a": 0.5}), test.ShouldNotBeNil)
+}
+
+func TestLabelAliases(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+		chosenLabels:        map[string]float64{"vehicle": 0.5},
+		labelAliases:        map[string]string{"car": "vehicle", "truck": "vehicle"},
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	dets := []objdet.Detection{
+		objdet.NewDetection(bounds, image.Rect(10, 10, 30, 30), 0.9, "Car"),
+		objdet.NewDetection(bounds, image.Rect(50, 10, 70, 30), 0.9, "truck"),
+		objdet.NewDetection(bounds, image.Rect(90, 10, 110, 30), 0.9, "person"),
+		// the class is the part of the label before the first "_", as in chosen_labels
+		objdet.NewDetection(bounds, image.Rect(130, 10, 150, 30), 0.9, "car_parked"),
+	}
+	filtered := fakeTracker.filterDetections(dets, bounds)
+	test.That(t, len(filtered), test.ShouldEqual, 3)
+	fakeTracker.step(newTracks(filtered))
+	// the counters use the canonical class
+	test.That(t, fakeTracker.classCounter, test.ShouldResemble, map[string]int{"vehicle": 2})
+	for _, tr := range fakeTracker.lastDetections {
+		checkLabel(t, tr, "vehicle_")
+	}
+}
+
+func TestDuplicateSuppression(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	det := func(box image.Rectangle, score float64, label string) objdet.Detection {
+		return objdet.NewDetection(bounds, box, score, label)
+	}
+	// the same animal detected as a dog and twice as a cat, and a cat elsewhere
+	dog := det(image.Rect(10, 10, 50, 50), 0.9, "dog")
+	cat1 := det(image.Rect(12, 10, 52, 50), 0.6, "cat")
+	cat2 := det(image.Rect(10, 12, 50, 52), 0.5, "cat")
+	other := det(image.Rect(120, 10, 160, 50), 0.8, "cat")
+	dets := []objdet.Detection{cat1, dog, other, cat2}
+
+	// none keeps every box
+	test.That(t, NewDuplicateSuppression(DuplicateSuppression{})(dets), test.ShouldHaveLength, 4)
+
+	// nms keeps the best box
+	out := NewDuplicateSuppression(DuplicateSuppression{Method: DuplicatesNMS, IOUThreshold: 0.6})(dets)
+	test.That(t, out, test.ShouldHaveLength, 2)
+	test.That(t, out[0].Label(), test.ShouldEqual, "dog")
+	test.That(t, *out[0].BoundingBox(), test.ShouldResemble, *dog.BoundingBox())
+	test.That(t, out[0].Score(), test.ShouldEqual, 0.9)
+	test.That(t, ImageBoundsFromDet(out[0]), test.ShouldNotBeNil)
+	test.That(t, out[1], test.ShouldEqual, other)
+
+	// the class with the highest total score wins a vote
+	out = NewDuplicateSuppression(DuplicateSuppression{
+		Method: DuplicatesNMS, IOUThreshold: 0.6, ClassPolicy: DuplicateClassVote,
+	})(dets)
+	test.That(t, out, test.ShouldHaveLength, 2)
+	test.That(t, out[0].Label(), test.ShouldEqual, "cat")
+	test.That(t, out[0].Score(), test.ShouldEqual, 0.9)
+
+	// wbf averages the boxes by score
+	out = NewDuplicateSuppression(DuplicateSuppression{Method: DuplicatesWBF, IOUThreshold: 0.6})(dets)
+	test.That(t, out, test.ShouldHaveLength, 2)
+	test.That(t, out[0].Label(), test.ShouldEqual, "dog")
+	// (0.9*10 + 0.6*12 + 0.5*10) / 2 = 10.6 and (0.9*10 + 0.6*10 + 0.5*12) / 2 = 10.5
+	test.That(t, *out[0].BoundingBox(), test.ShouldResemble, image.Rect(11, 11, 51, 51))
+
+	// boxes below the threshold are kept
+	out = NewDuplicateSuppression(DuplicateSuppression{Method: DuplicatesNMS, IOUThreshold: 0.95})(dets)
+	test.That(t, out, test.ShouldHaveLength, 4)
+}
+
+func TestDuplicatesAreOneTrack(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	dets := []objdet.Detection{
+		objdet.NewDetection(bounds, image.Rect(10, 10, 50, 50), 0.9, "dog"),
+		objdet.NewDetection(bounds, image.Rect(11, 10, 51, 50), 0.8, "cat"),
+	}
+	tracker := &myTracker{
+		logger:        logging.NewTestLogger(t),
+		minConfidence: 0.5,
+		duplicates:    DuplicateSuppression{Method: DuplicatesNMS, IOUThreshold: DefaultDuplicateIOUThreshold},
+	}
+	out := tracker.filterDetections(dets, bounds)
+	test.That(t, out, test.ShouldHaveLength, 1)
+	test.That(t, out[0].Label(), test.ShouldEqual, "dog")
+}
@@ -13,10 +13,22 @@ import (
 	objdet "go.viam.com/rdk/vision/objectdetection"
 )
 
+// timestampLayout is the layout of the time in labels, YYYYMMDD_HHMMSS
+const timestampLayout = "20060102_150405"
+
 // GetTimestamp will retrieve and format a timestamp to be YYYYMMDD_HHMMSS
 func GetTimestamp() string {
 	currTime := time.Now()
-	return currTime.Format("20060102_150405")
+	return currTime.Format(timestampLayout)
+}
+
+// timestamp returns the formatted time of the frame being tracked, which is now unless the frame
+// came with its own time.
+func (t *myTracker) timestamp() string {
+	if t.frameTime.IsZero() {
+		return GetTimestamp()
+	}
+	return t.frameTime.Format(timestampLayout)
 }
 
 // ReplaceLabel replaces the detection with an almost identical detection (new label)
@@ -35,95 +47,72 @@ func ReplaceLabel(tr *track, label string) *track {
 
 // ReplaceBoundingBox replaces the detection with an almost identical detection (new bounding box)
 func ReplaceBoundingBox(tr *track, bb *image.Rectangle) *track {
+	return replaceDetection(tr, bb, tr.Det.Score(), tr.Det.Label())
+}
+
+// replaceDetection replaces the detection with one in the same image (new bounding box, score and label)
+func replaceDetection(tr *track, bb *image.Rectangle, score float64, label string) *track {
 	imageBounds := ImageBoundsFromDet(tr.Det)
 	var det objdet.Detection
 	if imageBounds == nil {
-		det = objdet.NewDetectionWithoutImgBounds(*bb, tr.Det.Score(), tr.Det.Label())
+		det = objdet.NewDetectionWithoutImgBounds(*bb, score, label)
 	} else {
-		det = objdet.NewDetection(*imageBounds, *bb, tr.Det.Score(), tr.Det.Label())
+		det = objdet.NewDetection(*imageBounds, *bb, score, label)
 	}
 	newTrack := tr.clone()
 	newTrack.Det = det
 	return newTrack
 }
 
-// RenameFromMatches takes the output of the Hungarian matching algorithm and
-// gives the new detection the same label as the matching old detection.  Any new detections
-// found will be given a new name (and cleass counter will be updated)
-// Also return freshDets that are the fresh detections that were not matched with any detections in the previous frame.
-func (t *myTracker) RenameFromMatches(matches []int, matchinMtx [][]float64, oldDets, newDets []*track) ([]*track, []*track, []*track) {
-	// Fill up a map with the indices of newDetections we have
-	notUsed := make(map[int]struct{})
-	for i := range newDets {
-		notUsed[i] = struct{}{}
-	}
-	// Go through valid matches and update name and track
-	updatedTracks := make([]*track, 0)
-	newlyStableTracks := make([]*track, 0)
-	for oldIdx, newIdx := range matches {
-		if newIdx != -1 {
-			if matchinMtx[oldIdx][newIdx] != 0 {
-				if newIdx >= 0 && newIdx < len(newDets) && oldIdx >= 0 && oldIdx < len(oldDets) {
-					// take the old track, clone it, and update their Bounding Box
-					// to the new track. Increment its persistence counter.
-					updatedTrack, newlyStable := t.UpdateTrack(newDets[newIdx], oldDets[oldIdx])
-					if newlyStable {
-						newlyStableTracks = append(newlyStableTracks, updatedTrack)
-					} else {
-						updatedTracks = append(updatedTracks, updatedTrack)
-					}
-					delete(notUsed, newIdx)
-				}
-			}
-		}
-	}
-	// Go through all NEW things and add them in (name them and start new track)
-	freshTracks := make([]*track, 0)
-	for idx := range notUsed {
-		newDet := t.RenameFirstTime(newDets[idx])
-		newDets[idx] = newDet
-		freshTracks = append(freshTracks, newDet)
-	}
-	return updatedTracks, newlyStableTracks, freshTracks
-}
-
-// RenameFirstTime should activate whenever a new object appears.
-// It will start or update a class counter for whichever class and create a new track.
-func (t *myTracker) RenameFirstTime(det *track) *track {
-	baseLabel := strings.ToLower(strings.Split(det.Det.Label(), "_")[0])
-	classCount, ok := t.classCounter[baseLabel]
+// nextTrackID returns the ID of the next track of the class, which is the class name and the
+// counter of the class. It is how the tracking package names new tracks.
+func (t *myTracker) nextTrackID(class string) string {
+	classCount, ok := t.classCounter[class]
 	if !ok {
-		t.classCounter[baseLabel] = 0
+		t.classCounter[class] = 0
 	} else {
-		t.classCounter[baseLabel] = classCount + 1
+		t.classCounter[class] = classCount + 1
 	}
-	countLabel := baseLabel + "_" + strconv.Itoa(t.classCounter[baseLabel])
-	label := countLabel + "_" + GetTimestamp()
-	out := ReplaceLabel(det, label)
-	// start a new track, but it will be tentative, and may be removed if lost
-	// before persistence counter reaches "stable"
-	t.tracks[countLabel] = []*track{out}
+	return class + "_" + strconv.Itoa(t.classCounter[class])
+}
+
+// RenameFirstTime should activate whenever a new object appears. It labels the detection with the
+// ID the tracking package gave its track, and the time it was first seen.
+func (t *myTracker) RenameFirstTime(det *track, id string) *track {
+	out := ReplaceLabel(det, id+"_"+t.timestamp())
+	out.id = id
+	t.voteClass(out, ClassPolicyLocked)
+	t.observe(out)
 	return out
 }
 
+// getTrackingLabel returns the ID of the track, which is the class name and counter of its label
 func getTrackingLabel(tr *track) string {
+	if tr.id != "" {
+		return tr.id
+	}
 	return strings.Join(strings.Split(tr.Det.Label(), "_")[0:2], "_")
 }
 
-// UpdateTrack changes the old bounding box to the new one, updates persistence,
-// and also returns if the track became newly stable
-func (t *myTracker) UpdateTrack(nextTrack, oldMatchedTrack *track) (*track, bool) {
+// UpdateTrack changes the old bounding box to the new one, marks the track stable as the tracking
+// package decided, and also returns if the track became newly stable
+func (t *myTracker) UpdateTrack(nextTrack, oldMatchedTrack *track, stable bool) (*track, bool) {
 	wasStable := oldMatchedTrack.isStable()
 	newTrack := ReplaceBoundingBox(oldMatchedTrack, nextTrack.Det.BoundingBox())
-	newTrack.addPersistence()
-	countLabel := getTrackingLabel(newTrack)
-	trackSlice, ok := t.tracks[countLabel]
-	if ok {
-		t.tracks[countLabel] = append(trackSlice, newTrack)
+	newTrack.class = nextTrack.class
+	newTrack.score = nextTrack.score
+	newTrack.truncated = nextTrack.truncated
+	if class := t.voteClass(newTrack, t.classPolicy); class != strings.Split(newTrack.Det.Label(), "_")[0] {
+		previous := newTrack
+		newTrack = ReplaceLabel(newTrack, t.classLabel(newTrack, class))
+		if wasStable {
+			t.logClassChange(previous, newTrack)
+		}
 	}
-	isNowStable := newTrack.isStable()
-	newlyStable := wasStable != isNowStable
-	return newTrack, newlyStable
+	newTrack.propagated = false
+	newTrack.stable = stable
+	t.observe(newTrack)
+	return newTrack, stable && !wasStable
 }
 
 // ImageBoundsFromDet returns the image bounds from the detection.
@@ -0,0 +1,359 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the estimation of the global motion of the camera between two frames,
+// used to move the predicted boxes along with the camera before they are matched.
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	// MotionCompensationNone assumes the camera is static.
+	MotionCompensationNone = "none"
+	// MotionCompensationImage estimates the camera motion from the images.
+	MotionCompensationImage = "image"
+	// MotionCompensationMovementSensor derives the camera motion from a movement sensor's angular velocity.
+	MotionCompensationMovementSensor = "movement_sensor"
+)
+
+const (
+	// motionMaxWidth is the width frames are downsampled to before estimating the motion.
+	motionMaxWidth = 320
+	// motionGridCols and motionGridRows split the frame in cells that each contribute one feature.
+	motionGridCols = 8
+	motionGridRows = 6
+	// motionPatchRadius is the half side of the patch matched around each feature.
+	motionPatchRadius = 4
+	// motionSearchRadius is how far (in downsampled pixels) a feature is searched for.
+	motionSearchRadius = 16
+	// minFeatureMatchScore is the lowest correlation for a feature to be considered matched.
+	minFeatureMatchScore = 0.8
+	// ransacIterations is the number of random samples tried when fitting the motion.
+	ransacIterations = 200
+	// ransacThreshold is the largest error (in downsampled pixels) for a match to agree with a motion.
+	ransacThreshold = 1.5
+	// minMotionInliers is the number of matches that must agree on a motion for it to be used.
+	minMotionInliers = 6
+)
+
+// affine is a 2D affine transform mapping (x, y) to (a[0]x + a[1]y + a[2], a[3]x + a[4]y + a[5]).
+type affine [6]float64
+
+// identityAffine is the transform of a static camera.
+var identityAffine = affine{1, 0, 0, 0, 1, 0}
+
+// translation returns the transform that shifts points by (dx, dy).
+func translation(dx, dy float64) affine {
+	return affine{1, 0, dx, 0, 1, dy}
+}
+
+// apply transforms a point.
+func (a affine) apply(x, y float64) (float64, float64) {
+	return a[0]*x + a[1]*y + a[2], a[3]*x + a[4]*y + a[5]
+}
+
+// warpRect returns the bounding box of the transformed corners of r.
+func (a affine) warpRect(r image.Rectangle) image.Rectangle {
+	if a == identityAffine {
+		return r
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, c := range []image.Point{r.Min, {r.Max.X, r.Min.Y}, {r.Min.X, r.Max.Y}, r.Max} {
+		x, y := a.apply(float64(c.X), float64(c.Y))
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	return image.Rect(int(math.Round(minX)), int(math.Round(minY)), int(math.Round(maxX)), int(math.Round(maxY)))
+}
+
+// scaled returns the transform expressed in coordinates that are factor times larger.
+func (a affine) scaled(factor float64) affine {
+	return affine{a[0], a[1], a[2] * factor, a[3], a[4], a[5] * factor}
+}
+
+// pointMatch is a feature seen at from in the previous frame and at to in the current frame.
+type pointMatch struct {
+	fromX, fromY, toX, toY float64
+}
+
+// downsampledGray converts img to grayscale, keeping one pixel every factor pixels.
+// The result starts at (0, 0) whatever the origin of img.
+func downsampledGray(img image.Image, factor int) *grayRegion {
+	b := img.Bounds()
+	r := image.Rect(0, 0, b.Dx()/factor, b.Dy()/factor)
+	g := &grayRegion{rect: r, pix: make([]float64, r.Dx()*r.Dy())}
+	lum := luminance(img)
+	for y := range r.Dy() {
+		for x := range r.Dx() {
+			g.pix[y*r.Dx()+x] = lum(b.Min.X+x*factor, b.Min.Y+y*factor)
+		}
+	}
+	return g
+}
+
+// estimateCameraMotion returns the affine transform that maps points of prev to where they are in
+// curr. Features inside the ignored boxes (the tracked objects, which move on their own) are not used.
+// It returns false when not enough features agree on a motion.
+func estimateCameraMotion(prev, curr image.Image, ignored []image.Rectangle) (affine, bool) {
+	if prev.Bounds() != curr.Bounds() {
+		return identityAffine, false
+	}
+	factor := max(1, (prev.Bounds().Dx()+motionMaxWidth-1)/motionMaxWidth)
+	prevGray := downsampledGray(prev, factor)
+	currGray := downsampledGray(curr, factor)
+	scaledIgnored := make([]image.Rectangle, len(ignored))
+	for i, r := range ignored {
+		r = r.Sub(prev.Bounds().Min)
+		scaledIgnored[i] = image.Rect(r.Min.X/factor, r.Min.Y/factor, r.Max.X/factor, r.Max.Y/factor)
+	}
+
+	var matches []pointMatch
+	for _, p := range findFeatures(prevGray, scaledIgnored) {
+		patch := image.Rect(p.X-motionPatchRadius, p.Y-motionPatchRadius, p.X+motionPatchRadius+1, p.Y+motionPatchRadius+1)
+		found, score := matchPatch(prevGray, currGray, patch)
+		if score >= minFeatureMatchScore {
+			matches = append(matches, pointMatch{
+				float64(p.X), float64(p.Y),
+				float64(p.X + found.X), float64(p.Y + found.Y),
+			})
+		}
+	}
+	motion, ok := fitAffineRANSAC(matches, rand.New(rand.NewSource(int64(len(matches)))))
+	if !ok {
+		return identityAffine, false
+	}
+	// back to full resolution, and to the image origin
+	motion = motion.scaled(float64(factor))
+	o := prev.Bounds().Min
+	ox, oy := motion.apply(float64(-o.X), float64(-o.Y))
+	motion[2], motion[5] = ox+float64(o.X), oy+float64(o.Y)
+	return motion, true
+}
+
+// findFeatures returns the strongest corner of each cell of a grid laid over g, using the
+// minimum eigenvalue of the structure tensor (Shi-Tomasi) as the corner strength.
+func findFeatures(g *grayRegion, ignored []image.Rectangle) []image.Point {
+	border := motionPatchRadius + motionSearchRadius + 1
+	inner := g.rect.Inset(border)
+	if inner.Empty() {
+		return nil
+	}
+	var features []image.Point
+	cellW, cellH := max(1, inner.Dx()/motionGridCols), max(1, inner.Dy()/motionGridRows)
+	for cy := inner.Min.Y; cy+cellH <= inner.Max.Y; cy += cellH {
+		for cx := inner.Min.X; cx+cellW <= inner.Max.X; cx += cellW {
+			best, bestScore := image.Point{}, 0.0
+			for y := cy; y < cy+cellH; y += 2 {
+				for x := cx; x < cx+cellW; x += 2 {
+					p := image.Pt(x, y)
+					if insideAny(p, ignored) {
+						continue
+					}
+					if score := cornerStrength(g, p); score > bestScore {
+						best, bestScore = p, score
+					}
+				}
+			}
+			// flat cells have no reliable feature
+			if bestScore > 100 {
+				features = append(features, best)
+			}
+		}
+	}
+	return features
+}
+
+func insideAny(p image.Point, rects []image.Rectangle) bool {
+	for _, r := range rects {
+		if p.In(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// cornerStrength returns the smallest eigenvalue of the structure tensor around p.
+func cornerStrength(g *grayRegion, p image.Point) float64 {
+	var sxx, syy, sxy float64
+	for y := p.Y - 2; y <= p.Y+2; y++ {
+		for x := p.X - 2; x <= p.X+2; x++ {
+			dx := (g.at(x+1, y) - g.at(x-1, y)) / 2
+			dy := (g.at(x, y+1) - g.at(x, y-1)) / 2
+			sxx += dx * dx
+			syy += dy * dy
+			sxy += dx * dy
+		}
+	}
+	trace, det := sxx+syy, sxx*syy-sxy*sxy
+	return trace/2 - math.Sqrt(math.Max(trace*trace/4-det, 0))
+}
+
+// matchPatch looks for the patch of prev within motionSearchRadius in curr, and returns
+// its displacement and correlation.
+func matchPatch(prev, curr *grayRegion, patch image.Rectangle) (image.Point, float64) {
+	tmpl := newTemplate(prev, patch, 1)
+	best, bestScore := image.Point{}, math.Inf(-1)
+	for dy := -motionSearchRadius; dy <= motionSearchRadius; dy++ {
+		for dx := -motionSearchRadius; dx <= motionSearchRadius; dx++ {
+			d := image.Pt(dx, dy)
+			if !patch.Add(d).In(curr.rect) {
+				continue
+			}
+			if score := tmpl.ncc(curr, patch.Min.Add(d)); score > bestScore {
+				best, bestScore = d, score
+			}
+		}
+	}
+	return best, bestScore
+}
+
+// fitAffineRANSAC finds the affine transform most matches agree with, and refines it with a least
+// squares fit on those matches.
+func fitAffineRANSAC(matches []pointMatch, rng *rand.Rand) (affine, bool) {
+	if len(matches) < minMotionInliers {
+		return identityAffine, false
+	}
+	var bestInliers []pointMatch
+	sample := make([]pointMatch, 3)
+	for range ransacIterations {
+		for k, idx := range rng.Perm(len(matches))[:3] {
+			sample[k] = matches[idx]
+		}
+		candidate, ok := fitAffine(sample)
+		if !ok {
+			continue
+		}
+		inliers := affineInliers(candidate, matches)
+		if len(inliers) > len(bestInliers) {
+			bestInliers = inliers
+		}
+	}
+	if len(bestInliers) < minMotionInliers {
+		return identityAffine, false
+	}
+	return fitAffine(bestInliers)
+}
+
+func affineInliers(a affine, matches []pointMatch) []pointMatch {
+	var inliers []pointMatch
+	for _, m := range matches {
+		x, y := a.apply(m.fromX, m.fromY)
+		if math.Hypot(x-m.toX, y-m.toY) <= ransacThreshold {
+			inliers = append(inliers, m)
+		}
+	}
+	return inliers
+}
+
+// fitAffine returns the least squares affine transform for at least 3 matches.
+func fitAffine(matches []pointMatch) (affine, bool) {
+	// both rows of the transform share the same normal equations
+	var ata [3][3]float64
+	var atbx, atby [3]float64
+	for _, m := range matches {
+		row := [3]float64{m.fromX, m.fromY, 1}
+		for i := range 3 {
+			for j := range 3 {
+				ata[i][j] += row[i] * row[j]
+			}
+			atbx[i] += row[i] * m.toX
+			atby[i] += row[i] * m.toY
+		}
+	}
+	rx, ok := solve3(ata, atbx)
+	if !ok {
+		return identityAffine, false
+	}
+	ry, ok := solve3(ata, atby)
+	if !ok {
+		return identityAffine, false
+	}
+	return affine{rx[0], rx[1], rx[2], ry[0], ry[1], ry[2]}, true
+}
+
+// solve3 solves the 3x3 linear system m x = b with Gaussian elimination.
+func solve3(m [3][3]float64, b [3]float64) ([3]float64, bool) {
+	for col := range 3 {
+		pivot := col
+		for r := col + 1; r < 3; r++ {
+			if math.Abs(m[r][col]) > math.Abs(m[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-9 {
+			return [3]float64{}, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		for r := col + 1; r < 3; r++ {
+			f := m[r][col] / m[col][col]
+			for c := col; c < 3; c++ {
+				m[r][c] -= f * m[col][c]
+			}
+			b[r] -= f * b[col]
+		}
+	}
+	var x [3]float64
+	for r := 2; r >= 0; r-- {
+		sum := b[r]
+		for c := r + 1; c < 3; c++ {
+			sum -= m[r][c] * x[c]
+		}
+		x[r] = sum / m[r][r]
+	}
+	return x, true
+}
+
+// motionFromAngularVelocity converts the rotation of the camera over dt into a shift of the image.
+// Turning left (positive yaw) moves the scene right, and pitching up moves it down.
+func motionFromAngularVelocity(yawDegPerSec, pitchDegPerSec float64, dt time.Duration, pixelsPerDegree float64) affine {
+	secs := dt.Seconds()
+	return translation(yawDegPerSec*secs*pixelsPerDegree, pitchDegPerSec*secs*pixelsPerDegree)
+}
+
+// estimateMotion returns the camera motion between the previous and the current frame, using the
+// configured source. It returns the identity when the motion cannot be estimated.
+func (t *myTracker) estimateMotion(ctx context.Context, prev, curr image.Image, dt time.Duration) affine {
+	switch t.motionCompensation {
+	case MotionCompensationImage:
+		if prev == nil {
+			return identityAffine
+		}
+		ignored := make([]image.Rectangle, 0, len(t.lastDetections))
+		for _, tr := range t.lastDetections {
+			ignored = append(ignored, *tr.Det.BoundingBox())
+		}
+		motion, ok := estimateCameraMotion(prev, curr, ignored)
+		if !ok {
+			t.logger.Debug("could not estimate the camera motion, assuming it is static")
+		}
+		return motion
+	case MotionCompensationMovementSensor:
+		angVel, err := t.movementSensor.AngularVelocity(ctx, nil)
+		if err != nil {
+			t.logger.Errorf("can't get angular velocity. got err: %s", err)
+			return identityAffine
+		}
+		pixelsPerDegree := float64(curr.Bounds().Dx()) / t.cameraFOV
+		return motionFromAngularVelocity(angVel.Z, angVel.Y, dt, pixelsPerDegree)
+	default:
+		return identityAffine
+	}
+}
+
+// compensateMotion moves the box and the history of every track by the camera motion of this
+// frame. The history is kept in the coordinates of the latest frame, so that the velocity of a track
+// does not include the motion of the camera.
+func (t *myTracker) compensateMotion(motion affine) {
+	if motion == identityAffine {
+		t.motion = nil
+		return
+	}
+	t.motion = &motion
+	t.tracks.Warp(motion.warpRect)
+}
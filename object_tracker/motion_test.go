@@ -0,0 +1,154 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+// sceneFrame returns a frame of a textured scene, seen by a camera that moved by (dx, dy) pixels.
+func sceneFrame(bounds image.Rectangle, dx, dy int) *image.Gray {
+	img := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// a hash of the scene coordinates, so the texture moves with the scene
+			sx, sy := uint32(x-dx+1000), uint32(y-dy+1000)
+			h := sx*73856093 ^ sy*19349663
+			h ^= h >> 13
+			h *= 0x5bd1e995
+			img.SetGray(x, y, color.Gray{uint8(h >> 24)})
+		}
+	}
+	return img
+}
+
+func TestAffine(t *testing.T) {
+	a := translation(3, -2)
+	x, y := a.apply(10, 10)
+	test.That(t, x, test.ShouldEqual, 13)
+	test.That(t, y, test.ShouldEqual, 8)
+	test.That(t, a.warpRect(image.Rect(0, 0, 10, 20)), test.ShouldResemble, image.Rect(3, -2, 13, 18))
+	test.That(t, identityAffine.warpRect(image.Rect(1, 2, 3, 4)), test.ShouldResemble, image.Rect(1, 2, 3, 4))
+
+	// a zoom keeps the box around the same center
+	zoom := affine{2, 0, -50, 0, 2, -50}
+	test.That(t, zoom.warpRect(image.Rect(40, 40, 60, 60)), test.ShouldResemble, image.Rect(30, 30, 70, 70))
+}
+
+func TestFitAffineRANSAC(t *testing.T) {
+	want := affine{1.01, 0.02, 5, -0.02, 0.99, -3}
+	rng := rand.New(rand.NewSource(3))
+	var matches []pointMatch
+	for range 40 {
+		x, y := rng.Float64()*300, rng.Float64()*200
+		nx, ny := want.apply(x, y)
+		matches = append(matches, pointMatch{x, y, nx, ny})
+	}
+	// objects moving on their own do not agree with the camera motion
+	for range 15 {
+		x, y := rng.Float64()*300, rng.Float64()*200
+		matches = append(matches, pointMatch{x, y, x + 20 + rng.Float64()*10, y - 15})
+	}
+	got, ok := fitAffineRANSAC(matches, rand.New(rand.NewSource(1)))
+	test.That(t, ok, test.ShouldBeTrue)
+	for i := range got {
+		test.That(t, got[i], test.ShouldAlmostEqual, want[i], 1e-6)
+	}
+
+	_, ok = fitAffineRANSAC(matches[:3], rand.New(rand.NewSource(1)))
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestEstimateCameraMotion(t *testing.T) {
+	bounds := image.Rect(0, 0, 240, 180)
+	prev := sceneFrame(bounds, 0, 0)
+	curr := sceneFrame(bounds, 7, -4)
+	motion, ok := estimateCameraMotion(prev, curr, nil)
+	test.That(t, ok, test.ShouldBeTrue)
+	x, y := motion.apply(100, 100)
+	test.That(t, x, test.ShouldAlmostEqual, 107, 0.5)
+	test.That(t, y, test.ShouldAlmostEqual, 96, 0.5)
+
+	// large frames are downsampled, and the motion is scaled back to full resolution
+	bounds = image.Rect(0, 0, 960, 540)
+	motion, ok = estimateCameraMotion(sceneFrame(bounds, 0, 0), sceneFrame(bounds, 12, 6), nil)
+	test.That(t, ok, test.ShouldBeTrue)
+	x, y = motion.apply(500, 300)
+	test.That(t, x, test.ShouldAlmostEqual, 512, 3)
+	test.That(t, y, test.ShouldAlmostEqual, 306, 3)
+
+	// a flat frame has no features
+	_, ok = estimateCameraMotion(image.NewGray(bounds), image.NewGray(bounds), nil)
+	test.That(t, ok, test.ShouldBeFalse)
+}
+
+func TestMotionFromAngularVelocity(t *testing.T) {
+	motion := motionFromAngularVelocity(10, -5, 500*time.Millisecond, 8)
+	test.That(t, motion, test.ShouldResemble, translation(40, -20))
+}
+
+func TestMotionCompensatedStep(t *testing.T) {
+	bounds := image.Rect(0, 0, 240, 180)
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+		motionCompensation:  MotionCompensationImage,
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	box := image.Rect(50, 50, 60, 60)
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box, 0.9, LabelDet0)}))
+	label := fakeTracker.lastDetections[0].Det.Label()
+
+	// the camera pans by more than the size of the object, which did not move in the scene
+	prev, curr := sceneFrame(bounds, 0, 0), sceneFrame(bounds, 14, 0)
+	fakeTracker.compensateMotion(fakeTracker.estimateMotion(context.Background(), prev, curr, 100*time.Millisecond))
+	test.That(t, fakeTracker.motion, test.ShouldNotBeNil)
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box.Add(image.Pt(14, 0)), 0.9, LabelDet0)}))
+	test.That(t, len(fakeTracker.lastDetections), test.ShouldEqual, 1)
+	test.That(t, fakeTracker.lastDetections[0].Det.Label(), test.ShouldEqual, label)
+
+	// without motion, predictions are left as they are
+	fakeTracker.compensateMotion(identityAffine)
+	test.That(t, fakeTracker.motion, test.ShouldBeNil)
+}
+
+func TestStaticObjectUnderSteadyPan(t *testing.T) {
+	bounds := image.Rect(0, 0, 240, 180)
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+		motionCompensation:  MotionCompensationImage,
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	// the camera pans by 8 pixels every frame, so the object moves by 8 pixels in the image
+	// while it does not move in the scene
+	pan := translation(8, 0)
+	at := func(frame int) image.Rectangle {
+		return image.Rect(50, 50, 60, 60).Add(image.Pt(8*frame, 0))
+	}
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, at(0), 0.9, LabelDet0)}))
+	label := fakeTracker.lastDetections[0].Det.Label()
+	for frame := 1; frame < 8; frame++ {
+		fakeTracker.compensateMotion(pan)
+		// the pan is counted once, in the motion of the camera and not in the velocity of the track
+		test.That(t, fakeTracker.predictBox(fakeTracker.lastDetections[0]), test.ShouldResemble, at(frame))
+		fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, at(frame), 0.9, LabelDet0)}))
+		test.That(t, len(fakeTracker.lastDetections), test.ShouldEqual, 1)
+		test.That(t, fakeTracker.lastDetections[0].Det.Label(), test.ShouldEqual, label)
+	}
+}
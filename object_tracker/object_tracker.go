@@ -4,6 +4,7 @@ package object_tracker
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -13,9 +14,10 @@ import (
 
 	"image"
 
-	hg "github.com/charles-haynes/munkres"
 	"github.com/pkg/errors"
+	"github.com/viam-modules/object-tracking/tracking"
 	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/components/movementsensor"
 	"go.viam.com/rdk/logging"
 	"go.viam.com/rdk/resource"
 	"go.viam.com/rdk/services/vision"
@@ -40,6 +42,7 @@ var (
 	DefaultMaxFrequency        = 10.0
 	DefaultTriggerCoolDown     = 5.0
 	DefaultBufferSize          = 30
+	DefaultMaxTrackHistory     = 30
 )
 
 type allObjects struct {
@@ -50,6 +53,12 @@ type allObjects struct {
 type currentDetections struct {
 	mutex      sync.RWMutex
 	detections []*track
+	// raw holds the tracks before smoothing
+	raw []*track
+	// propagated is true when the detections were propagated from the previous frame
+	propagated bool
+	// trails holds the centers of the history of each stable track, oldest first, by label
+	trails map[string][][2]float64
 }
 
 func init() {
@@ -68,10 +77,15 @@ type myTracker struct {
 	triggerContext    context.Context
 
 	activeBackgroundWorkers sync.WaitGroup
-	lastDetections          []*track
-	currDetections          currentDetections
-	currImg                 atomic.Pointer[image.Image]
-	lostDetectionsBuffer    *tracksBuffer
+	// lastDetections holds the tracks detected on the latest frame
+	lastDetections []*track
+	currDetections currentDetections
+	currImg        atomic.Pointer[image.Image]
+	// tracks keeps every track, including the lost ones, and matches them with new detections
+	tracks *tracking.Tracker
+	// lastSeen holds the latest presentation of every track of tracks, by ID
+	lastSeen map[string]*track
+	frame    int
 
 	allFreshObjects allObjects
 
@@ -86,17 +100,71 @@ type myTracker struct {
 	minConfidence       float64
 	chosenLabels        map[string]float64
 	classCounter        map[string]int
-	tracks              map[string][]*track
-	timeStats           []time.Duration
+	timeStats           *latencyHistogram
 	minTrackPersistence int
+	maxTrackHistory     int
+	bufferSize          int
+
+	pipelined         bool
+	pipelineQueueSize int
+	pipelineStats     pipelineStats
+
+	detectEveryNFrames int
+	propagationRadius  int
+
+	motionCompensation string
+	movementSensor     movementsensor.MovementSensor
+	cameraFOV          float64
+	// motion is the camera motion since the previous frame, nil if the camera did not move
+	motion *affine
+
+	directionWeight float64
+	coastFrames     int
+
+	smoothing smoothing
+	// smoothers holds the smoothed values of each track, nil when nothing is smoothed
+	smoothers map[string]*trackSmoother
+
+	classPolicy string
+	// classVotes holds the class histogram of each track
+	classVotes map[string]*classVotes
+
+	regions      *regionFilter
+	boxFilters   BoxFilters
+	labelAliases map[string]string
+	duplicates   DuplicateSuppression
+
+	// ensemble is set instead of detector when several detectors are configured
+	ensemble *ensemble
+	tiling   *tiling
+
+	mode string
+	// conf and deps are kept to configure the trackers of new sessions
+	conf resource.Config
+	deps resource.Dependencies
+	// sessions holds the track state of each session but the default one, in on demand mode
+	sessions      map[string]*myTracker
+	sessionsMutex sync.Mutex
+	maxSessions   int
+	// lastUsed is when the session was last looked up, guarded by the sessionsMutex of its tracker
+	lastUsed time.Time
+	// stepMutex serializes the on demand steps of the session
+	stepMutex sync.Mutex
+	lastStep  time.Time
+	// frameTime is the time the frame being tracked was captured, zero for now
+	frameTime time.Time
+
+	// recorder is nil unless recording is configured, and shared with the sessions
+	recorder    *recorder
+	sessionName string
 }
 
-func newTracker(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (vision.Service, error) {
-	t := &myTracker{
-		Named:        conf.ResourceName().AsNamed(),
+// newTrackerState returns a tracker without any tracks, before it is configured.
+func newTrackerState(named resource.Named, logger logging.Logger) *myTracker {
+	return &myTracker{
+		Named:        named,
 		logger:       logger,
 		classCounter: make(map[string]int),
-		tracks:       make(map[string][]*track),
 		properties: vision.Properties{
 			ClassificationSupported: true,
 			DetectionSupported:      true,
@@ -106,16 +174,16 @@ func newTracker(ctx context.Context, deps resource.Dependencies, conf resource.C
 			objects: []trackedObject{},
 		},
 		currDetections: currentDetections{},
+		timeStats:      newLatencyHistogram(),
 	}
+}
 
+func newTracker(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (vision.Service, error) {
+	t := newTrackerState(conf.ResourceName().AsNamed(), logger)
 	if err := t.Reconfigure(ctx, deps, conf); err != nil {
 		return nil, err
 	}
 
-	//Default value for persistence
-	if t.minTrackPersistence == 0 {
-		t.minTrackPersistence = DefaultMinTrackPersistence
-	}
 	// Default value for frequency = 10Hz
 	if t.frequency == 0 {
 		t.frequency = DefaultMaxFrequency
@@ -125,58 +193,30 @@ func newTracker(ctx context.Context, deps resource.Dependencies, conf resource.C
 	t.cancelFunc = cancel
 	t.cancelContext = cancelableCtx
 
+	// in on demand and push modes, the tracker only moves when it is given images or detections
+	if t.mode == ModeOnDemand || t.mode == ModePush {
+		return t, nil
+	}
+
 	// Do the first pass to populate the first set of 2 detections.
-	starterDets := make([][]*track, 2)
-	for i := range 2 {
+	for range 2 {
 		img, err := camera.DecodeImageFromCamera(cancelableCtx, t.cam, nil, nil)
 		if err != nil {
 			return nil, err
 		}
-		detections, err := t.detector.Detections(ctx, img, nil)
+		detections, err := t.detect(ctx, img)
 		if err != nil {
 			return nil, err
 		}
-		filteredDets := FilterDetections(t.chosenLabels, detections, t.minConfidence)
-		tracks := newTracks(filteredDets, t.minTrackPersistence)
-		starterDets[i] = tracks
-	}
-	filteredOld := starterDets[0]
-	filteredNew := starterDets[1]
-	// Rename (from scratch)
-	renamedOld := make([]*track, 0, len(filteredOld))
-	for _, det := range filteredOld {
-		newDet := t.RenameFirstTime(det)
-		renamedOld = append(renamedOld, newDet)
-	}
-	// Build and solve cost matrix via Munkres' method
-	matchMtx := t.BuildMatchingMatrix(renamedOld, filteredNew)
-	HA, err := hg.NewHungarianAlgorithm(matchMtx)
-	if err != nil {
-		return nil, err
-	}
-	matches := HA.Execute()
-	var lostDetections []*track
-	for idx := range matches {
-		if matches[idx] == -1 {
-			// if lost detection is not stable, discard it
-			if renamedOld[idx].isStable() {
-				lostDetections = append(lostDetections, renamedOld[idx])
-			}
-		}
+		t.recordFrame(time.Now(), img, img.Bounds(), detections)
+		t.step(newTracks(t.filterDetections(detections, img.Bounds())))
 	}
-	t.lostDetectionsBuffer.AppendDets(lostDetections)
 
-	// Rename from temporal matches. New det copies old det's label
-	renamedNew, newlyStable, _ := t.RenameFromMatches(matches, matchMtx, renamedOld, filteredNew)
-	if len(newlyStable) > 0 {
-		t.trigger()
+	if t.pipelined {
+		t.pipelineStats = newPipelineStats()
+		t.startPipeline(t.cancelContext)
+		return t, nil
 	}
-	renamedNew = append(renamedNew, newlyStable...)
-	t.lastDetections = renamedNew
-	t.currDetections.mutex.Lock()
-	t.currDetections.detections = renamedNew
-	t.currDetections.mutex.Unlock()
-
 	t.activeBackgroundWorkers.Add(1)
 	viamutils.ManagedGo(func() {
 		t.run(t.cancelContext)
@@ -191,6 +231,9 @@ func newTracker(ctx context.Context, deps resource.Dependencies, conf resource.C
 // run is a (cancelable) infinite loop that takes new detections from the camera and compares them to
 // the most recently seen detections. Matching detections are linked via matching labels.
 func (t *myTracker) run(cancelableCtx context.Context) {
+	// number of frames since the detector last ran
+	sinceDetection := 0
+	var lastFrame time.Time
 	for {
 		select {
 		case <-cancelableCtx.Done():
@@ -207,67 +250,31 @@ func (t *myTracker) run(cancelableCtx context.Context) {
 				t.logger.Errorf("got nil image")
 				continue
 			}
-			detections, err := t.detector.Detections(cancelableCtx, img, nil)
-			if err != nil {
-				t.logger.Errorf("can't get detections. got err: %s", err)
-				continue
-			}
-			filteredDets := FilterDetections(t.chosenLabels, detections, t.minConfidence)
-			// all new tracks get a fresh persistence counter
-			filteredNew := newTracks(filteredDets, t.minTrackPersistence)
-
-			// Store oldDetection and lost detections in allDetections
-			allDetections := t.lastDetections
-			for _, dets := range t.lostDetectionsBuffer.detections {
-				allDetections = append(allDetections, dets...)
-			}
-			// Build and solve cost matrix via Munkres' method
-			matchMtx := t.BuildMatchingMatrix(allDetections, filteredNew)
-			HA, _ := hg.NewHungarianAlgorithm(matchMtx)
-			matches := HA.Execute()
-			// Store the lost detections in the buffer, drop lost detections
-			// if they were not considered stable
-			var lostDetections []*track
-			for idx := range t.lastDetections {
-				if matches[idx] == -1 {
-					if t.lastDetections[idx].isStable() {
-						lostDetections = append(lostDetections, t.lastDetections[idx])
-					} else {
-						// drop lost detections from track list as well
-						countLabel := getTrackingLabel(t.lastDetections[idx])
-						delete(t.tracks, countLabel)
-					}
-				}
+			prevImg := t.currImg.Load()
+			if prevImg != nil {
+				t.compensateMotion(t.estimateMotion(cancelableCtx, *prevImg, img, start.Sub(lastFrame)))
 			}
-			t.lostDetectionsBuffer.AppendDets(lostDetections)
-			// Returns a new set of detections, from matching allDetections with the filteredNew
-			// All three outputs must be summed together to get the full set of new detections
-			renamedNew, newlyStable, freshDets := t.RenameFromMatches(matches, matchMtx, allDetections, filteredNew)
-			if len(newlyStable) > 0 {
-				//trigger classification and schedule "untrigger"
-				t.trigger()
-
-				// add the detections to the logs
-				t.allFreshObjects.mutex.Lock()
-				for _, det := range newlyStable {
-					to, err := newTrackedObjectFromLabel(det.Det.Label())
-					if err != nil {
-						t.logger.Error(err)
-					}
-					t.allFreshObjects.objects = append(t.allFreshObjects.objects, to)
+			lastFrame = start
+			sinceDetection++
+			if sinceDetection < t.detectEveryNFrames && prevImg != nil {
+				// follow the tracks from the previous image instead of running the detector
+				t.recordPropagatedFrame(start, img)
+				t.propagateStep(*prevImg, img)
+			} else {
+				detections, err := t.detect(cancelableCtx, img)
+				if err != nil {
+					t.logger.Errorf("can't get detections. got err: %s", err)
+					continue
 				}
-				t.allFreshObjects.mutex.Unlock()
+				t.recordFrame(start, img, img.Bounds(), detections)
+				filteredDets := t.filterDetections(detections, img.Bounds())
+				t.step(newTracks(filteredDets))
+				sinceDetection = 0
 			}
-			renamedNew = append(renamedNew, newlyStable...)
-			renamedNew = append(renamedNew, freshDets...)
-			t.lastDetections = renamedNew
-			t.currDetections.mutex.Lock()
-			t.currDetections.detections = renamedNew
-			t.currDetections.mutex.Unlock()
 			t.currImg.Store(&img)
 
 			took := time.Since(start)
-			t.timeStats = append(t.timeStats, took)
+			t.timeStats.Record(took)
 			waitFor := time.Duration((1/t.frequency)*float64(time.Second)) - took
 			if waitFor > time.Microsecond {
 				select {
@@ -280,6 +287,124 @@ func (t *myTracker) run(cancelableCtx context.Context) {
 	}
 }
 
+// step matches a fresh set of tracks with the tracks of the tracking package, which include the
+// lost tracks. Matching tracks are linked via matching labels, and the tracker state is updated in
+// place.
+func (t *myTracker) step(filteredNew []*track) {
+	dets := make([]tracking.Detection, 0, len(filteredNew))
+	for _, tr := range filteredNew {
+		tr.truncated = t.boxFilters.Truncated(tr.Det)
+		dets = append(dets, tracking.Detection{
+			Box:       *tr.Det.BoundingBox(),
+			Class:     strings.ToLower(strings.Split(tr.Det.Label(), "_")[0]),
+			Score:     tr.score,
+			Truncated: tr.truncated,
+		})
+	}
+	// Match overlapping tracks, solving each group of overlapping tracks separately. A pair that
+	// costs 0 is never a match, so a track is not handed a box it does not overlap just because
+	// the assignment had nothing better for it.
+	matched := t.tracks.Update(dets, t.frameTime)
+	renamedNew := make([]*track, 0, len(matched))
+	newlyStable := make([]*track, 0)
+	for _, et := range matched {
+		next := filteredNew[et.Detection]
+		var tr *track
+		if old, ok := t.lastSeen[et.ID]; ok {
+			var becameStable bool
+			tr, becameStable = t.UpdateTrack(next, old, et.Stable)
+			if becameStable {
+				newlyStable = append(newlyStable, tr)
+			}
+		} else {
+			// a new track, which is tentative, and is dropped if lost before it is stable
+			tr = t.RenameFirstTime(next, et.ID)
+		}
+		t.lastSeen[et.ID] = tr
+		renamedNew = append(renamedNew, tr)
+	}
+	if len(newlyStable) > 0 {
+		//trigger classification and schedule "untrigger"
+		t.trigger()
+
+		// add the detections to the logs
+		t.allFreshObjects.mutex.Lock()
+		for _, det := range newlyStable {
+			if t.smoothing.logs {
+				det = t.smoothedTrack(det)
+			}
+			to, err := newTrackedObjectFromLabel(det.Det.Label())
+			if err != nil {
+				t.logger.Error(err)
+			}
+			t.allFreshObjects.objects = append(t.allFreshObjects.objects, to)
+		}
+		t.allFreshObjects.mutex.Unlock()
+	}
+	t.lastDetections = renamedNew
+	t.publish(renamedNew, false)
+	t.advanceFrame()
+}
+
+// publish makes the tracks of the current frame available to the API, along with the lost tracks
+// that are still coasting.
+func (t *myTracker) publish(tracks []*track, propagated bool) {
+	if t.coastFrames > 0 {
+		tracks = append(slices.Clip(tracks), t.coastingTracks()...)
+	}
+	out := tracks
+	if t.smoothing.detections {
+		out = t.smoothedTracks(tracks)
+	}
+	trails := t.trails(out)
+	t.currDetections.mutex.Lock()
+	t.currDetections.detections = out
+	t.currDetections.raw = tracks
+	t.currDetections.propagated = propagated
+	t.currDetections.trails = trails
+	t.currDetections.mutex.Unlock()
+}
+
+// forgetTrack removes the presentation and smoothed values of a track.
+func (t *myTracker) forgetTrack(id string) {
+	delete(t.lastSeen, id)
+	delete(t.smoothers, id)
+	delete(t.classVotes, id)
+}
+
+// getTruncatedLabels returns the labels of the stable tracks whose box touches the image border.
+func getTruncatedLabels(tracks []*track) []string {
+	labels := make([]string, 0)
+	for _, tr := range tracks {
+		if tr.stable && tr.truncated {
+			labels = append(labels, tr.Det.Label())
+		}
+	}
+	return labels
+}
+
+// getCoastingLabels returns the labels of the coasting tracks.
+func getCoastingLabels(tracks []*track) []string {
+	labels := make([]string, 0)
+	for _, tr := range tracks {
+		if tr.stable && tr.coasting {
+			labels = append(labels, tr.Det.Label())
+		}
+	}
+	return labels
+}
+
+// advanceFrame moves the tracker to the next frame, and forgets the tracks that the tracking
+// package dropped, because they were lost before they were stable or for too long.
+func (t *myTracker) advanceFrame() {
+	for id := range t.lastSeen {
+		if _, ok := t.tracks.Track(id); !ok {
+			t.forgetTrack(id)
+		}
+	}
+	t.frame++
+}
+
 func (t *myTracker) trigger() {
 	if t.triggerCancelFunc != nil {
 		t.triggerCancelFunc()
@@ -291,14 +416,17 @@ func (t *myTracker) trigger() {
 	t.newInstance.Store(true)
 	t.activeBackgroundWorkers.Add(1)
 
+	// the worker only uses what it is given here, since the next trigger replaces t.triggerContext,
+	// and a reconfiguration t.coolDown
+	coolDown := time.Duration(t.coolDown * float64(time.Second))
 	viamutils.ManagedGo(
 		func() {
-			coolDownTimer := time.After(time.Duration(t.coolDown * float64(time.Second)))
+			coolDownTimer := time.After(coolDown)
 			select {
 			case <-coolDownTimer:
 				t.newInstance.Store(false)
 				return
-			case <-t.triggerContext.Done():
+			case <-triggerContext.Done():
 				return
 			}
 		},
@@ -317,6 +445,46 @@ type Config struct {
 	TriggerCoolDown     *float64           `json:"trigger_cool_down_s,omitempty"`
 	BufferSize          int                `json:"buffer_size,omitempty"`
 	MinTrackPersistence int                `json:"min_track_persistence"`
+	MaxTrackHistory     int                `json:"max_track_history,omitempty"`
+	Pipelined           bool               `json:"pipelined,omitempty"`
+	PipelineQueueSize   int                `json:"pipeline_queue_size,omitempty"`
+	DetectEveryNFrames  int                `json:"detect_every_n_frames,omitempty"`
+	PropagationRadius   int                `json:"propagation_radius_px,omitempty"`
+	MotionCompensation  string             `json:"motion_compensation,omitempty"`
+	MovementSensorName  string             `json:"movement_sensor_name,omitempty"`
+	CameraFOV           float64            `json:"camera_horizontal_fov_deg,omitempty"`
+	DirectionWeight     *float64           `json:"direction_consistency_weight,omitempty"`
+	CoastFrames         int                `json:"coast_frames,omitempty"`
+	BoxSmoothing        string             `json:"box_smoothing,omitempty"`
+	SmoothingAlpha      *float64           `json:"smoothing_alpha,omitempty"`
+	SmoothScores        bool               `json:"smooth_scores,omitempty"`
+	MajorityVoteLabels  bool               `json:"majority_vote_labels,omitempty"`
+	SmoothedOutputs     []string           `json:"smoothed_outputs,omitempty"`
+	ClassPolicy         string             `json:"class_policy,omitempty"`
+	IncludeRegions      [][][]float64      `json:"include_regions,omitempty"`
+	ExcludeRegions      [][][]float64      `json:"exclude_regions,omitempty"`
+	RegionRule          string             `json:"region_rule,omitempty"`
+	RegionMinOverlap    *float64           `json:"region_min_overlap,omitempty"`
+	CropToRegions       bool               `json:"crop_to_regions,omitempty"`
+	BoxFilters          BoxFilters         `json:"box_filters,omitempty"`
+	LabelAliases        map[string]string  `json:"label_aliases,omitempty"`
+	Duplicates          string             `json:"duplicate_suppression,omitempty"`
+	DuplicateIOU        *float64           `json:"duplicate_iou_threshold,omitempty"`
+	DuplicateClass      string             `json:"duplicate_class_policy,omitempty"`
+	DetectorNames       []string           `json:"detector_names,omitempty"`
+	DetectorLabels      DetectorLabels     `json:"detector_labels,omitempty"`
+	EnsembleFusion      string             `json:"ensemble_fusion,omitempty"`
+	TileSize            int                `json:"tile_size_px,omitempty"`
+	TileOverlap         *float64           `json:"tile_overlap,omitempty"`
+	TileFullFrame       bool               `json:"tile_full_frame,omitempty"`
+	TileMergeThreshold  *float64           `json:"tile_merge_threshold,omitempty"`
+	Mode                string             `json:"mode,omitempty"`
+	RecordingDir        string             `json:"recording_dir,omitempty"`
+	RecordOnStart       bool               `json:"record_on_start,omitempty"`
+	RecordImages        bool               `json:"record_images,omitempty"`
+	RecordingMaxFrames  int                `json:"recording_max_frames,omitempty"`
+	RecordingMaxFiles   int                `json:"recording_max_files,omitempty"`
+	MaxSessions         int                `json:"max_sessions,omitempty"`
 }
 
 // Validate validates the config and returns implicit dependencies,
@@ -325,24 +493,191 @@ func (cfg *Config) Validate(path string) ([]string, []string, error) {
 	if cfg.MinTrackPersistence < 0 {
 		return nil, nil, errors.New("attribute min_track_persistence cannot be less than 0")
 	}
-	// this makes them required for the model to successfully build
-	if cfg.CameraName == "" {
+	if cfg.MaxTrackHistory < 0 || cfg.MaxTrackHistory == 1 {
+		return nil, nil, errors.New("attribute max_track_history must be at least 2")
+	}
+	if cfg.PipelineQueueSize < 0 {
+		return nil, nil, errors.New("attribute pipeline_queue_size cannot be less than 0")
+	}
+	if cfg.DetectEveryNFrames < 0 {
+		return nil, nil, errors.New("attribute detect_every_n_frames cannot be less than 0")
+	}
+	if cfg.DetectEveryNFrames > 1 && cfg.Pipelined {
+		return nil, nil, errors.New("attribute detect_every_n_frames cannot be used in pipelined mode")
+	}
+	if cfg.PropagationRadius < 0 {
+		return nil, nil, errors.New("attribute propagation_radius_px cannot be less than 0")
+	}
+	if cfg.TileSize < 0 {
+		return nil, nil, errors.New("attribute tile_size_px cannot be less than 0")
+	}
+	if cfg.TileOverlap != nil && (*cfg.TileOverlap < 0 || *cfg.TileOverlap >= 1) {
+		return nil, nil, errors.New("attribute tile_overlap must be at least 0 and below 1")
+	}
+	if cfg.TileMergeThreshold != nil && (*cfg.TileMergeThreshold < 0 || *cfg.TileMergeThreshold >= 1) {
+		return nil, nil, errors.New("attribute tile_merge_threshold must be at least 0 and below 1")
+	}
+	if cfg.CoastFrames < 0 {
+		return nil, nil, errors.New("attribute coast_frames cannot be less than 0")
+	}
+	bufferSize := cfg.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	if cfg.CoastFrames > bufferSize {
+		// lost tracks cannot coast longer than they are kept
+		return nil, nil, errors.Errorf("attribute coast_frames cannot be more than buffer_size (%d)", bufferSize)
+	}
+	switch cfg.BoxSmoothing {
+	case "", BoxSmoothingNone, BoxSmoothingEMA, BoxSmoothingKalman:
+	default:
+		return nil, nil, errors.Errorf("attribute box_smoothing must be one of %q, %q or %q",
+			BoxSmoothingNone, BoxSmoothingEMA, BoxSmoothingKalman)
+	}
+	if err := ValidateChosenLabels(cfg.ChosenLabels); err != nil {
+		return nil, nil, err
+	}
+	for class, canonical := range cfg.LabelAliases {
+		if canonical == "" || strings.Contains(canonical, "_") {
+			return nil, nil, errors.Errorf("label alias of %q must be a class name without underscores", class)
+		}
+	}
+	switch cfg.Duplicates {
+	case "", DuplicatesNone, DuplicatesNMS, DuplicatesWBF:
+	default:
+		return nil, nil, errors.Errorf("attribute duplicate_suppression must be one of %q, %q or %q",
+			DuplicatesNone, DuplicatesNMS, DuplicatesWBF)
+	}
+	if cfg.DuplicateIOU != nil && (*cfg.DuplicateIOU < 0 || *cfg.DuplicateIOU >= 1) {
+		return nil, nil, errors.New("attribute duplicate_iou_threshold must be at least 0 and below 1")
+	}
+	if cfg.DuplicateClass != "" && cfg.DuplicateClass != DuplicateClassBest && cfg.DuplicateClass != DuplicateClassVote {
+		return nil, nil, errors.Errorf("attribute duplicate_class_policy must be %q or %q", DuplicateClassBest, DuplicateClassVote)
+	}
+	if err := cfg.BoxFilters.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if err := validateRegions("include_regions", cfg.IncludeRegions); err != nil {
+		return nil, nil, err
+	}
+	if err := validateRegions("exclude_regions", cfg.ExcludeRegions); err != nil {
+		return nil, nil, err
+	}
+	if cfg.RegionRule != "" && cfg.RegionRule != RegionRuleCenter && cfg.RegionRule != RegionRuleOverlap {
+		return nil, nil, errors.Errorf("attribute region_rule must be %q or %q", RegionRuleCenter, RegionRuleOverlap)
+	}
+	if cfg.RegionMinOverlap != nil && (*cfg.RegionMinOverlap <= 0 || *cfg.RegionMinOverlap > 1) {
+		return nil, nil, errors.New("attribute region_min_overlap must be above 0 and at most 1")
+	}
+	if cfg.CropToRegions && len(cfg.IncludeRegions) == 0 {
+		return nil, nil, errors.New("attribute crop_to_regions needs include_regions")
+	}
+	switch cfg.ClassPolicy {
+	case "", ClassPolicyLocked, ClassPolicyMajority, ClassPolicyLatest:
+	default:
+		return nil, nil, errors.Errorf("attribute class_policy must be one of %q, %q or %q",
+			ClassPolicyLocked, ClassPolicyMajority, ClassPolicyLatest)
+	}
+	if cfg.SmoothingAlpha != nil && (*cfg.SmoothingAlpha <= 0 || *cfg.SmoothingAlpha > 1) {
+		return nil, nil, errors.New("attribute smoothing_alpha must be above 0 and at most 1")
+	}
+	for _, output := range cfg.SmoothedOutputs {
+		if output != SmoothedOutputDetections && output != SmoothedOutputLogs {
+			return nil, nil, errors.Errorf("attribute smoothed_outputs can only contain %q and %q",
+				SmoothedOutputDetections, SmoothedOutputLogs)
+		}
+	}
+	if cfg.DirectionWeight != nil && *cfg.DirectionWeight < 0 {
+		return nil, nil, errors.New("attribute direction_consistency_weight cannot be less than 0")
+	}
+	if cfg.RecordingMaxFrames < 0 {
+		return nil, nil, errors.New("attribute recording_max_frames cannot be less than 0")
+	}
+	if cfg.RecordingMaxFiles < 0 {
+		return nil, nil, errors.New("attribute recording_max_files cannot be less than 0")
+	}
+	if cfg.MaxSessions < 0 {
+		return nil, nil, errors.New("attribute max_sessions cannot be less than 0")
+	}
+	if cfg.RecordingDir == "" && (cfg.RecordOnStart || cfg.RecordImages) {
+		return nil, nil, errors.New("attributes record_on_start and record_images need recording_dir")
+	}
+	switch cfg.Mode {
+	case "", ModeContinuous:
+	case ModeOnDemand, ModePush:
+		if cfg.Pipelined {
+			return nil, nil, errors.Errorf("attribute pipelined cannot be used in %s mode", cfg.Mode)
+		}
+		if cfg.DetectEveryNFrames > 1 {
+			return nil, nil, errors.Errorf("attribute detect_every_n_frames cannot be used in %s mode", cfg.Mode)
+		}
+	default:
+		return nil, nil, errors.Errorf("attribute mode must be one of %q, %q or %q", ModeContinuous, ModeOnDemand, ModePush)
+	}
+	// this makes them required for the model to successfully build, but on demand trackers are
+	// given their images, and push trackers their detections
+	if cfg.CameraName == "" && cfg.Mode != ModeOnDemand && cfg.Mode != ModePush {
 		return nil, nil, fmt.Errorf(`expected "camera_name" attribute for object tracker %q`, path)
 	}
-	if cfg.DetectorName == "" {
+	if cfg.DetectorName == "" && len(cfg.DetectorNames) == 0 && cfg.Mode != ModePush {
 		return nil, nil, fmt.Errorf(`expected "detector_name" attribute for object tracker %q`, path)
 	}
+	if cfg.DetectorName != "" && len(cfg.DetectorNames) > 0 {
+		return nil, nil, errors.New("attributes detector_name and detector_names cannot be used together")
+	}
+	for i, name := range cfg.DetectorNames {
+		if name == "" || slices.Contains(cfg.DetectorNames[:i], name) {
+			return nil, nil, errors.New("attribute detector_names must only contain distinct names")
+		}
+	}
+	for name, labels := range cfg.DetectorLabels {
+		if !slices.Contains(cfg.DetectorNames, name) {
+			return nil, nil, errors.Errorf("detector_labels of %q must be for a detector in detector_names", name)
+		}
+		if err := ValidateChosenLabels(labels); err != nil {
+			return nil, nil, err
+		}
+	}
+	switch cfg.EnsembleFusion {
+	case "", DuplicatesNone, DuplicatesNMS, DuplicatesWBF:
+	default:
+		return nil, nil, errors.Errorf("attribute ensemble_fusion must be one of %q, %q or %q",
+			DuplicatesNone, DuplicatesNMS, DuplicatesWBF)
+	}
+
+	var deps []string
+	if cfg.CameraName != "" {
+		deps = append(deps, cfg.CameraName)
+	}
+	if cfg.DetectorName != "" {
+		deps = append(deps, cfg.DetectorName)
+	}
+	deps = append(deps, cfg.DetectorNames...)
+	switch cfg.MotionCompensation {
+	case "", MotionCompensationNone, MotionCompensationImage:
+	case MotionCompensationMovementSensor:
+		if cfg.MovementSensorName == "" {
+			return nil, nil, fmt.Errorf(`expected "movement_sensor_name" attribute for object tracker %q`, path)
+		}
+		if cfg.CameraFOV <= 0 || cfg.CameraFOV >= 180 {
+			return nil, nil, errors.New("attribute camera_horizontal_fov_deg must be between 0 and 180")
+		}
+		deps = append(deps, cfg.MovementSensorName)
+	default:
+		return nil, nil, errors.Errorf("attribute motion_compensation must be one of %q, %q or %q",
+			MotionCompensationNone, MotionCompensationImage, MotionCompensationMovementSensor)
+	}
 
 	// Return the resource names so that newTracker can access them as dependencies.
-	return []string{cfg.CameraName, cfg.DetectorName}, nil, nil
+	return deps, nil, nil
 }
 
 // Reconfigure reconfigures with new settings.
 func (t *myTracker) Reconfigure(ctx context.Context, deps resource.Dependencies, conf resource.Config) error {
-	var timeList []time.Duration
 	t.cam = nil
 	t.detector = nil
-	t.timeStats = timeList
+	t.ensemble = nil
+	t.timeStats.Reset()
 
 	// This takes the generic resource.Config passed down from the parent and converts it to the
 	// model-specific (aka "native") Config structure defined, above making it easier to directly access attributes.
@@ -351,22 +686,161 @@ func (t *myTracker) Reconfigure(ctx context.Context, deps resource.Dependencies,
 		return errors.Errorf("Could not assert proper config for %s", ModelName)
 	}
 
+	// the background stages are started once, so switching modes needs a new tracker
+	mode := trackerConfig.Mode
+	if mode == "" {
+		mode = ModeContinuous
+	}
+	if t.cancelContext != nil && (trackerConfig.Pipelined != t.pipelined || mode != t.mode) {
+		return resource.NewMustRebuildError(conf.ResourceName())
+	}
+	t.mode = mode
+	t.conf, t.deps = conf, deps
+	// the sessions are started again with the new configuration
+	t.sessionsMutex.Lock()
+	sessions := t.sessions
+	t.sessions = nil
+	t.maxSessions = trackerConfig.MaxSessions
+	if t.maxSessions == 0 {
+		t.maxSessions = DefaultMaxSessions
+	}
+	t.sessionsMutex.Unlock()
+	for _, s := range sessions {
+		s.stopSession()
+	}
+	t.pipelined = trackerConfig.Pipelined
+	if trackerConfig.PipelineQueueSize > 0 {
+		t.pipelineQueueSize = trackerConfig.PipelineQueueSize
+	} else {
+		t.pipelineQueueSize = DefaultPipelineQueueSize
+	}
+
+	//config detection interval, detect on every frame by default
+	t.detectEveryNFrames = max(trackerConfig.DetectEveryNFrames, 1)
+	if trackerConfig.PropagationRadius > 0 {
+		t.propagationRadius = trackerConfig.PropagationRadius
+	} else {
+		t.propagationRadius = DefaultPropagationRadius
+	}
+
 	if trackerConfig.MaxFrequency < 0 {
 		// if 0, will be set to default later
 		return errors.New("frequency(Hz) must be a positive number")
 	}
 	t.frequency = trackerConfig.MaxFrequency
 
+	//config track persistence
 	t.minTrackPersistence = trackerConfig.MinTrackPersistence
+	if t.minTrackPersistence == 0 {
+		t.minTrackPersistence = DefaultMinTrackPersistence
+	}
+
+	//config track history length
+	if trackerConfig.MaxTrackHistory > 0 {
+		t.maxTrackHistory = trackerConfig.MaxTrackHistory
+	} else {
+		t.maxTrackHistory = DefaultMaxTrackHistory
+	}
+
+	//config direction consistency weight
+	if trackerConfig.DirectionWeight != nil {
+		t.directionWeight = *trackerConfig.DirectionWeight
+	} else {
+		t.directionWeight = DefaultDirectionWeight
+	}
+
+	//config coasting, which Validate keeps within the buffer size
+	t.coastFrames = trackerConfig.CoastFrames
+
+	//config label aliases, with lower-cased detector classes
+	t.labelAliases = make(map[string]string, len(trackerConfig.LabelAliases))
+	for class, canonical := range trackerConfig.LabelAliases {
+		t.labelAliases[strings.ToLower(class)] = strings.ToLower(canonical)
+	}
+
+	//config duplicate suppression, off by default
+	t.duplicates = DuplicateSuppression{
+		Method:       trackerConfig.Duplicates,
+		IOUThreshold: DefaultDuplicateIOUThreshold,
+		ClassPolicy:  trackerConfig.DuplicateClass,
+	}
+	if trackerConfig.DuplicateIOU != nil {
+		t.duplicates.IOUThreshold = *trackerConfig.DuplicateIOU
+	}
+
+	//config tiled detection, off by default
+	t.tiling = &tiling{
+		size:           trackerConfig.TileSize,
+		overlap:        DefaultTileOverlap,
+		fullFrame:      trackerConfig.TileFullFrame,
+		mergeThreshold: DefaultTileMergeThreshold,
+	}
+	if trackerConfig.TileOverlap != nil {
+		t.tiling.overlap = *trackerConfig.TileOverlap
+	}
+	if trackerConfig.TileMergeThreshold != nil {
+		t.tiling.mergeThreshold = *trackerConfig.TileMergeThreshold
+	}
+
+	//config geometric box filters, global or per class
+	t.boxFilters = trackerConfig.BoxFilters
+
+	//config regions of interest, detections are kept by the center of their box by default
+	t.regions = &regionFilter{
+		include:    newPolygons(trackerConfig.IncludeRegions),
+		exclude:    newPolygons(trackerConfig.ExcludeRegions),
+		rule:       trackerConfig.RegionRule,
+		minOverlap: DefaultRegionMinOverlap,
+		crop:       trackerConfig.CropToRegions,
+	}
+	if t.regions.rule == "" {
+		t.regions.rule = RegionRuleCenter
+	}
+	if trackerConfig.RegionMinOverlap != nil {
+		t.regions.minOverlap = *trackerConfig.RegionMinOverlap
+	}
+
+	//config which class tracks are reported as, the first one by default
+	t.classPolicy = trackerConfig.ClassPolicy
+	if t.classPolicy == "" {
+		t.classPolicy = ClassPolicyLocked
+	}
+
+	//config output smoothing, applied to the detections by default
+	t.smoothing = smoothing{
+		boxes:  trackerConfig.BoxSmoothing,
+		alpha:  DefaultSmoothingAlpha,
+		scores: trackerConfig.SmoothScores,
+		vote:   trackerConfig.MajorityVoteLabels,
+	}
+	if t.smoothing.boxes == "" {
+		t.smoothing.boxes = BoxSmoothingNone
+	}
+	if trackerConfig.SmoothingAlpha != nil {
+		t.smoothing.alpha = *trackerConfig.SmoothingAlpha
+	}
+	outputs := trackerConfig.SmoothedOutputs
+	if len(outputs) == 0 {
+		outputs = []string{SmoothedOutputDetections}
+	}
+	t.smoothing.detections = slices.Contains(outputs, SmoothedOutputDetections)
+	t.smoothing.logs = slices.Contains(outputs, SmoothedOutputLogs)
+	t.smoothers = nil
+	if t.smoothing.enabled() {
+		t.smoothers = make(map[string]*trackSmoother)
+	}
 
 	//config buffer size
 	if trackerConfig.BufferSize > 0 {
 		if trackerConfig.BufferSize > 256 {
 			return errors.New("buffer size must be between 1 and 256")
 		}
-		t.lostDetectionsBuffer = newTracksBuffer(trackerConfig.BufferSize)
+		t.bufferSize = trackerConfig.BufferSize
 	} else {
-		t.lostDetectionsBuffer = newTracksBuffer(DefaultBufferSize)
+		t.bufferSize = DefaultBufferSize
+	}
+	if err := t.configureTracks(); err != nil {
+		return err
 	}
 
 	//config trigger cool down
@@ -391,13 +865,56 @@ func (t *myTracker) Reconfigure(ctx context.Context, deps resource.Dependencies,
 
 	t.chosenLabels = trackerConfig.ChosenLabels
 	t.camName = trackerConfig.CameraName
-	t.cam, err = camera.FromProvider(deps, trackerConfig.CameraName)
-	if err != nil {
-		return errors.Wrapf(err, "unable to get camera %v for object tracker", trackerConfig.CameraName)
+	if t.camName != "" {
+		t.cam, err = camera.FromProvider(deps, trackerConfig.CameraName)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get camera %v for object tracker", trackerConfig.CameraName)
+		}
 	}
-	t.detector, err = vision.FromProvider(deps, trackerConfig.DetectorName)
-	if err != nil {
-		return errors.Wrapf(err, "unable to get camera %v for object tracker", trackerConfig.DetectorName)
+	if trackerConfig.DetectorName != "" {
+		t.detector, err = vision.FromProvider(deps, trackerConfig.DetectorName)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get camera %v for object tracker", trackerConfig.DetectorName)
+		}
+	}
+
+	//config ensemble of detectors, fused with weighted box fusion by default
+	if len(trackerConfig.DetectorNames) > 0 {
+		t.ensemble = &ensemble{fusion: t.duplicates}
+		t.ensemble.fusion.Method = trackerConfig.EnsembleFusion
+		if t.ensemble.fusion.Method == "" {
+			t.ensemble.fusion.Method = DuplicatesWBF
+		}
+		for _, name := range trackerConfig.DetectorNames {
+			detector, err := vision.FromProvider(deps, name)
+			if err != nil {
+				return errors.Wrapf(err, "unable to get detector %v for object tracker", name)
+			}
+			t.ensemble.members = append(t.ensemble.members, &ensembleMember{
+				name:     name,
+				detector: detector,
+				labels:   trackerConfig.DetectorLabels[name],
+				latency:  newLatencyHistogram(),
+			})
+		}
+	}
+
+	//config recording, shared with the default session by the other sessions
+	if t.sessionName == "" {
+		if err := t.configureRecorder(trackerConfig); err != nil {
+			return err
+		}
+	}
+
+	//config camera motion compensation
+	t.motionCompensation = trackerConfig.MotionCompensation
+	t.movementSensor = nil
+	t.cameraFOV = trackerConfig.CameraFOV
+	if t.motionCompensation == MotionCompensationMovementSensor {
+		t.movementSensor, err = movementsensor.FromProvider(deps, trackerConfig.MovementSensorName)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get movement sensor %v for object tracker", trackerConfig.MovementSensorName)
+		}
 	}
 	return nil
 }
@@ -410,6 +927,16 @@ func (t *myTracker) DetectionsFromCamera(
 	if cameraName != "" && cameraName != t.camName {
 		return nil, errors.Errorf("Camera name given to method, %v is not the same as configured camera %v", cameraName, t.camName)
 	}
+	if t.mode == ModeOnDemand {
+		if t.cam == nil {
+			return nil, errors.New("no camera_name configured, use Detections with an image instead")
+		}
+		img, err := camera.DecodeImageFromCamera(ctx, t.cam, nil, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't get image")
+		}
+		return t.Detections(ctx, img, extra)
+	}
 	select {
 	case <-t.cancelContext.Done():
 		return nil, t.cancelContext.Err()
@@ -430,6 +957,16 @@ func (t *myTracker) Detections(ctx context.Context, img image.Image, extra map[s
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
+		if t.mode == ModeOnDemand {
+			if img == nil {
+				return nil, errors.New("on_demand mode needs an image")
+			}
+			s, err := t.session(ctx, extra)
+			if err != nil {
+				return nil, err
+			}
+			return s.trackImage(ctx, img)
+		}
 		t.currDetections.mutex.RLock()
 		dets := getStableDetections(t.currDetections.detections)
 		t.currDetections.mutex.RUnlock()
@@ -446,17 +983,27 @@ func (t *myTracker) ClassificationsFromCamera(
 	if cameraName != "" && cameraName != t.camName {
 		return nil, errors.Errorf("Camera name given to method, %v is not the same as configured camera %v", cameraName, t.camName)
 	}
-	if newInstance := t.newInstance.Load(); newInstance {
-		return []classification.Classification{classification.NewClassification(1, NewObjectDetectedLabel)}, nil
-	} else {
-		return []classification.Classification{}, nil
-	}
+	return t.classifications(ctx, extra)
 }
 
 func (t *myTracker) Classifications(ctx context.Context, img image.Image,
 	n int, extra map[string]interface{},
 ) (classification.Classifications, error) {
-	if newInstance := t.newInstance.Load(); newInstance {
+	return t.classifications(ctx, extra)
+}
+
+// classifications returns whether a new object was detected recently. In on demand and push modes,
+// it is about the session in extra, like the detections.
+func (t *myTracker) classifications(ctx context.Context, extra map[string]interface{}) (classification.Classifications, error) {
+	tr := t
+	if t.mode == ModeOnDemand || t.mode == ModePush {
+		s, err := t.session(ctx, extra)
+		if err != nil {
+			return nil, err
+		}
+		tr = s
+	}
+	if newInstance := tr.newInstance.Load(); newInstance {
 		return []classification.Classification{classification.NewClassification(1, NewObjectDetectedLabel)}, nil
 	} else {
 		return []classification.Classification{}, nil
@@ -483,6 +1030,7 @@ func (t *myTracker) CaptureAllFromCamera(
 	var detections []objdet.Detection
 	var classifications []classification.Classification
 	var img image.Image
+	captureExtra := make(map[string]interface{})
 	select {
 	case <-t.cancelContext.Done():
 		return viscapture.VisCapture{}, t.cancelContext.Err()
@@ -493,11 +1041,24 @@ func (t *myTracker) CaptureAllFromCamera(
 			if cameraName != "" && cameraName != t.camName {
 				return viscapture.VisCapture{}, errors.Errorf("Camera name given to method, %v is not the same as configured camera %v", cameraName, t.camName)
 			}
-			img = *t.currImg.Load()
+			// there is no image before the first on demand step
+			if currImg := t.currImg.Load(); currImg != nil {
+				img = *currImg
+			}
 		}
 		if opt.ReturnDetections {
 			t.currDetections.mutex.RLock()
 			detections = getStableDetections(t.currDetections.detections)
+			// let the caller know the detector did not run on this frame
+			captureExtra["propagated"] = t.currDetections.propagated
+			// and which tracks are lost, and reported at their predicted box
+			captureExtra["coasting"] = getCoastingLabels(t.currDetections.detections)
+			// and which boxes touch the image border
+			captureExtra["truncated"] = getTruncatedLabels(t.currDetections.detections)
+			// and what the overlay camera draws, if it is the caller
+			if overlay, _ := extra[OverlayKey].(bool); overlay {
+				captureExtra[OverlayKey] = t.overlayState(t.currDetections.trails)
+			}
 			t.currDetections.mutex.RUnlock()
 		}
 		if opt.ReturnClassifications {
@@ -508,12 +1069,21 @@ func (t *myTracker) CaptureAllFromCamera(
 			}
 		}
 	}
-	return viscapture.VisCapture{Image: img, Detections: detections, Classifications: classifications}, nil
+	return viscapture.VisCapture{Image: img, Detections: detections, Classifications: classifications, Extra: captureExtra}, nil
 }
 
 func (t *myTracker) Close(ctx context.Context) error {
 	t.cancelFunc()
 	t.activeBackgroundWorkers.Wait()
+	t.sessionsMutex.Lock()
+	defer t.sessionsMutex.Unlock()
+	for _, s := range t.sessions {
+		s.activeBackgroundWorkers.Wait()
+	}
+	if t.recorder != nil {
+		_, err := t.recorder.stop()
+		return err
+	}
 	return nil
 }
 
@@ -521,33 +1091,53 @@ type benchmark struct {
 	Slowest      float64
 	Fastest      float64
 	Average      float64
+	P50          float64
+	P90          float64
+	P99          float64
 	NumberOfRuns int
 }
 
 // DoCommand will return the slowest, fastest, and average time of the tracking module
 func (t *myTracker) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
-	// average, fastest, and slowest time (and n)
+	// average, fastest, slowest and percentile times (and n)
 	out := make(map[string]interface{})
 	if cmd["benchmark"] != nil {
-		tmin, tmax := 10*time.Second, 10*time.Nanosecond
-		n := int64(len(t.timeStats))
-		var sum time.Duration
-		for _, tt := range t.timeStats {
-			if tt < tmin {
-				tmin = tt
-			}
-			if tt > tmax {
-				tmax = tt
-			}
-			sum += tt
+		out["benchmark"] = t.timeStats.Benchmark()
+		if t.ensemble != nil {
+			out["detector_benchmark"] = t.ensemble.benchmark()
 		}
-		mean := time.Duration(int64(sum) / n)
-		out["benchmark"] = benchmark{
-			Slowest:      float64(tmax),
-			Fastest:      float64(tmin),
-			Average:      float64(mean),
-			NumberOfRuns: int(n),
+	}
+	if cmd["pipeline_stats"] != nil {
+		if !t.pipelined {
+			return nil, errors.New("pipeline_stats is only available when pipelined is true")
+		}
+		out["pipeline_stats"] = t.pipelineStats.report()
+	}
+	if frame, ok := cmd["push"]; ok {
+		tracks, err := t.push(ctx, frame)
+		if err != nil {
+			return nil, err
+		}
+		out["push"] = tracks
+	}
+	if name, ok := cmd["end_session"]; ok {
+		session, ok := name.(string)
+		if !ok {
+			return nil, errors.New("end_session must be the name of a session")
+		}
+		out["end_session"] = t.endSession(session)
+	}
+	if arg, ok := cmd["record"]; ok {
+		status, err := t.record(arg)
+		if err != nil {
+			return nil, err
 		}
+		out["record"] = status
+	}
+	if cmd["raw_detections"] != nil {
+		t.currDetections.mutex.RLock()
+		out["raw_detections"] = getRawDetections(t.currDetections.raw)
+		t.currDetections.mutex.RUnlock()
 	}
 	if cmd["logs"] != nil {
 		t.allFreshObjects.mutex.RLock()
@@ -556,38 +1146,3 @@ func (t *myTracker) DoCommand(ctx context.Context, cmd map[string]interface{}) (
 	}
 	return out, nil
 }
-
-type tracksBuffer struct {
-	detections [][]*track
-	size       int
-}
-
-// newTracksBuffer initializes a new fixed-length queue with the specified size.
-func newTracksBuffer(size int) *tracksBuffer {
-	return &tracksBuffer{
-		detections: make([][]*track, 0, size),
-		size:       size,
-	}
-}
-func (b *tracksBuffer) AppendDets(newDets []*track) {
-	if len(b.detections) == b.size {
-		b.detections = b.detections[1:]
-	}
-
-	//remove old dets to match new dets only on the most recent detections
-	for _, newDet := range newDets {
-		countLabel := strings.Join(strings.Split(newDet.Det.Label(), "_")[0:2], "_")
-		for i := range b.detections {
-			dets := b.detections[i]
-			for idx, det := range dets {
-				oldCountLabel := strings.Join(strings.Split(det.Det.Label(), "_")[0:2], "_")
-				if countLabel == oldCountLabel {
-					b.detections[i] = append(dets[:idx], dets[idx+1:]...)
-					break
-				}
-			}
-		}
-	}
-
-	b.detections = append(b.detections, newDets)
-}
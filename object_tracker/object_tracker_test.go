@@ -4,7 +4,11 @@ import (
 	"context"
 	"fmt"
 	"image"
+	"runtime"
+	"strconv"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"go.viam.com/rdk/components/camera"
 	"go.viam.com/rdk/data"
@@ -15,7 +19,6 @@ import (
 	"go.viam.com/rdk/utils"
 	"go.viam.com/rdk/vision/viscapture"
 
-	hg "github.com/charles-haynes/munkres"
 	"go.viam.com/rdk/services/vision"
 	objdet "go.viam.com/rdk/vision/objectdetection"
 	"go.viam.com/test"
@@ -124,6 +127,32 @@ func TestValidate(t *testing.T) {
 	test.That(t, err, test.ShouldNotBeNil)
 	test.That(t, badDeps, test.ShouldBeNil)
 	test.That(t, err.Error(), test.ShouldContainSubstring, "detector_name")
+
+	// motion compensation from a movement sensor depends on it
+	sensorCfg := Config{CameraName: "camera", DetectorName: "detector", MotionCompensation: MotionCompensationMovementSensor}
+	_, _, err = sensorCfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "movement_sensor_name")
+	sensorCfg.MovementSensorName = "imu"
+	_, _, err = sensorCfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	sensorCfg.CameraFOV = 70
+	sensorDeps, _, err := sensorCfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, sensorDeps, test.ShouldResemble, []string{"camera", "detector", "imu"})
+
+	unknownCfg := Config{CameraName: "camera", DetectorName: "detector", MotionCompensation: "gyro"}
+	_, _, err = unknownCfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	duplicatesCfg := Config{CameraName: "camera", DetectorName: "detector", Duplicates: "soft-nms"}
+	_, _, err = duplicatesCfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "duplicate_suppression")
+	duplicatesCfg.Duplicates = DuplicatesWBF
+	duplicatesCfg.DuplicateClass = DuplicateClassVote
+	_, _, err = duplicatesCfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
 }
 
 func TestEmptyConfig(t *testing.T) {
@@ -162,8 +191,9 @@ func TestTracker(t *testing.T) {
 	}
 
 	fakeTracker := &myTracker{
-		classCounter: make(map[string]int),
-		tracks:       make(map[string][]*track),
+		logger:        logging.NewTestLogger(t),
+		cancelContext: context.Background(),
+		classCounter:  make(map[string]int),
 		properties: vision.Properties{
 			ClassificationSupported: true,
 			DetectionSupported:      true,
@@ -172,181 +202,64 @@ func TestTracker(t *testing.T) {
 		allFreshObjects: allObjects{
 			objects: []trackedObject{},
 		},
-		lostDetectionsBuffer: newTracksBuffer(10),
-	}
-
-	//initialisation
-	filteredOld := newTracks(fd.fakeDetections(), TestPersistenceLimit) // get cat and fish
-	filteredNew := newTracks(fd.fakeDetections(), TestPersistenceLimit) //get cat
-	renamedOld := make([]*track, 0, len(filteredOld))
-	for _, det := range filteredOld {
-		newDet := fakeTracker.RenameFirstTime(det) //create label fish_0 and cat_0
-		renamedOld = append(renamedOld, newDet)
-	}
-	//fakeTracker.lostDetectionsBuffer.AppendDets(renamedOld)
-
-	matchMtx := fakeTracker.BuildMatchingMatrix(renamedOld, filteredNew)
-	HA, err := hg.NewHungarianAlgorithm(matchMtx)
-	test.That(t, err, test.ShouldBeNil)
-	matches := HA.Execute()
-	lostDetections := []*track{}
-	for idx := range matches {
-		if matches[idx] == -1 {
-			lostDetections = append(lostDetections, renamedOld[idx])
-		}
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
 	}
-	test.That(t, len(lostDetections), test.ShouldEqual, 1)
-	checkLabel(t, lostDetections[0], LabelDet1) //we should be losing "fish"
-	fakeTracker.lostDetectionsBuffer.AppendDets(lostDetections)
-
-	// Rename from temporal matches. New det copies old det's label
-	renamedNew, newlyStable, _ := fakeTracker.RenameFromMatches(matches, matchMtx, renamedOld, filteredNew)
-
-	//Store stuffs
-	renamedNew = append(renamedNew, newlyStable...)
-	fakeTracker.lastDetections = renamedNew
-	fakeTracker.currDetections.mutex.Lock()
-	fakeTracker.currDetections.detections = renamedNew
-	fakeTracker.currDetections.mutex.Unlock()
-
-	//Check output
-	fakeTracker.currDetections.mutex.RLock()
-	currDetections := fakeTracker.currDetections.detections
-	fakeTracker.currDetections.mutex.RUnlock()
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+
+	//initialisation: the cat and the fish are new, and not stable yet
+	fakeTracker.step(newTracks(fd.fakeDetections())) // get cat and fish
+	test.That(t, len(fakeTracker.lastDetections), test.ShouldEqual, 2)
+	checkLabel(t, fakeTracker.lastDetections[0], LabelDet0+"_0")
+	checkLabel(t, fakeTracker.lastDetections[1], LabelDet1+"_0")
+	test.That(t, fakeTracker.stableTracks(), test.ShouldBeEmpty)
+
+	//the cat becomes stable, and we lose the fish, which is dropped since it was not stable
+	fakeTracker.step(newTracks(fd.fakeDetections())) //get cat
+	currDetections := fakeTracker.stableTracks()
 	test.That(t, len(currDetections), test.ShouldEqual, 1)
 	checkLabel(t, currDetections[0], LabelDet0)
+	cat := currDetections[0]
+	test.That(t, len(fakeTracker.allFreshObjects.objects), test.ShouldEqual, 1)
+	_, ok := fakeTracker.tracks.Track(LabelDet1 + "_0")
+	test.That(t, ok, test.ShouldBeFalse)
 
 	//End of initialisation get new detections
 
-	filteredNew = newTracks(fd.fakeDetections(), TestPersistenceLimit) //get fish but somewhere else
-
-	// Store oldDetection and lost detections in allDetections
-	allDetections := fakeTracker.lastDetections
-	for _, dets := range fakeTracker.lostDetectionsBuffer.detections {
-		allDetections = append(allDetections, dets...)
-	}
-
-	// Build and solve cost matrix via Munkres' method
-	matchMtx = fakeTracker.BuildMatchingMatrix(allDetections, filteredNew)
-	HA, _ = hg.NewHungarianAlgorithm(matchMtx)
-	matches = HA.Execute()
-	lostDetections = []*track{}
-	for idx := range fakeTracker.lastDetections {
-		if matches[idx] == -1 {
-			lostDetections = append(lostDetections, fakeTracker.lastDetections[idx])
-		}
-	}
-	test.That(t, len(lostDetections), test.ShouldEqual, 1)
-	checkLabel(t, lostDetections[0], LabelDet0) //now we loose the cat
-
-	//}
-	// Rename from temporal matches. New det copies old det's label
-	renamedNew, newlyStable, _ = fakeTracker.RenameFromMatches(matches, matchMtx, allDetections, filteredNew)
-	fakeTracker.lostDetectionsBuffer.AppendDets(lostDetections)
-
-	// Store results
-	renamedNew = append(renamedNew, newlyStable...)
-	fakeTracker.lastDetections = renamedNew
-	fakeTracker.currDetections.mutex.Lock()
-	fakeTracker.currDetections.detections = renamedNew
-	fakeTracker.currDetections.mutex.Unlock()
-
-	// Check output
-	fakeTracker.currDetections.mutex.RLock()
-	currDetections = fakeTracker.currDetections.detections
-	fakeTracker.currDetections.mutex.RUnlock()
+	//now we lose the cat, which is kept since it is stable, and the fish somewhere else is a new
+	//fish, so that we don't have 2 "fish_0"
+	fakeTracker.step(newTracks(fd.fakeDetections())) //get fish but somewhere else
+	test.That(t, len(fakeTracker.lastDetections), test.ShouldEqual, 1)
+	checkLabel(t, fakeTracker.lastDetections[0], LabelDet1+"_1")
+	test.That(t, fakeTracker.lastDetections[0].Det.BoundingBox().Min, test.ShouldResemble, image.Pt(22, 22))
+	test.That(t, fakeTracker.lastDetections[0].Det.BoundingBox().Max, test.ShouldResemble, image.Pt(33, 33))
+	lostCat, ok := fakeTracker.tracks.Track(getTrackingLabel(cat))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, lostCat.Misses, test.ShouldEqual, 1)
+	test.That(t, lostCat.Box, test.ShouldResemble, image.Rect(0, 0, 10, 10))
+
+	//detecting the cat again brings back its label, and loses the new fish
+	fakeTracker.step(newTracks(fd.fakeDetections())) //get cat again
+	currDetections = fakeTracker.stableTracks()
 	test.That(t, len(currDetections), test.ShouldEqual, 1)
-	test.That(t, currDetections[0].Det.Label()[:len(LabelDet1)], test.ShouldEqual, LabelDet1)
-
-	//Detecting a new cat, now we want to make sure that we don't have 2 "fish_zero"
-	//when fish get lost
-	filteredNew = newTracks(fd.fakeDetections(), TestPersistenceLimit) //get cat again
-	test.That(t, len(filteredNew), test.ShouldEqual, 1)
-	checkLabel(t, filteredNew[0], LabelDet0)
-
-	// Store oldDetection and lost detections in allDetections
-	allDetections = fakeTracker.lastDetections
-	for _, dets := range fakeTracker.lostDetectionsBuffer.detections {
-		allDetections = append(allDetections, dets...)
+	test.That(t, currDetections[0].Det.Label(), test.ShouldEqual, cat.Det.Label())
+	tracks := fakeTracker.tracks.Tracks()
+	test.That(t, len(tracks), test.ShouldEqual, 1)
+	test.That(t, tracks[0].Misses, test.ShouldEqual, 0)
+	// the cat was already logged when it became stable
+	test.That(t, len(fakeTracker.allFreshObjects.objects), test.ShouldEqual, 1)
+
+	// the cat expires once it is lost for longer than the buffer
+	for range fakeTracker.bufferSize {
+		fakeTracker.step(nil)
 	}
-
-	// Build and solve cost matrix via Munkres' method
-	matchMtx = fakeTracker.BuildMatchingMatrix(allDetections, filteredNew)
-	HA, _ = hg.NewHungarianAlgorithm(matchMtx)
-	matches = HA.Execute()
-	lostDetections = []*track{}
-	for idx := range fakeTracker.lastDetections {
-		if matches[idx] == -1 {
-			lostDetections = append(lostDetections, fakeTracker.lastDetections[idx])
-		}
-	}
-	test.That(t, len(lostDetections), test.ShouldEqual, 1)
-	checkLabel(t, lostDetections[0], LabelDet1) //loosing the fish
-
-	//}
-	// Rename from temporal matches. New det copies old det's label
-	renamedNew, newlyStable, _ = fakeTracker.RenameFromMatches(matches, matchMtx, allDetections, filteredNew)
-	renamedNew = append(renamedNew, newlyStable...)
-	test.That(t, len(fakeTracker.lostDetectionsBuffer.detections[0]), test.ShouldEqual, 1)
-	checkLabel(t, fakeTracker.lostDetectionsBuffer.detections[0][0], LabelDet1) //check if there used to be fish
-	test.That(
-		t,
-		fakeTracker.lostDetectionsBuffer.detections[0][0].Det.BoundingBox().Min,
-		test.ShouldResemble,
-		image.Pt(20, 20),
-	)
-	test.That(
-		t,
-		fakeTracker.lostDetectionsBuffer.detections[0][0].Det.BoundingBox().Max,
-		test.ShouldResemble,
-		image.Pt(30, 30),
-	)
-	fakeTracker.lostDetectionsBuffer.AppendDets(lostDetections)
-	//check if the last fish_0 has been deleted
-	test.That(t, len(fakeTracker.lostDetectionsBuffer.detections[0]), test.ShouldEqual, 0)
-
-	//check if the new fish is actually new (updated bbox)
-	test.That(t, len(fakeTracker.lostDetectionsBuffer.detections[2]), test.ShouldEqual, 1)
-	checkLabel(t, fakeTracker.lostDetectionsBuffer.detections[2][0], LabelDet1)
-	test.That(
-		t,
-		fakeTracker.lostDetectionsBuffer.detections[2][0].Det.BoundingBox().Min,
-		test.ShouldResemble,
-		image.Pt(22, 22),
-	)
-	test.That(
-		t,
-		fakeTracker.lostDetectionsBuffer.detections[2][0].Det.BoundingBox().Max,
-		test.ShouldResemble,
-		image.Pt(33, 33),
-	)
-
-	test.That(
-		t,
-		fakeTracker.lostDetectionsBuffer.detections[2][0].Det.BoundingBox().Max,
-		test.ShouldNotResemble,
-		image.Pt(20, 20),
-	)
-
-	test.That(
-		t,
-		fakeTracker.lostDetectionsBuffer.detections[2][0].Det.BoundingBox().Max,
-		test.ShouldNotResemble,
-		image.Pt(30, 30),
-	)
-
-	// Store results
-	fakeTracker.lastDetections = renamedNew
-	fakeTracker.currDetections.mutex.Lock()
-	fakeTracker.currDetections.detections = renamedNew
-	fakeTracker.currDetections.mutex.Unlock()
-
-	// Check output
-	fakeTracker.currDetections.mutex.RLock()
-	currDetections = fakeTracker.currDetections.detections
-	fakeTracker.currDetections.mutex.RUnlock()
-	test.That(t, len(currDetections), test.ShouldEqual, 1)
-	checkLabel(t, currDetections[0], LabelDet0)
+	_, ok = fakeTracker.tracks.Track(getTrackingLabel(cat))
+	test.That(t, ok, test.ShouldBeTrue)
+	fakeTracker.step(nil)
+	_, ok = fakeTracker.tracks.Track(getTrackingLabel(cat))
+	test.That(t, ok, test.ShouldBeFalse)
+	test.That(t, fakeTracker.lastSeen, test.ShouldBeEmpty)
 }
 
 func TestInvalidCameraNamesError(t *testing.T) {
@@ -410,7 +323,7 @@ func TestReplaceLabelsAndBoundingBox(t *testing.T) {
 	det := objdet.NewDetection(bounds, image.Rect(0, 0, 10, 10), 1, LabelDet0)
 	test.That(t, det, test.ShouldNotBeNil)
 	test.That(t, det.Label(), test.ShouldEqual, LabelDet0)
-	tr := newTrack(det, 50)
+	tr := newTrack(det)
 
 	newLabel := "dog"
 	replacedTrack := ReplaceLabel(tr, newLabel)
@@ -422,3 +335,303 @@ func TestReplaceLabelsAndBoundingBox(t *testing.T) {
 	test.That(t, replacedTrack.Det.BoundingBox(), test.ShouldResemble, &newBB)
 	test.That(t, replacedTrack.Det.NormalizedBoundingBox(), test.ShouldResemble, []float64{0.4, 0.4, 0.6, 0.6})
 }
+
+func TestSoakMemoryStaysFlat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak test tracks 20000 frames")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bounds := image.Rect(0, 0, 640, 480)
+	maxHistory := 5
+	bufferSize := 10
+	nObjects := 5
+
+	fakeTracker := &myTracker{
+		logger:        logging.NewTestLogger(t),
+		cancelContext: ctx,
+		classCounter:  make(map[string]int),
+		allFreshObjects: allObjects{
+			objects: []trackedObject{},
+		},
+		bufferSize:          bufferSize,
+		minTrackPersistence: TestPersistenceLimit,
+		timeStats:           newLatencyHistogram(),
+		maxTrackHistory:     maxHistory,
+		coolDown:            DefaultTriggerCoolDown,
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+
+	// every object is visible for 40 frames, then hidden for longer than the buffer,
+	// so that it comes back as a new track
+	frame := func(i int) []*track {
+		dets := make([]objdet.Detection, 0, nObjects)
+		for o := range nObjects {
+			if (i+o*20)%100 >= 40 {
+				continue
+			}
+			x := 100*o + i%40
+			dets = append(dets, objdet.NewDetection(bounds, image.Rect(x, 50, x+40, 90), 0.9, LabelDet0))
+		}
+		return newTracks(dets)
+	}
+	heapAlloc := func() uint64 {
+		var m runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+
+	var warm uint64
+	for i := range 20000 {
+		if i == 2000 {
+			warm = heapAlloc()
+		}
+		start := time.Now()
+		fakeTracker.step(frame(i))
+		fakeTracker.timeStats.Record(time.Since(start))
+
+		// at most every object is active, plus the ones waiting in the lost buffer
+		tracks := fakeTracker.tracks.Tracks()
+		test.That(t, len(tracks), test.ShouldBeLessThanOrEqualTo, nObjects*2)
+		test.That(t, len(fakeTracker.lastSeen), test.ShouldEqual, len(tracks))
+		for _, tr := range tracks {
+			test.That(t, len(tr.History), test.ShouldBeLessThanOrEqualTo, maxHistory)
+		}
+	}
+	end := heapAlloc()
+	// the only thing allowed to grow is the log of newly seen objects
+	test.That(t, int64(end)-int64(warm), test.ShouldBeLessThan, 1<<20)
+	test.That(t, fakeTracker.timeStats.Benchmark().NumberOfRuns, test.ShouldEqual, 20000)
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram()
+	test.That(t, h.Benchmark(), test.ShouldResemble, benchmark{})
+	for i := 1; i <= 100; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	b := h.Benchmark()
+	test.That(t, b.NumberOfRuns, test.ShouldEqual, 100)
+	test.That(t, b.Fastest, test.ShouldEqual, float64(time.Millisecond))
+	test.That(t, b.Slowest, test.ShouldEqual, float64(100*time.Millisecond))
+	test.That(t, b.Average, test.ShouldEqual, float64(50500*time.Microsecond))
+	test.That(t, b.P50, test.ShouldEqual, float64(50*time.Millisecond))
+	test.That(t, b.P99, test.ShouldEqual, float64(100*time.Millisecond))
+	h.Reset()
+	test.That(t, h.Benchmark().NumberOfRuns, test.ShouldEqual, 0)
+}
+
+// newFakeCamera returns a camera that serves a blank frame of the given size.
+func newFakeCamera(bounds image.Rectangle) *inject.Camera {
+	return &inject.Camera{
+		ImagesFunc: func(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+			namedImage, err := camera.NamedImageFromImage(rimage.NewImageFromBounds(bounds), "color", utils.MimeTypeRawRGBA, data.Annotations{})
+			if err != nil {
+				return nil, resource.ResponseMetadata{}, err
+			}
+			return []camera.NamedImage{namedImage}, resource.ResponseMetadata{}, nil
+		},
+	}
+}
+
+func TestPipelinedTracker(t *testing.T) {
+	ctx := context.Background()
+	bounds := image.Rect(0, 0, 64, 48)
+	// after the two calls made by the constructor, the detector is held until the camera has
+	// overrun the capture queue
+	release := make(chan struct{})
+	var calls atomic.Int64
+	detector := &inject.VisionService{
+		DetectionsFunc: func(ctx context.Context, img image.Image, extra map[string]interface{}) ([]objdet.Detection, error) {
+			if calls.Add(1) > 2 {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-release:
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return []objdet.Detection{objdet.NewDetection(bounds, image.Rect(10, 10, 20, 20), 0.9, LabelDet0)}, nil
+		},
+	}
+	deps := resource.Dependencies{
+		camera.Named("camera"):   newFakeCamera(bounds),
+		vision.Named("detector"): detector,
+	}
+	conf := resource.Config{
+		Name: "test-objtracker",
+		API:  vision.API,
+		ConvertedAttributes: &Config{
+			CameraName:          "camera",
+			DetectorName:        "detector",
+			MaxFrequency:        200,
+			MinTrackPersistence: 1,
+			Pipelined:           true,
+		},
+	}
+	tracker, err := newTracker(ctx, deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	defer tracker.Close(ctx)
+
+	// waitForReport polls the pipeline stats until done holds, or fails after a generous deadline
+	waitForReport := func(done func(map[string]stageReport) bool) map[string]stageReport {
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			out, err := tracker.DoCommand(ctx, map[string]interface{}{"pipeline_stats": true})
+			test.That(t, err, test.ShouldBeNil)
+			report := out["pipeline_stats"].(map[string]stageReport)
+			if done(report) || time.Now().After(deadline) {
+				return report
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	report := waitForReport(func(r map[string]stageReport) bool { return r[captureStage].Dropped > 0 })
+	test.That(t, report[captureStage].Dropped, test.ShouldBeGreaterThan, 0)
+	test.That(t, report[trackStage].Processed, test.ShouldEqual, 0)
+
+	close(release)
+	report = waitForReport(func(r map[string]stageReport) bool { return r[endToEnd].Processed >= 5 })
+	test.That(t, report[trackStage].Processed, test.ShouldBeGreaterThanOrEqualTo, 5)
+	test.That(t, report[endToEnd].Processed, test.ShouldBeGreaterThanOrEqualTo, 5)
+	test.That(t, report[detectStage].Latency.Fastest, test.ShouldBeGreaterThanOrEqualTo, float64(time.Millisecond))
+	test.That(t, report[trackStage].LastSeq, test.ShouldBeLessThanOrEqualTo, report[captureStage].LastSeq)
+
+	// benchmark times tracking iterations, not the time frames spent waiting in the pipeline
+	out, err := tracker.DoCommand(ctx, map[string]interface{}{"benchmark": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out["benchmark"].(benchmark).NumberOfRuns, test.ShouldBeGreaterThanOrEqualTo, 5)
+	test.That(t, out["benchmark"].(benchmark).Fastest, test.ShouldBeLessThan, report[endToEnd].Latency.Slowest)
+
+	dets, err := tracker.Detections(ctx, nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(dets), test.ShouldEqual, 1)
+	checkLabel(t, &track{Det: dets[0]}, LabelDet0+"_0_")
+
+	// not pipelined trackers have no pipeline stats
+	_, err = (&myTracker{}).DoCommand(ctx, map[string]interface{}{"pipeline_stats": true})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestCoastingTracks(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+		coastFrames:         2,
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	box := image.Rect(0, 20, 20, 40)
+	at := func(frame int) image.Rectangle {
+		return box.Add(image.Pt(5*frame, 0))
+	}
+	for frame := range 4 {
+		fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, at(frame), 0.9, LabelDet0)}))
+	}
+	label := fakeTracker.lastDetections[0].Det.Label()
+	id := getTrackingLabel(fakeTracker.lastDetections[0])
+
+	// the lost track keeps being reported where it is expected to be, for coast_frames frames
+	for frame := 4; frame < 6; frame++ {
+		fakeTracker.step(nil)
+		test.That(t, fakeTracker.lastDetections, test.ShouldBeEmpty)
+		capture, err := fakeTracker.CaptureAllFromCamera(context.Background(), "", viscapture.CaptureOptions{ReturnDetections: true}, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(capture.Detections), test.ShouldEqual, 1)
+		test.That(t, capture.Detections[0].Label(), test.ShouldEqual, label)
+		test.That(t, *capture.Detections[0].BoundingBox(), test.ShouldResemble, at(frame))
+		test.That(t, capture.Extra["coasting"], test.ShouldResemble, []string{label})
+	}
+	fakeTracker.step(nil)
+	capture, err := fakeTracker.CaptureAllFromCamera(context.Background(), "", viscapture.CaptureOptions{ReturnDetections: true}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, capture.Detections, test.ShouldBeEmpty)
+	test.That(t, capture.Extra["coasting"], test.ShouldBeEmpty)
+	lost, ok := fakeTracker.tracks.Track(id)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, lost.Misses, test.ShouldEqual, 3)
+
+	// once found again, the history has no gap
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, at(7), 0.9, LabelDet0)}))
+	test.That(t, fakeTracker.lastDetections[0].Det.Label(), test.ShouldEqual, label)
+	found, ok := fakeTracker.tracks.Track(getTrackingLabel(fakeTracker.lastDetections[0]))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, len(found.History), test.ShouldEqual, 8)
+	for frame, h := range found.History {
+		test.That(t, h.Box, test.ShouldResemble, at(frame))
+	}
+	capture, err = fakeTracker.CaptureAllFromCamera(context.Background(), "", viscapture.CaptureOptions{ReturnDetections: true}, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, capture.Extra["coasting"], test.ShouldBeEmpty)
+}
+
+func TestCoastFramesWithinBufferSize(t *testing.T) {
+	cfg := &Config{CameraName: "camera", DetectorName: "detector", CoastFrames: DefaultBufferSize}
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	cfg.CoastFrames = DefaultBufferSize + 1
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "coast_frames")
+
+	cfg.BufferSize = DefaultBufferSize + 1
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+}
+
+func TestDisjointBoxesAreNotMatched(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	for range 2 {
+		fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, image.Rect(10, 10, 30, 30), 0.9, LabelDet0)}))
+	}
+	first := fakeTracker.lastDetections[0]
+	test.That(t, first.isStable(), test.ShouldBeTrue)
+
+	// a box that does not overlap the track costs 0, which is not a match even when it is the only
+	// assignment: the track is lost and the box starts a new one
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, image.Rect(150, 60, 170, 80), 0.9, LabelDet0)}))
+	test.That(t, len(fakeTracker.lastDetections), test.ShouldEqual, 1)
+	test.That(t, getTrackingLabel(fakeTracker.lastDetections[0]), test.ShouldEqual, LabelDet0+"_1")
+	lost, ok := fakeTracker.tracks.Track(getTrackingLabel(first))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, lost.Misses, test.ShouldEqual, 1)
+}
+
+func TestFreshTracksAreNamedInOrder(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 100)
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	var dets []objdet.Detection
+	for k := range 8 {
+		dets = append(dets, objdet.NewDetection(bounds, image.Rect(40*k, 10, 40*k+20, 30), 0.9, LabelDet0))
+	}
+	fakeTracker.step(newTracks(dets))
+	// the same detections always get the same names, so that a replay gives the same IDs
+	test.That(t, len(fakeTracker.lastDetections), test.ShouldEqual, len(dets))
+	for k, tr := range fakeTracker.lastDetections {
+		test.That(t, getTrackingLabel(tr), test.ShouldEqual, LabelDet0+"_"+strconv.Itoa(k))
+		test.That(t, *tr.Det.BoundingBox(), test.ShouldResemble, *dets[k].BoundingBox())
+	}
+}
@@ -0,0 +1,82 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the observation-centric parts of OC-SORT that are not in the tracking package:
+// the direction consistency term of the association cost. The tracking package re-updates the
+// tracks that are found again after an occlusion.
+package object_tracker
+
+import (
+	"image"
+	"math"
+
+	"github.com/viam-modules/object-tracking/tracking"
+)
+
+const (
+	// DefaultDirectionWeight is the weight of the direction consistency term of the association cost.
+	// It is off by default, so that detections are matched on their overlap alone.
+	DefaultDirectionWeight = 0.0
+	// directionFrames is how many observations back the direction of a track is measured.
+	directionFrames = 3
+	// minDirectionSpeed is the displacement, in pixels, under which a box is not considered moving.
+	minDirectionSpeed = 1.0
+)
+
+// center returns the center of the rectangle.
+func center(r image.Rectangle) (float64, float64) {
+	return float64(r.Min.X+r.Max.X) / 2, float64(r.Min.Y+r.Max.Y) / 2
+}
+
+// directionCost is the association cost when direction_weight is set: the opposite of the IOU of the
+// predicted and detected boxes, lowered when going from the last box of the track to the detection
+// follows the direction the track was moving in.
+type directionCost struct {
+	weight float64
+}
+
+// Cost implements tracking.CostFunction.
+func (c directionCost) Cost(tr *tracking.Track, predicted image.Rectangle, det tracking.Detection) float64 {
+	// cost is -IOU between bboxes (b/c solver will find min)
+	cost := -tracking.IOU(predicted, det.Box)
+	if x, y, ok := trackDirection(tr); ok {
+		// the last box of the history, which compensateMotion keeps in the coordinates of the
+		// latest frame
+		cost -= c.weight * directionConsistency(x, y, tr.History[len(tr.History)-1].Box, det.Box)
+	}
+	return cost
+}
+
+// trackDirection returns the unit vector of the direction the track moved in over its last few
+// observations, and false if the track has not moved enough for its direction to be known.
+func trackDirection(tr *tracking.Track) (float64, float64, bool) {
+	history := tr.History
+	if len(history) < 2 {
+		return 0, 0, false
+	}
+	back := min(directionFrames, len(history)-1)
+	return direction(history[len(history)-1-back].Box, history[len(history)-1].Box)
+}
+
+// direction returns the unit vector going from the center of a to the center of b, and false
+// if they are too close for the direction to be meaningful.
+func direction(a, b image.Rectangle) (float64, float64, bool) {
+	ax, ay := center(a)
+	bx, by := center(b)
+	dx, dy := bx-ax, by-ay
+	norm := math.Hypot(dx, dy)
+	if norm < minDirectionSpeed {
+		return 0, 0, false
+	}
+	return dx / norm, dy / norm, true
+}
+
+// directionConsistency scores how well going from the last box of a track to a detection follows
+// the direction the track was moving in, from 0.5 when it keeps going straight to -0.5 when it turns
+// back. It is 0 when either direction is unknown.
+func directionConsistency(trackX, trackY float64, last, detected image.Rectangle) float64 {
+	x, y, ok := direction(last, detected)
+	if !ok {
+		return 0
+	}
+	cos := max(-1, min(1, trackX*x+trackY*y))
+	return (math.Pi/2 - math.Acos(cos)) / math.Pi
+}
@@ -0,0 +1,146 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+func newOCSORTTracker(t *testing.T) *myTracker {
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+		directionWeight:     0.2,
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	return fakeTracker
+}
+
+// stepMatch steps the tracker with the detections, and returns the index of the detection the track
+// continues with, or -1 if it is lost.
+func stepMatch(fakeTracker *myTracker, tr *track, dets ...*track) int {
+	fakeTracker.step(dets)
+	et, ok := fakeTracker.tracks.Track(getTrackingLabel(tr))
+	if !ok {
+		return -1
+	}
+	return et.Detection
+}
+
+func TestDirectionConsistency(t *testing.T) {
+	last := image.Rect(0, 0, 10, 10)
+	test.That(t, directionConsistency(1, 0, last, last.Add(image.Pt(5, 0))), test.ShouldAlmostEqual, 0.5)
+	test.That(t, directionConsistency(1, 0, last, last.Add(image.Pt(-5, 0))), test.ShouldAlmostEqual, -0.5)
+	test.That(t, directionConsistency(1, 0, last, last.Add(image.Pt(0, 5))), test.ShouldAlmostEqual, 0)
+	// the detection did not move from the last box
+	test.That(t, directionConsistency(1, 0, last, last), test.ShouldEqual, 0)
+}
+
+func TestDirectionBreaksTies(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker := newOCSORTTracker(t)
+	// a track moving right by 2 pixels per frame
+	box := image.Rect(20, 20, 40, 40)
+	for k := range 3 {
+		fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box.Add(image.Pt(2*k, 0)), 0.9, LabelDet0)}))
+	}
+	tr := fakeTracker.lastDetections[0]
+	pred := fakeTracker.predictBox(tr)
+	test.That(t, pred, test.ShouldResemble, box.Add(image.Pt(6, 0)))
+
+	// both detections overlap the prediction as much, but only one keeps going the same way
+	behind := newTrack(objdet.NewDetection(bounds, pred.Add(image.Pt(-4, 0)), 0.9, LabelDet0))
+	ahead := newTrack(objdet.NewDetection(bounds, pred.Add(image.Pt(4, 0)), 0.9, LabelDet0))
+	test.That(t, stepMatch(fakeTracker, tr, behind, ahead), test.ShouldEqual, 1)
+}
+
+func TestDirectionUnderPan(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker := newOCSORTTracker(t)
+	// a track moving right by 2 pixels per frame, while the camera then pans by more than the object
+	box := image.Rect(60, 20, 80, 40)
+	for k := range 3 {
+		fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box.Add(image.Pt(2*k, 0)), 0.9, LabelDet0)}))
+	}
+	fakeTracker.compensateMotion(translation(-30, 0))
+	tr := fakeTracker.lastDetections[0]
+	pred := fakeTracker.predictBox(tr)
+	test.That(t, pred, test.ShouldResemble, box.Add(image.Pt(6-30, 0)))
+
+	// the direction is measured from the last box moved along with the camera, so the detection
+	// ahead keeps going the same way, though both are behind the box seen before the pan
+	behind := newTrack(objdet.NewDetection(bounds, pred.Add(image.Pt(-4, 0)), 0.9, LabelDet0))
+	ahead := newTrack(objdet.NewDetection(bounds, pred.Add(image.Pt(4, 0)), 0.9, LabelDet0))
+	test.That(t, stepMatch(fakeTracker, tr, behind, ahead), test.ShouldEqual, 1)
+}
+
+func TestDirectionIsOptIn(t *testing.T) {
+	test.That(t, getOnDemandTracker(t).directionWeight, test.ShouldEqual, 0)
+
+	bounds := image.Rect(0, 0, 200, 100)
+	box := image.Rect(20, 20, 40, 40)
+	associate := func(fakeTracker *myTracker) int {
+		for k := range 3 {
+			fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box.Add(image.Pt(2*k, 0)), 0.9, LabelDet0)}))
+		}
+		tr := fakeTracker.lastDetections[0]
+		pred := fakeTracker.predictBox(tr)
+		// the detection behind overlaps the prediction more, the one ahead keeps going the same way
+		behind := newTrack(objdet.NewDetection(bounds, pred.Add(image.Pt(-3, 0)), 0.9, LabelDet0))
+		ahead := newTrack(objdet.NewDetection(bounds, pred.Add(image.Pt(4, 0)), 0.9, LabelDet0))
+		return stepMatch(fakeTracker, tr, behind, ahead)
+	}
+	// by default, the association is on the overlap alone
+	defaultTracker := newOCSORTTracker(t)
+	defaultTracker.directionWeight = DefaultDirectionWeight
+	test.That(t, defaultTracker.configureTracks(), test.ShouldBeNil)
+	test.That(t, associate(defaultTracker), test.ShouldEqual, 0)
+	test.That(t, associate(newOCSORTTracker(t)), test.ShouldEqual, 1)
+}
+
+func TestOcclusionRecovery(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker := newOCSORTTracker(t)
+	box := image.Rect(0, 20, 20, 40)
+	at := func(frame int) image.Rectangle {
+		return box.Add(image.Pt(5*frame, 0))
+	}
+	for frame := range 4 {
+		fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, at(frame), 0.9, LabelDet0)}))
+	}
+	label := getTrackingLabel(fakeTracker.lastDetections[0])
+	// the object is hidden for 3 frames
+	for range 3 {
+		fakeTracker.step(nil)
+	}
+	lost, ok := fakeTracker.tracks.Track(label)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, lost.Misses, test.ShouldEqual, 3)
+	// the lost track is expected to keep moving while it is hidden
+	test.That(t, fakeTracker.predictBox(fakeTracker.lastSeen[label]), test.ShouldResemble, at(7))
+
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, at(7), 0.9, LabelDet0)}))
+	test.That(t, len(fakeTracker.lastDetections), test.ShouldEqual, 1)
+	tr := fakeTracker.lastDetections[0]
+	test.That(t, getTrackingLabel(tr), test.ShouldEqual, label)
+
+	// the gap is filled with virtual boxes moving at constant velocity
+	found, ok := fakeTracker.tracks.Track(label)
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, found.Misses, test.ShouldEqual, 0)
+	test.That(t, len(found.History), test.ShouldEqual, 8)
+	for frame, h := range found.History {
+		test.That(t, h.Box, test.ShouldResemble, at(frame))
+		test.That(t, h.Virtual, test.ShouldEqual, frame >= 4 && frame < 7)
+	}
+	// so the next prediction does not overshoot
+	test.That(t, fakeTracker.predictBox(tr), test.ShouldResemble, at(8))
+}
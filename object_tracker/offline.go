@@ -0,0 +1,310 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the offline mode, where the tracker runs frame by frame on recorded footage with
+// precomputed detections, and writes the tracks in the MOTChallenge CSV and JSONL formats.
+package object_tracker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/services/vision"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+)
+
+const (
+	// offlineDetectorName is the name of the stand-in detector serving the precomputed detections.
+	offlineDetectorName = "offline-detector"
+	// DefaultMOTClass is the class of MOTChallenge detections, which are pedestrians.
+	DefaultMOTClass = "person"
+)
+
+// OfflineOptions holds the inputs and outputs of an offline run.
+type OfflineOptions struct {
+	// Input is a directory of JPEG and PNG images, read in name order, or an MJPEG file
+	Input string
+	// Detections is a JSONL file with one {"frame": N, "detections": [...]} object per frame, in the
+	// format of the push command, or a MOTChallenge det.txt file
+	Detections string
+	// MOTClass is the class of the detections of a MOTChallenge file, which do not have any
+	MOTClass string
+	// Config holds the attributes of the tracker. The camera, detector and mode are ignored.
+	Config Config
+	// MOTOutput and JSONLOutput receive the stable tracks of each frame, and can be nil
+	MOTOutput   io.Writer
+	JSONLOutput io.Writer
+}
+
+// offlineFrame is a line of the JSONL input and output, where frames are numbered from 1.
+type offlineFrame struct {
+	Frame      int               `json:"frame"`
+	Detections []pushedDetection `json:"detections"`
+}
+
+// RunOffline runs the tracker on every frame of the input, with the precomputed detections of
+// each frame, and returns the number of frames tracked.
+func RunOffline(ctx context.Context, opts OfflineOptions, logger logging.Logger) (int, error) {
+	frames, err := readFrames(opts.Input)
+	if err != nil {
+		return 0, err
+	}
+	detections, err := readDetections(opts.Detections, opts.MOTClass)
+	if err != nil {
+		return 0, err
+	}
+
+	// the tracker runs on demand, with a detector that serves the detections of the current frame
+	frame := 0
+	detector, err := vision.NewService(
+		vision.Named(offlineDetectorName), nil, logger, nil, nil,
+		func(ctx context.Context, img image.Image) ([]objdet.Detection, error) {
+			out := make([]objdet.Detection, 0, len(detections[frame]))
+			for _, d := range detections[frame] {
+				out = append(out, objdet.NewDetection(img.Bounds(), image.Rect(d.XMin, d.YMin, d.XMax, d.YMax), d.Confidence, d.ClassName))
+			}
+			return out, nil
+		}, nil, "")
+	if err != nil {
+		return 0, err
+	}
+	cfg := opts.Config
+	cfg.CameraName, cfg.DetectorName, cfg.DetectorNames, cfg.Mode = "", offlineDetectorName, nil, ModeOnDemand
+	if _, _, err := cfg.Validate(""); err != nil {
+		return 0, err
+	}
+	conf := resource.Config{Name: "offline-tracker", API: vision.API, Model: Model, ConvertedAttributes: &cfg}
+	deps := resource.Dependencies{vision.Named(offlineDetectorName): detector}
+	svc, err := newTracker(ctx, deps, conf, logger)
+	if err != nil {
+		return 0, err
+	}
+	defer svc.Close(ctx)
+
+	out := newTrackWriter(opts.MOTOutput, opts.JSONLOutput)
+	n := 0
+	for img, err := range frames {
+		if err != nil {
+			return n, err
+		}
+		n++
+		frame = n
+		if _, err := svc.Detections(ctx, img, nil); err != nil {
+			return n, errors.Wrapf(err, "frame %d", n)
+		}
+		if err := out.write(n, svc.(*myTracker).stableTracks()); err != nil {
+			return n, err
+		}
+	}
+	return n, out.flush()
+}
+
+// trackWriter writes the stable tracks of each frame in the MOTChallenge CSV and JSONL formats.
+type trackWriter struct {
+	mot   *csv.Writer
+	jsonl *json.Encoder
+	// MOTChallenge IDs are integers, given to the tracks in the order they first appear. They are
+	// keyed by the tracking label, which a track keeps when it changes class.
+	ids map[string]int
+}
+
+// newTrackWriter returns a writer to either output, which can be nil.
+func newTrackWriter(mot, jsonl io.Writer) *trackWriter {
+	w := &trackWriter{ids: make(map[string]int)}
+	if mot != nil {
+		w.mot = csv.NewWriter(mot)
+	}
+	if jsonl != nil {
+		w.jsonl = json.NewEncoder(jsonl)
+	}
+	return w
+}
+
+// write writes the tracks of a frame, numbered from 1.
+func (w *trackWriter) write(n int, tracks []*track) error {
+	out := offlineFrame{Frame: n, Detections: make([]pushedDetection, 0, len(tracks))}
+	for _, tr := range tracks {
+		d := tr.Det
+		box := d.BoundingBox()
+		out.Detections = append(out.Detections, pushedDetection{
+			XMin: box.Min.X, YMin: box.Min.Y, XMax: box.Max.X, YMax: box.Max.Y,
+			ClassName: d.Label(), Confidence: d.Score(),
+		})
+		if w.mot == nil {
+			continue
+		}
+		id, ok := w.ids[getTrackingLabel(tr)]
+		if !ok {
+			id = len(w.ids) + 1
+			w.ids[getTrackingLabel(tr)] = id
+		}
+		err := w.mot.Write([]string{
+			strconv.Itoa(n), strconv.Itoa(id),
+			strconv.Itoa(box.Min.X), strconv.Itoa(box.Min.Y), strconv.Itoa(box.Dx()), strconv.Itoa(box.Dy()),
+			strconv.FormatFloat(d.Score(), 'f', -1, 64), "-1", "-1", "-1",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if w.jsonl != nil {
+		return w.jsonl.Encode(out)
+	}
+	return nil
+}
+
+// flush writes what is buffered.
+func (w *trackWriter) flush() error {
+	if w.mot == nil {
+		return nil
+	}
+	w.mot.Flush()
+	return w.mot.Error()
+}
+
+// readFrames returns the frames of a directory of images or of an MJPEG file, in order.
+func readFrames(input string) (iter.Seq2[image.Image, error], error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read input %v", input)
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(input)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read input %v", input)
+		}
+		return func(yield func(image.Image, error) bool) {
+			for _, frame := range splitMJPEG(data) {
+				img, err := jpeg.Decode(bytes.NewReader(frame))
+				if !yield(img, errors.Wrap(err, "unable to decode MJPEG frame")) || err != nil {
+					return
+				}
+			}
+		}, nil
+	}
+	entries, err := os.ReadDir(input)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read input %v", input)
+	}
+	var paths []string
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".jpg", ".jpeg", ".png":
+			paths = append(paths, filepath.Join(input, e.Name()))
+		}
+	}
+	slices.Sort(paths)
+	return func(yield func(image.Image, error) bool) {
+		for _, path := range paths {
+			img, err := rimage.NewImageFromFile(path)
+			if !yield(img, errors.Wrapf(err, "unable to decode %v", path)) || err != nil {
+				return
+			}
+		}
+	}, nil
+}
+
+// splitMJPEG splits a stream of concatenated JPEG images, which may have multipart headers between
+// them. A start of image marker only starts a new frame after the end of the previous image, so
+// that thumbnails embedded in a frame are left in it.
+func splitMJPEG(data []byte) [][]byte {
+	soi, eoi := []byte{0xff, 0xd8, 0xff}, []byte{0xff, 0xd9}
+	var frames [][]byte
+	start := bytes.Index(data, soi)
+	for start >= 0 {
+		end := -1
+		for from := start + len(soi); ; {
+			next := bytes.Index(data[from:], soi)
+			if next < 0 {
+				break
+			}
+			next += from
+			if bytes.Contains(data[start:next], eoi) {
+				end = next
+				break
+			}
+			from = next + len(soi)
+		}
+		if end < 0 {
+			frames = append(frames, data[start:])
+			break
+		}
+		frames = append(frames, data[start:end])
+		start = end
+	}
+	return frames
+}
+
+// readDetections returns the precomputed detections of each frame, by frame number from 1.
+func readDetections(path, motClass string) (map[int][]pushedDetection, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read detections %v", path)
+	}
+	defer f.Close()
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".txt" || ext == ".csv" {
+		if motClass == "" {
+			motClass = DefaultMOTClass
+		}
+		return readMOTDetections(f, motClass)
+	}
+	out := make(map[int][]pushedDetection)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var frame offlineFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, errors.Wrapf(err, "line %d of %v", line, path)
+		}
+		out[frame.Frame] = append(out[frame.Frame], frame.Detections...)
+	}
+	return out, scanner.Err()
+}
+
+// readMOTDetections reads detections in the MOTChallenge format: frame, id, left, top, width,
+// height and confidence, followed by unused columns.
+func readMOTDetections(r io.Reader, class string) (map[int][]pushedDetection, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read MOT detections")
+	}
+	out := make(map[int][]pushedDetection)
+	for i, rec := range records {
+		if len(rec) < 7 {
+			return nil, errors.Errorf("line %d of MOT detections must have at least 7 columns", i+1)
+		}
+		var values [7]float64
+		for k := range values {
+			if values[k], err = strconv.ParseFloat(rec[k], 64); err != nil {
+				return nil, errors.Wrapf(err, "line %d of MOT detections", i+1)
+			}
+		}
+		frame := int(values[0])
+		left, top := int(values[2]), int(values[3])
+		out[frame] = append(out[frame], pushedDetection{
+			XMin: left, YMin: top, XMax: left + int(values[4]), YMax: top + int(values[5]),
+			ClassName: class, Confidence: values[6],
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,139 @@
+package object_tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+)
+
+// writeWalkingPerson writes nFrames images and the detections of a person walking right, in the
+// JSONL format.
+func writeWalkingPerson(t *testing.T, dir string, nFrames int) string {
+	var lines []string
+	for i := range nFrames {
+		f, err := os.Create(filepath.Join(dir, fmt.Sprintf("frame_%03d.png", i)))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, png.Encode(f, image.NewRGBA(image.Rect(0, 0, 200, 100))), test.ShouldBeNil)
+		test.That(t, f.Close(), test.ShouldBeNil)
+		line, err := json.Marshal(offlineFrame{Frame: i + 1, Detections: []pushedDetection{
+			{XMin: 10 + 5*i, YMin: 20, XMax: 40 + 5*i, YMax: 80, ClassName: "person", Confidence: 0.9},
+		}})
+		test.That(t, err, test.ShouldBeNil)
+		lines = append(lines, string(line))
+	}
+	path := filepath.Join(t.TempDir(), "detections.jsonl")
+	test.That(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600), test.ShouldBeNil)
+	return path
+}
+
+func TestRunOffline(t *testing.T) {
+	dir := t.TempDir()
+	detections := writeWalkingPerson(t, dir, 5)
+	var mot, jsonl bytes.Buffer
+	frames, err := RunOffline(context.Background(), OfflineOptions{
+		Input:       dir,
+		Detections:  detections,
+		MOTOutput:   &mot,
+		JSONLOutput: &jsonl,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, frames, test.ShouldEqual, 5)
+
+	// the person is stable once it was matched on min_track_persistence frames
+	test.That(t, strings.Split(strings.TrimSpace(mot.String()), "\n"), test.ShouldResemble, []string{
+		"4,1,25,20,30,60,0.9,-1,-1,-1",
+		"5,1,30,20,30,60,0.9,-1,-1,-1",
+	})
+	lines := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	test.That(t, len(lines), test.ShouldEqual, 5)
+	var last offlineFrame
+	test.That(t, json.Unmarshal([]byte(lines[4]), &last), test.ShouldBeNil)
+	test.That(t, last.Frame, test.ShouldEqual, 5)
+	test.That(t, len(last.Detections), test.ShouldEqual, 1)
+	test.That(t, strings.HasPrefix(last.Detections[0].ClassName, "person_0_"), test.ShouldBeTrue)
+}
+
+func TestRunOfflineClassChange(t *testing.T) {
+	dir := t.TempDir()
+	detections := writeWalkingPerson(t, dir, 8)
+	data, err := os.ReadFile(detections)
+	test.That(t, err, test.ShouldBeNil)
+	// the person is detected as a cyclist from the sixth frame
+	lines := strings.Split(string(data), "\n")
+	for i := 5; i < len(lines); i++ {
+		lines[i] = strings.Replace(lines[i], `"person"`, `"cyclist"`, 1)
+	}
+	test.That(t, os.WriteFile(detections, []byte(strings.Join(lines, "\n")), 0o600), test.ShouldBeNil)
+
+	var mot, jsonl bytes.Buffer
+	_, err = RunOffline(context.Background(), OfflineOptions{
+		Input:       dir,
+		Detections:  detections,
+		Config:      Config{ClassPolicy: ClassPolicyLatest},
+		MOTOutput:   &mot,
+		JSONLOutput: &jsonl,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+
+	// the track changes class but keeps its MOTChallenge ID
+	rows := strings.Split(strings.TrimSpace(mot.String()), "\n")
+	test.That(t, len(rows), test.ShouldEqual, 5)
+	for _, row := range rows {
+		test.That(t, strings.Split(row, ",")[1], test.ShouldEqual, "1")
+	}
+	out := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	var last offlineFrame
+	test.That(t, json.Unmarshal([]byte(out[7]), &last), test.ShouldBeNil)
+	test.That(t, len(last.Detections), test.ShouldEqual, 1)
+	test.That(t, strings.HasPrefix(last.Detections[0].ClassName, "cyclist_0_"), test.ShouldBeTrue)
+}
+
+func TestSplitMJPEG(t *testing.T) {
+	var stream bytes.Buffer
+	for i := range 3 {
+		// multipart streams have a header before each frame
+		fmt.Fprintf(&stream, "--frame\r\nContent-Type: image/jpeg\r\n\r\n")
+		img := image.NewGray(image.Rect(0, 0, 8+i, 8))
+		test.That(t, jpeg.Encode(&stream, img, nil), test.ShouldBeNil)
+	}
+	path := filepath.Join(t.TempDir(), "video.mjpeg")
+	test.That(t, os.WriteFile(path, stream.Bytes(), 0o600), test.ShouldBeNil)
+
+	frames, err := readFrames(path)
+	test.That(t, err, test.ShouldBeNil)
+	var widths []int
+	for img, err := range frames {
+		test.That(t, err, test.ShouldBeNil)
+		widths = append(widths, img.Bounds().Dx())
+	}
+	test.That(t, widths, test.ShouldResemble, []int{8, 9, 10})
+}
+
+func TestReadMOTDetections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "det.txt")
+	content := "1,-1,10.5,20,30,60,0.9,-1,-1,-1\n1,-1,100,20,30,60,0.4,-1,-1,-1\n3,-1,12,20,30,60,0.8,-1,-1,-1\n"
+	test.That(t, os.WriteFile(path, []byte(content), 0o600), test.ShouldBeNil)
+	dets, err := readDetections(path, "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(dets[1]), test.ShouldEqual, 2)
+	test.That(t, dets[1][0], test.ShouldResemble, pushedDetection{
+		XMin: 10, YMin: 20, XMax: 40, YMax: 80, ClassName: DefaultMOTClass, Confidence: 0.9,
+	})
+	test.That(t, len(dets[2]), test.ShouldEqual, 0)
+	test.That(t, len(dets[3]), test.ShouldEqual, 1)
+
+	test.That(t, os.WriteFile(path, []byte("1,-1,10\n"), 0o600), test.ShouldBeNil)
+	_, err = readDetections(path, "")
+	test.That(t, err, test.ShouldNotBeNil)
+}
@@ -0,0 +1,143 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the on demand mode, where there is no background loop and each call to
+// Detections with an image advances the tracker by one frame.
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"time"
+
+	"github.com/pkg/errors"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+)
+
+const (
+	// ModeContinuous polls the camera in a background loop.
+	ModeContinuous = "continuous"
+	// ModeOnDemand tracks the images given to Detections, and has no background loop.
+	ModeOnDemand = "on_demand"
+
+	// SessionKey is the key of extra that selects an independent track state in on demand mode.
+	SessionKey = "session"
+	// DefaultMaxSessions is the number of sessions kept besides the default one. Starting another
+	// session ends the least recently used one.
+	DefaultMaxSessions = 16
+)
+
+// sessionName returns the session given in extra, or "" for the default session.
+func sessionName(extra map[string]interface{}) (string, error) {
+	session, ok := extra[SessionKey]
+	if !ok {
+		return "", nil
+	}
+	name, ok := session.(string)
+	if !ok {
+		return "", errors.Errorf("%q in extra must be a string", SessionKey)
+	}
+	return name, nil
+}
+
+// session returns the tracker holding the track state of the session in extra, and creates it on
+// first use. The default session is the tracker itself.
+func (t *myTracker) session(ctx context.Context, extra map[string]interface{}) (*myTracker, error) {
+	name, err := sessionName(extra)
+	if err != nil || name == "" {
+		return t, err
+	}
+	var evicted *myTracker
+	defer func() {
+		// once the lock is released, so that the other sessions are not held up
+		if evicted != nil {
+			evicted.stopSession()
+		}
+	}()
+	t.sessionsMutex.Lock()
+	defer t.sessionsMutex.Unlock()
+	if s, ok := t.sessions[name]; ok {
+		s.lastUsed = time.Now()
+		return s, nil
+	}
+	if len(t.sessions) >= max(t.maxSessions, 1) {
+		var oldest string
+		for other, s := range t.sessions {
+			if oldest == "" || s.lastUsed.Before(t.sessions[oldest].lastUsed) {
+				oldest = other
+			}
+		}
+		evicted = t.sessions[oldest]
+		delete(t.sessions, oldest)
+		t.logger.Infof("ending session %v, the least recently used of %d sessions", oldest, len(t.sessions)+1)
+	}
+	s := newTrackerState(t.Named, t.logger)
+	s.cancelFunc, s.cancelContext = t.cancelFunc, t.cancelContext
+	s.mode = t.mode
+	s.sessionName = name
+	if err := s.Reconfigure(ctx, t.deps, t.conf); err != nil {
+		return nil, errors.Wrapf(err, "unable to start session %v", name)
+	}
+	s.recorder = t.recorder
+	s.frequency = t.frequency
+	s.lastUsed = time.Now()
+	if t.sessions == nil {
+		t.sessions = make(map[string]*myTracker)
+	}
+	t.sessions[name] = s
+	return s, nil
+}
+
+// endSession forgets the track state of a session. It returns false if there was no such session.
+func (t *myTracker) endSession(name string) bool {
+	t.sessionsMutex.Lock()
+	s, ok := t.sessions[name]
+	delete(t.sessions, name)
+	t.sessionsMutex.Unlock()
+	if !ok {
+		return false
+	}
+	s.stopSession()
+	return true
+}
+
+// stopSession stops the background workers of a session that is no longer used, once its current
+// step is done.
+func (t *myTracker) stopSession() {
+	t.stepMutex.Lock()
+	defer t.stepMutex.Unlock()
+	if t.triggerCancelFunc != nil {
+		t.triggerCancelFunc()
+	}
+	t.activeBackgroundWorkers.Wait()
+}
+
+// trackImage runs the detector on the image, advances the tracker by one frame, and returns the
+// stable tracks. Calls on the same session are handled one at a time.
+func (t *myTracker) trackImage(ctx context.Context, img image.Image) ([]objdet.Detection, error) {
+	t.stepMutex.Lock()
+	defer t.stepMutex.Unlock()
+	start := time.Now()
+	if prevImg := t.currImg.Load(); prevImg != nil {
+		t.compensateMotion(t.estimateMotion(ctx, *prevImg, img, start.Sub(t.lastStep)))
+	}
+	t.lastStep = start
+	t.frameTime = time.Time{}
+	detections, err := t.detect(ctx, img)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't get detections")
+	}
+	t.currImg.Store(&img)
+	t.recordFrame(start, img, img.Bounds(), detections)
+	return t.stepDetections(detections, img.Bounds(), start), nil
+}
+
+// stepDetections advances the tracker by one frame with the detections of an image with the given
+// bounds, and returns the stable tracks.
+func (t *myTracker) stepDetections(detections []objdet.Detection, bounds image.Rectangle, start time.Time) []objdet.Detection {
+	filteredDets := t.filterDetections(detections, bounds)
+	t.step(newTracks(filteredDets))
+	t.timeStats.Record(time.Since(start))
+
+	t.currDetections.mutex.RLock()
+	defer t.currDetections.mutex.RUnlock()
+	return getStableDetections(t.currDetections.detections)
+}
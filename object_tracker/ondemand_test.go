@@ -0,0 +1,180 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"strings"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/testutils/inject"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+func getOnDemandTracker(t *testing.T) *myTracker {
+	// the detector finds a person at the x coordinate written in the first pixel of the image
+	detector := &inject.VisionService{
+		DetectionsFunc: func(ctx context.Context, img image.Image, extra map[string]interface{}) ([]objdet.Detection, error) {
+			r, _, _, _ := img.At(0, 0).RGBA()
+			x := int(r >> 8)
+			return []objdet.Detection{objdet.NewDetection(img.Bounds(), image.Rect(x, 20, x+30, 80), 0.9, "person")}, nil
+		},
+	}
+	conf := resource.Config{
+		Name:                "test-on-demand",
+		API:                 vision.API,
+		ConvertedAttributes: &Config{DetectorName: "detector", Mode: ModeOnDemand},
+	}
+	deps := resource.Dependencies{vision.Named("detector"): detector}
+	svc, err := newTracker(context.Background(), deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() { svc.Close(context.Background()) })
+	return svc.(*myTracker)
+}
+
+// personAt returns an image where the fake detector finds a person at x.
+func personAt(x int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	img.Pix[0] = uint8(x)
+	return img
+}
+
+func TestOnDemandTracking(t *testing.T) {
+	ctx := context.Background()
+	tracker := getOnDemandTracker(t)
+	test.That(t, tracker.cam, test.ShouldBeNil)
+
+	// nothing is tracked before images are given
+	dets, err := tracker.DetectionsFromCamera(ctx, "", nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, dets, test.ShouldBeNil)
+
+	// the person becomes stable once it persisted, and keeps its label while walking
+	var labels []string
+	for i := range DefaultMinTrackPersistence + 2 {
+		dets, err := tracker.Detections(ctx, personAt(10+5*i), nil)
+		test.That(t, err, test.ShouldBeNil)
+		for _, d := range dets {
+			labels = append(labels, d.Label())
+		}
+	}
+	test.That(t, len(labels), test.ShouldBeGreaterThan, 0)
+	for _, label := range labels {
+		test.That(t, label, test.ShouldEqual, labels[0])
+	}
+	test.That(t, strings.HasPrefix(labels[0], "person_0_"), test.ShouldBeTrue)
+	test.That(t, tracker.timeStats.Benchmark().NumberOfRuns, test.ShouldEqual, DefaultMinTrackPersistence+2)
+
+	_, err = tracker.Detections(ctx, nil, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestOnDemandSessions(t *testing.T) {
+	ctx := context.Background()
+	tracker := getOnDemandTracker(t)
+	cam2 := map[string]interface{}{SessionKey: "cam2"}
+
+	// the default session sees a person on the left, and the other one a person on the right
+	var left, right []objdet.Detection
+	for i := range DefaultMinTrackPersistence + 1 {
+		var err error
+		left, err = tracker.Detections(ctx, personAt(10+i), nil)
+		test.That(t, err, test.ShouldBeNil)
+		right, err = tracker.Detections(ctx, personAt(150+i), cam2)
+		test.That(t, err, test.ShouldBeNil)
+	}
+	test.That(t, len(left), test.ShouldEqual, 1)
+	test.That(t, len(right), test.ShouldEqual, 1)
+	test.That(t, left[0].BoundingBox().Min.X, test.ShouldBeLessThan, 100)
+	test.That(t, right[0].BoundingBox().Min.X, test.ShouldBeGreaterThan, 100)
+	// the sessions count their tracks independently
+	test.That(t, strings.HasPrefix(left[0].Label(), "person_0_"), test.ShouldBeTrue)
+	test.That(t, strings.HasPrefix(right[0].Label(), "person_0_"), test.ShouldBeTrue)
+
+	_, err := tracker.Detections(ctx, personAt(10), map[string]interface{}{SessionKey: 2})
+	test.That(t, err, test.ShouldNotBeNil)
+
+	out, err := tracker.DoCommand(ctx, map[string]interface{}{"end_session": "cam2"})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out["end_session"], test.ShouldBeTrue)
+	test.That(t, len(tracker.sessions), test.ShouldEqual, 0)
+	// a new session with the same name starts from scratch
+	right, err = tracker.Detections(ctx, personAt(150), cam2)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(right), test.ShouldEqual, 0)
+}
+
+func TestOnDemandSessionClassifications(t *testing.T) {
+	ctx := context.Background()
+	tracker := getOnDemandTracker(t)
+	cam2 := map[string]interface{}{SessionKey: "cam2"}
+	for i := range DefaultMinTrackPersistence + 1 {
+		_, err := tracker.Detections(ctx, personAt(150+i), cam2)
+		test.That(t, err, test.ShouldBeNil)
+	}
+
+	// only the session that saw the person reports a new object
+	classes, err := tracker.Classifications(ctx, nil, 1, cam2)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(classes), test.ShouldEqual, 1)
+	test.That(t, classes[0].Label(), test.ShouldEqual, NewObjectDetectedLabel)
+	classes, err = tracker.ClassificationsFromCamera(ctx, "", 1, cam2)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(classes), test.ShouldEqual, 1)
+	classes, err = tracker.Classifications(ctx, nil, 1, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, classes, test.ShouldBeEmpty)
+
+	_, err = tracker.Classifications(ctx, nil, 1, map[string]interface{}{SessionKey: 2})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestSessionLimit(t *testing.T) {
+	ctx := context.Background()
+	tracker := getOnDemandTracker(t)
+	tracker.maxSessions = 2
+	track := func(name string) *myTracker {
+		for i := range DefaultMinTrackPersistence + 1 {
+			_, err := tracker.Detections(ctx, personAt(10+i), map[string]interface{}{SessionKey: name})
+			test.That(t, err, test.ShouldBeNil)
+		}
+		return tracker.sessions[name]
+	}
+	a, b := track("a"), track("b")
+	// the new stable tracks started a cool down in the background
+	test.That(t, a.triggerContext.Err(), test.ShouldBeNil)
+	test.That(t, b.triggerContext.Err(), test.ShouldBeNil)
+
+	// a third session ends the least recently used one, and stops its workers
+	track("a")
+	track("c")
+	test.That(t, len(tracker.sessions), test.ShouldEqual, 2)
+	test.That(t, tracker.sessions["b"], test.ShouldBeNil)
+	test.That(t, b.triggerContext.Err(), test.ShouldNotBeNil)
+	test.That(t, a.triggerContext.Err(), test.ShouldBeNil)
+
+	// reconfiguring ends every session
+	test.That(t, tracker.Reconfigure(ctx, tracker.deps, tracker.conf), test.ShouldBeNil)
+	test.That(t, tracker.sessions, test.ShouldBeEmpty)
+	test.That(t, a.triggerContext.Err(), test.ShouldNotBeNil)
+	test.That(t, tracker.maxSessions, test.ShouldEqual, DefaultMaxSessions)
+}
+
+func TestValidateOnDemand(t *testing.T) {
+	cfg := Config{DetectorName: "detector", Mode: ModeOnDemand}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"detector"})
+
+	cfg.Pipelined = true
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	continuous := Config{DetectorName: "detector", Mode: ModeContinuous}
+	_, _, err = continuous.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "camera_name")
+}
@@ -0,0 +1,392 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the overlay camera, which draws the tracks of a tracker on its current frame,
+// with a colour and a short ID per track, motion trails, predicted boxes and zones.
+package object_tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/pointcloud"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/rimage"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/spatialmath"
+	"go.viam.com/rdk/utils"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/viscapture"
+	"golang.org/x/image/font"
+)
+
+const (
+	// OverlayModelName is the name of the overlay camera model.
+	OverlayModelName = "object-tracker-overlay"
+	// OverlayKey is the extra of CaptureAllFromCamera that asks the tracker for what the overlay
+	// camera draws besides the boxes.
+	OverlayKey = "overlay"
+	// DefaultOverlayTrailLength is the number of past centers drawn behind each track.
+	DefaultOverlayTrailLength = 30
+
+	// overlaySourceName is the source name of the image of the overlay camera.
+	overlaySourceName = "overlay"
+	overlayLineWidth  = 2.0
+	overlayFontSize   = 14.0
+)
+
+// OverlayModel is the model of the camera that draws the tracks of a tracker.
+var OverlayModel = resource.NewModel("viam", "camera", OverlayModelName)
+
+var (
+	includeRegionColor = color.NRGBA{0, 200, 0, 255}
+	excludeRegionColor = color.NRGBA{220, 0, 0, 255}
+	zoneColor          = color.NRGBA{0, 140, 255, 255}
+	lineColor          = color.NRGBA{255, 210, 0, 255}
+)
+
+func init() {
+	resource.RegisterComponent(camera.API, OverlayModel, resource.Registration[camera.Camera, *OverlayConfig]{
+		Constructor: newOverlayCamera,
+	})
+}
+
+// OverlayConfig holds the attributes of the overlay camera.
+type OverlayConfig struct {
+	TrackerName string        `json:"tracker_name"`
+	TrailLength int           `json:"trail_length,omitempty"`
+	Zones       [][][]float64 `json:"zones,omitempty"`
+	Lines       [][][]float64 `json:"lines,omitempty"`
+}
+
+// Validate validates the config and returns implicit dependencies.
+func (cfg *OverlayConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.TrackerName == "" {
+		return nil, nil, fmt.Errorf(`expected "tracker_name" attribute for object tracker overlay %q`, path)
+	}
+	if cfg.TrailLength < 0 {
+		return nil, nil, errors.New("attribute trail_length cannot be less than 0")
+	}
+	if err := validateRegions("zones", cfg.Zones); err != nil {
+		return nil, nil, err
+	}
+	for _, line := range cfg.Lines {
+		if len(line) < 2 {
+			return nil, nil, errors.New("each line of attribute lines must have at least 2 points")
+		}
+		// lines have the points of regions, but can have only 2 of them
+		if err := validateRegions("lines", [][][]float64{append(line, line[0])}); err != nil {
+			return nil, nil, err
+		}
+	}
+	return []string{cfg.TrackerName}, nil, nil
+}
+
+// overlayState is what the tracker gives the overlay camera besides its image and boxes. The
+// regions are in normalized coordinates.
+type overlayState struct {
+	// Trails holds the centers of the history of each stable track, oldest first, by label
+	Trails         map[string][][2]float64 `json:"trails"`
+	IncludeRegions [][][]float64           `json:"include_regions,omitempty"`
+	ExcludeRegions [][][]float64           `json:"exclude_regions,omitempty"`
+}
+
+// trails returns the centers of the history of each stable track, by label.
+func (t *myTracker) trails(tracks []*track) map[string][][2]float64 {
+	out := make(map[string][][2]float64, len(tracks))
+	for _, tr := range tracks {
+		if !tr.stable {
+			continue
+		}
+		et, _ := t.tracks.Track(getTrackingLabel(tr))
+		trail := make([][2]float64, 0, len(et.History))
+		for _, h := range et.History {
+			x, y := center(h.Box)
+			trail = append(trail, [2]float64{x, y})
+		}
+		out[tr.Det.Label()] = trail
+	}
+	return out
+}
+
+// overlayState returns the state of the overlay in the types of the protobuf structs extras are
+// sent as, so that it reaches the overlay camera whether it runs in this module or not.
+func (t *myTracker) overlayState(trails map[string][][2]float64) interface{} {
+	state := overlayState{Trails: trails}
+	if t.regions != nil {
+		state.IncludeRegions, state.ExcludeRegions = polygonsToConfig(t.regions.include), polygonsToConfig(t.regions.exclude)
+	}
+	var out interface{}
+	if err := convertJSON(state, &out); err != nil {
+		t.logger.Warnw("unable to convert the overlay state", "error", err)
+		return nil
+	}
+	return out
+}
+
+// polygonsToConfig returns normalized polygons as in the config.
+func polygonsToConfig(polygons []polygon) [][][]float64 {
+	out := make([][][]float64, 0, len(polygons))
+	for _, poly := range polygons {
+		points := make([][]float64, 0, len(poly))
+		for _, p := range poly {
+			points = append(points, []float64{p.x, p.y})
+		}
+		out = append(out, points)
+	}
+	return out
+}
+
+// convertJSON converts in to the type of out through JSON.
+func convertJSON(in, out interface{}) error {
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// overlayCamera is a camera serving the current frame of a tracker with its tracks drawn on it.
+type overlayCamera struct {
+	resource.Named
+	resource.AlwaysRebuild
+	resource.TriviallyCloseable
+	logger logging.Logger
+
+	tracker     vision.Service
+	trailLength int
+	zones       []polygon
+	lines       []polygon
+
+	// face draws the IDs. It caches glyphs and is not safe for concurrent use, so renders draw
+	// their tracks one at a time
+	faceMutex sync.Mutex
+	face      font.Face
+}
+
+func newOverlayCamera(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (camera.Camera, error) {
+	cfg, err := resource.NativeConfig[*OverlayConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	tracker, err := vision.FromDependencies(deps, cfg.TrackerName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no tracker %q", cfg.TrackerName)
+	}
+	c := &overlayCamera{
+		Named:       conf.ResourceName().AsNamed(),
+		logger:      logger,
+		tracker:     tracker,
+		trailLength: cfg.TrailLength,
+		zones:       newPolygons(cfg.Zones),
+		lines:       newPolygons(cfg.Lines),
+		face:        truetype.NewFace(rimage.Font(), &truetype.Options{Size: overlayFontSize}),
+	}
+	if c.trailLength == 0 {
+		c.trailLength = DefaultOverlayTrailLength
+	}
+	return c, nil
+}
+
+// Images returns the current frame of the tracker with its tracks drawn on it.
+func (c *overlayCamera) Images(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+	if len(filterSourceNames) > 0 && !slices.Contains(filterSourceNames, overlaySourceName) {
+		return nil, resource.ResponseMetadata{}, errors.Errorf("the overlay camera only has the source %q", overlaySourceName)
+	}
+	img, err := c.render(ctx)
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	namedImage, err := camera.NamedImageFromImage(img, overlaySourceName, utils.MimeTypeJPEG, data.Annotations{})
+	if err != nil {
+		return nil, resource.ResponseMetadata{}, err
+	}
+	return []camera.NamedImage{namedImage}, resource.ResponseMetadata{CapturedAt: time.Now()}, nil
+}
+
+// Image returns the current frame of the tracker with its tracks drawn on it, encoded as a JPEG by
+// default.
+func (c *overlayCamera) Image(ctx context.Context, mimeType string, extra map[string]interface{}) ([]byte, camera.ImageMetadata, error) {
+	img, err := c.render(ctx)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	if mimeType == "" {
+		mimeType = utils.MimeTypeJPEG
+	}
+	mimeType = strings.TrimSuffix(mimeType, "+"+utils.MimeTypeSuffixLazy)
+	out, err := rimage.EncodeImage(ctx, img, mimeType)
+	if err != nil {
+		return nil, camera.ImageMetadata{}, err
+	}
+	return out, camera.ImageMetadata{MimeType: mimeType}, nil
+}
+
+func (c *overlayCamera) NextPointCloud(ctx context.Context, extra map[string]interface{}) (pointcloud.PointCloud, error) {
+	return nil, errUnimplemented
+}
+
+func (c *overlayCamera) Properties(ctx context.Context) (camera.Properties, error) {
+	return camera.Properties{ImageType: camera.ColorStream, MimeTypes: []string{utils.MimeTypeJPEG}}, nil
+}
+
+func (c *overlayCamera) Geometries(ctx context.Context, extra map[string]interface{}) ([]spatialmath.Geometry, error) {
+	return nil, nil
+}
+
+// render draws the tracks of the tracker on its current frame.
+func (c *overlayCamera) render(ctx context.Context) (image.Image, error) {
+	capture, err := c.tracker.CaptureAllFromCamera(ctx, "",
+		viscapture.CaptureOptions{ReturnImage: true, ReturnDetections: true},
+		map[string]interface{}{OverlayKey: true})
+	if err != nil {
+		return nil, err
+	}
+	if capture.Image == nil {
+		return nil, errors.New("the tracker has not tracked a frame yet")
+	}
+	var state overlayState
+	if err := convertJSON(capture.Extra[OverlayKey], &state); err != nil {
+		return nil, errors.Wrap(err, "invalid overlay state from the tracker")
+	}
+	var coasting []string
+	if err := convertJSON(capture.Extra["coasting"], &coasting); err != nil {
+		return nil, errors.Wrap(err, "invalid coasting tracks from the tracker")
+	}
+
+	dc := gg.NewContextForImage(capture.Image)
+	bounds := capture.Image.Bounds()
+	dc.SetLineWidth(overlayLineWidth)
+	// zones go under the tracks
+	for _, region := range newPolygons(state.IncludeRegions) {
+		drawPolyline(dc, region.toPixels(bounds), includeRegionColor, true)
+	}
+	for _, region := range newPolygons(state.ExcludeRegions) {
+		drawPolyline(dc, region.toPixels(bounds), excludeRegionColor, true)
+	}
+	for _, zone := range c.zones {
+		drawPolyline(dc, zone.toPixels(bounds), zoneColor, true)
+	}
+	for _, line := range c.lines {
+		drawPolyline(dc, line.toPixels(bounds), lineColor, false)
+	}
+
+	dets := slices.Clone(capture.Detections)
+	slices.SortFunc(dets, func(a, b objdet.Detection) int { return strings.Compare(a.Label(), b.Label()) })
+	c.faceMutex.Lock()
+	defer c.faceMutex.Unlock()
+	dc.SetFontFace(c.face)
+	for _, d := range dets {
+		trail := state.Trails[d.Label()]
+		c.drawTrack(dc, d, trail[max(0, len(trail)-c.trailLength):], slices.Contains(coasting, d.Label()))
+	}
+	return dc.Image(), nil
+}
+
+// drawTrack draws the trail, box and short ID of a track. The box of a coasting track is its
+// predicted box, and is dashed.
+func (c *overlayCamera) drawTrack(dc *gg.Context, d objdet.Detection, trail [][2]float64, coasting bool) {
+	id := shortTrackID(d.Label())
+	col := trackColor(id)
+	dc.SetColor(col)
+	if len(trail) > 1 {
+		for _, p := range trail {
+			dc.LineTo(p[0], p[1])
+		}
+		dc.Stroke()
+	}
+
+	box := d.BoundingBox()
+	if coasting {
+		dc.SetDash(6, 4)
+	}
+	dc.DrawRectangle(float64(box.Min.X), float64(box.Min.Y), float64(box.Dx()), float64(box.Dy()))
+	dc.Stroke()
+	dc.SetDash()
+
+	// the ID is on a tab above the box, or inside it at the top of the image
+	w, h := dc.MeasureString(id)
+	w, h = w+4, h+4
+	x, y := float64(box.Min.X), float64(box.Min.Y)-h
+	if y < 0 {
+		y = float64(box.Min.Y)
+	}
+	dc.DrawRectangle(x, y, w, h)
+	dc.Fill()
+	dc.SetColor(textColor(col))
+	dc.DrawStringAnchored(id, x+2, y+2, 0, 1)
+}
+
+// drawPolyline draws the points of a zone or line, and closes the zone.
+func drawPolyline(dc *gg.Context, points polygon, col color.Color, closed bool) {
+	dc.SetColor(col)
+	for _, p := range points {
+		dc.LineTo(p.x, p.y)
+	}
+	if closed {
+		dc.ClosePath()
+	}
+	dc.Stroke()
+}
+
+// shortTrackID returns the ID of a track without the time it was first seen, such as "person 3".
+// Labels are of the form class_N_YYYYMMDD_HHMMSS, where the class was cut at its first underscore
+// when the track was named, like chosen_labels does.
+func shortTrackID(label string) string {
+	parts := strings.Split(label, "_")
+	if len(parts) < 2 {
+		return label
+	}
+	return parts[0] + " " + parts[1]
+}
+
+// trackColor returns a bright colour of its own for each ID, which is the same on every frame.
+func trackColor(id string) color.NRGBA {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return hsvColor(float64(h.Sum32()%360), 0.8, 0.95)
+}
+
+// hsvColor converts a hue in degrees, and a saturation and value between 0 and 1.
+func hsvColor(hue, s, v float64) color.NRGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g = c, x
+	case hue < 120:
+		r, g = x, c
+	case hue < 180:
+		g, b = c, x
+	case hue < 240:
+		g, b = x, c
+	case hue < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := v - c
+	return color.NRGBA{uint8(255 * (r + m)), uint8(255 * (g + m)), uint8(255 * (b + m)), 255}
+}
+
+// textColor returns black or white, whichever reads best on the colour.
+func textColor(bg color.NRGBA) color.Color {
+	if 0.299*float64(bg.R)+0.587*float64(bg.G)+0.114*float64(bg.B) > 150 {
+		return color.Black
+	}
+	return color.White
+}
@@ -0,0 +1,117 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/rdk/vision/viscapture"
+	"go.viam.com/test"
+)
+
+func TestOverlayConfig(t *testing.T) {
+	cfg := OverlayConfig{TrackerName: "tracker", Lines: [][][]float64{{{0, 0.5}, {1, 0.5}}}}
+	deps, _, err := cfg.Validate("overlay")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, deps, test.ShouldResemble, []string{"tracker"})
+
+	for _, bad := range []OverlayConfig{
+		{},
+		{TrackerName: "tracker", TrailLength: -1},
+		{TrackerName: "tracker", Zones: [][][]float64{{{0, 0}, {1, 1}}}},
+		{TrackerName: "tracker", Lines: [][][]float64{{{0, 0}}}},
+		{TrackerName: "tracker", Lines: [][][]float64{{{0, 0}, {2, 1}}}},
+	} {
+		_, _, err := bad.Validate("overlay")
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+}
+
+func TestOverlayCamera(t *testing.T) {
+	ctx := context.Background()
+	tracker := getOnDemandTracker(t)
+	conf := resource.Config{
+		Name:                "overlay",
+		API:                 camera.API,
+		Model:               OverlayModel,
+		ConvertedAttributes: &OverlayConfig{TrackerName: "tracker", Zones: [][][]float64{{{0.5, 0}, {1, 0}, {1, 1}, {0.5, 1}}}},
+	}
+	deps := resource.Dependencies{vision.Named("tracker"): tracker}
+	cam, err := newOverlayCamera(ctx, deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+
+	// there is nothing to draw before the first frame
+	_, _, err = cam.Images(ctx, nil, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	frames := DefaultMinTrackPersistence + 2
+	for i := range frames {
+		_, err := tracker.Detections(ctx, personAt(10+5*i), nil)
+		test.That(t, err, test.ShouldBeNil)
+	}
+	capture, err := tracker.CaptureAllFromCamera(ctx, "", viscapture.CaptureOptions{ReturnDetections: true}, map[string]interface{}{OverlayKey: true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(capture.Detections), test.ShouldEqual, 1)
+	label := capture.Detections[0].Label()
+	var state overlayState
+	test.That(t, convertJSON(capture.Extra[OverlayKey], &state), test.ShouldBeNil)
+	// the trail holds the center of the person on every frame it was tracked
+	trail := state.Trails[label]
+	test.That(t, len(trail), test.ShouldEqual, frames)
+	test.That(t, trail[0], test.ShouldResemble, [2]float64{25, 50})
+	test.That(t, trail[frames-1], test.ShouldResemble, [2]float64{float64(25 + 5*(frames-1)), 50})
+
+	imgs, _, err := cam.Images(ctx, nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(imgs), test.ShouldEqual, 1)
+	test.That(t, imgs[0].SourceName, test.ShouldEqual, overlaySourceName)
+	img, err := imgs[0].Image(ctx)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, img.Bounds(), test.ShouldResemble, image.Rect(0, 0, 200, 100))
+
+	// the box is drawn in the colour of the track, and the zone in its own
+	box := capture.Detections[0].BoundingBox()
+	test.That(t, toNRGBA(img.At(box.Min.X, 60)), test.ShouldResemble, trackColor(shortTrackID(label)))
+	test.That(t, toNRGBA(img.At(100, 50)), test.ShouldResemble, zoneColor)
+
+	_, _, err = cam.Images(ctx, []string{"color"}, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+	data, meta, err := cam.Image(ctx, "", nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, meta.MimeType, test.ShouldEqual, "image/jpeg")
+	test.That(t, len(data), test.ShouldBeGreaterThan, 0)
+}
+
+func TestTrackColor(t *testing.T) {
+	test.That(t, shortTrackID("person_3_20240101_120000"), test.ShouldEqual, "person 3")
+	test.That(t, shortTrackID("person"), test.ShouldEqual, "person")
+	test.That(t, trackColor("person 3"), test.ShouldResemble, trackColor("person 3"))
+	test.That(t, trackColor("person 3"), test.ShouldNotResemble, trackColor("person 4"))
+}
+
+func TestShortTrackIDOfUnderscoredClass(t *testing.T) {
+	// the tracker names a track after the class of its detection up to the first underscore
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, image.Rect(10, 10, 30, 30), 0.9, "traffic_light")}))
+	test.That(t, len(fakeTracker.lastDetections), test.ShouldEqual, 1)
+	test.That(t, shortTrackID(fakeTracker.lastDetections[0].Det.Label()), test.ShouldEqual, "traffic 0")
+}
+
+func toNRGBA(c color.Color) color.NRGBA {
+	return color.NRGBAModel.Convert(c).(color.NRGBA)
+}
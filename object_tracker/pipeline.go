@@ -0,0 +1,228 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the pipelined mode, where frame capture, detection and tracking run as
+// separate stages connected by bounded queues.
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"sync/atomic"
+	"time"
+
+	"go.viam.com/rdk/components/camera"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	viamutils "go.viam.com/utils"
+)
+
+// DefaultPipelineQueueSize is the number of frames that can wait between two stages.
+const DefaultPipelineQueueSize = 1
+
+const (
+	captureStage = "capture"
+	detectStage  = "detect"
+	trackStage   = "track"
+	// endToEnd is not a stage: it holds the latency from capture to tracked detections.
+	endToEnd = "end_to_end"
+)
+
+// frame is the unit of work passed between stages of the pipeline.
+type frame struct {
+	seq        int64
+	capturedAt time.Time
+	img        image.Image
+	detections []objdet.Detection
+}
+
+// dropOldestQueue is a bounded queue that never blocks the producer: when it is full,
+// the oldest item is dropped to make room for the new one.
+type dropOldestQueue struct {
+	items   chan *frame
+	dropped atomic.Int64
+}
+
+func newDropOldestQueue(size int) *dropOldestQueue {
+	return &dropOldestQueue{items: make(chan *frame, size)}
+}
+
+// push adds the frame to the queue, dropping the oldest frames until there is room for it.
+func (q *dropOldestQueue) push(f *frame) {
+	for {
+		select {
+		case q.items <- f:
+			return
+		default:
+		}
+		select {
+		case <-q.items:
+			q.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// stageStats holds the statistics of a single stage of the pipeline.
+type stageStats struct {
+	latency   *latencyHistogram
+	processed atomic.Int64
+	dropped   atomic.Int64
+	lastSeq   atomic.Int64
+}
+
+// stageReport is the summary of a stage returned by DoCommand.
+type stageReport struct {
+	Latency   benchmark
+	Processed int64
+	Dropped   int64
+	LastSeq   int64
+}
+
+func (s *stageStats) report() stageReport {
+	return stageReport{
+		Latency:   s.latency.Benchmark(),
+		Processed: s.processed.Load(),
+		Dropped:   s.dropped.Load(),
+		LastSeq:   s.lastSeq.Load(),
+	}
+}
+
+// pipelineStats holds the statistics of every stage of the pipeline.
+type pipelineStats map[string]*stageStats
+
+func newPipelineStats() pipelineStats {
+	stats := make(pipelineStats)
+	for _, stage := range []string{captureStage, detectStage, trackStage, endToEnd} {
+		stats[stage] = &stageStats{latency: newLatencyHistogram()}
+	}
+	return stats
+}
+
+func (ps pipelineStats) report() map[string]stageReport {
+	out := make(map[string]stageReport, len(ps))
+	for stage, stats := range ps {
+		out[stage] = stats.report()
+	}
+	return out
+}
+
+// startPipeline starts the capture, detection and tracking stages in the background.
+// Frames dropped from a queue are counted against the stage that produced them.
+func (t *myTracker) startPipeline(ctx context.Context) {
+	captured := newDropOldestQueue(t.pipelineQueueSize)
+	detected := newDropOldestQueue(t.pipelineQueueSize)
+	stages := []func(){
+		func() { t.runCaptureStage(ctx, captured) },
+		func() { t.runDetectStage(ctx, captured, detected) },
+		func() { t.runTrackStage(ctx, detected) },
+	}
+	for _, stage := range stages {
+		t.activeBackgroundWorkers.Add(1)
+		viamutils.ManagedGo(stage, func() {
+			t.cancelFunc()
+			t.activeBackgroundWorkers.Done()
+		})
+	}
+}
+
+// runCaptureStage grabs frames from the camera no faster than the configured frequency.
+func (t *myTracker) runCaptureStage(ctx context.Context, out *dropOldestQueue) {
+	stats := t.pipelineStats[captureStage]
+	var seq int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		start := time.Now()
+		img, err := camera.DecodeImageFromCamera(ctx, t.cam, nil, nil)
+		if err != nil {
+			t.logger.Errorf("can't get image. got err: %s", err)
+			continue
+		}
+		if img == nil {
+			t.logger.Errorf("got nil image")
+			continue
+		}
+		seq++
+		before := out.dropped.Load()
+		out.push(&frame{seq: seq, capturedAt: start, img: img})
+		stats.dropped.Add(out.dropped.Load() - before)
+		stats.processed.Add(1)
+		stats.lastSeq.Store(seq)
+		took := time.Since(start)
+		stats.latency.Record(took)
+
+		waitFor := time.Duration((1/t.frequency)*float64(time.Second)) - took
+		if waitFor > time.Microsecond {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(waitFor):
+			}
+		}
+	}
+}
+
+// runDetectStage runs the detector on the most recent captured frames.
+func (t *myTracker) runDetectStage(ctx context.Context, in, out *dropOldestQueue) {
+	stats := t.pipelineStats[detectStage]
+	for {
+		var f *frame
+		select {
+		case <-ctx.Done():
+			return
+		case f = <-in.items:
+		}
+		start := time.Now()
+		detections, err := t.detect(ctx, f.img)
+		if err != nil {
+			t.logger.Errorf("can't get detections. got err: %s", err)
+			continue
+		}
+		f.detections = detections
+		before := out.dropped.Load()
+		out.push(f)
+		stats.dropped.Add(out.dropped.Load() - before)
+		stats.processed.Add(1)
+		stats.lastSeq.Store(f.seq)
+		stats.latency.Record(time.Since(start))
+	}
+}
+
+// runTrackStage associates the detections of each frame with the existing tracks, in sequence order.
+func (t *myTracker) runTrackStage(ctx context.Context, in *dropOldestQueue) {
+	stats := t.pipelineStats[trackStage]
+	var prev *frame
+	for {
+		var f *frame
+		select {
+		case <-ctx.Done():
+			return
+		case f = <-in.items:
+		}
+		if f.seq <= stats.lastSeq.Load() {
+			// never step the tracker back in time
+			stats.dropped.Add(1)
+			continue
+		}
+		start := time.Now()
+		if prev != nil {
+			t.compensateMotion(t.estimateMotion(ctx, prev.img, f.img, f.capturedAt.Sub(prev.capturedAt)))
+		}
+		prev = f
+		t.recordFrame(f.capturedAt, f.img, f.img.Bounds(), f.detections)
+		filteredDets := t.filterDetections(f.detections, f.img.Bounds())
+		t.step(newTracks(filteredDets))
+		t.currImg.Store(&f.img)
+		stats.processed.Add(1)
+		stats.lastSeq.Store(f.seq)
+		took := time.Since(start)
+		stats.latency.Record(took)
+		// like in the sequential loop, benchmark times a tracking iteration
+		t.timeStats.Record(took)
+		e2e := t.pipelineStats[endToEnd]
+		e2e.processed.Add(1)
+		e2e.lastSeq.Store(f.seq)
+		e2e.latency.Record(time.Since(f.capturedAt))
+	}
+}
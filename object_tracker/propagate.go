@@ -0,0 +1,194 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the propagation of bounding boxes between frames without running the detector,
+// by template matching with normalized cross-correlation.
+package object_tracker
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// DefaultPropagationRadius is how far (in pixels) a box is searched for around its predicted position.
+	DefaultPropagationRadius = 24
+	// minPropagationScore is the lowest correlation for which a propagated box is trusted.
+	minPropagationScore = 0.5
+	// maxTemplateSide is the number of samples along the longest side of a template.
+	maxTemplateSide = 32
+)
+
+// grayRegion is the luminance of a region of an image.
+type grayRegion struct {
+	rect image.Rectangle
+	pix  []float64
+}
+
+// luminance returns a function that reads the luminance of the pixels of img, with fast paths
+// for the image types cameras usually decode to.
+func luminance(img image.Image) func(x, y int) float64 {
+	switch src := img.(type) {
+	case *image.YCbCr:
+		return func(x, y int) float64 {
+			return float64(src.Y[src.YOffset(x, y)])
+		}
+	case *image.Gray:
+		return func(x, y int) float64 {
+			return float64(src.Pix[src.PixOffset(x, y)])
+		}
+	case *image.RGBA:
+		return func(x, y int) float64 {
+			pix := src.Pix[src.PixOffset(x, y):]
+			return 0.299*float64(pix[0]) + 0.587*float64(pix[1]) + 0.114*float64(pix[2])
+		}
+	default:
+		return func(x, y int) float64 {
+			return float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+		}
+	}
+}
+
+// newGrayRegion converts the part of img within r to grayscale.
+func newGrayRegion(img image.Image, r image.Rectangle) *grayRegion {
+	r = r.Intersect(img.Bounds())
+	g := &grayRegion{rect: r, pix: make([]float64, r.Dx()*r.Dy())}
+	lum := luminance(img)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			g.pix[(y-r.Min.Y)*r.Dx()+x-r.Min.X] = lum(x, y)
+		}
+	}
+	return g
+}
+
+// at returns the luminance of the pixel at (x, y), which must be within the region.
+func (g *grayRegion) at(x, y int) float64 {
+	return g.pix[(y-g.rect.Min.Y)*g.rect.Dx()+x-g.rect.Min.X]
+}
+
+// template is a subsampled patch of an image, normalized to zero mean.
+type template struct {
+	offsets []image.Point
+	values  []float64
+	norm    float64
+}
+
+// newTemplate samples the region r of g every step pixels.
+func newTemplate(g *grayRegion, r image.Rectangle, step int) *template {
+	t := &template{}
+	sum := 0.0
+	for y := r.Min.Y; y < r.Max.Y; y += step {
+		for x := r.Min.X; x < r.Max.X; x += step {
+			t.offsets = append(t.offsets, image.Pt(x-r.Min.X, y-r.Min.Y))
+			v := g.at(x, y)
+			t.values = append(t.values, v)
+			sum += v
+		}
+	}
+	mean := sum / float64(len(t.values))
+	for i := range t.values {
+		t.values[i] -= mean
+		t.norm += t.values[i] * t.values[i]
+	}
+	t.norm = math.Sqrt(t.norm)
+	return t
+}
+
+// ncc returns the normalized cross-correlation of the template placed with its top left corner at
+// origin in g, between -1 and 1. Flat patches have a correlation of 0.
+func (t *template) ncc(g *grayRegion, origin image.Point) float64 {
+	if t.norm == 0 {
+		return 0
+	}
+	sum, sumSq, cross := 0.0, 0.0, 0.0
+	for i, off := range t.offsets {
+		v := g.at(origin.X+off.X, origin.Y+off.Y)
+		sum += v
+		sumSq += v * v
+		cross += v * t.values[i]
+	}
+	n := float64(len(t.offsets))
+	variance := sumSq - sum*sum/n
+	if variance <= 0 {
+		return 0
+	}
+	// the template has zero mean, so the mean of the patch does not change the cross term
+	return cross / (t.norm * math.Sqrt(variance))
+}
+
+// propagateBox looks for the content of box in prev within curr, searching within radius pixels
+// around start. It returns the best matching box and its correlation with the original content.
+func propagateBox(prev, curr image.Image, box, start image.Rectangle, radius int) (image.Rectangle, float64) {
+	tb := box.Intersect(prev.Bounds())
+	if tb.Dx() < 4 || tb.Dy() < 4 {
+		return start, 0
+	}
+	step := max(1, max(tb.Dx(), tb.Dy())/maxTemplateSide)
+	tmpl := newTemplate(newGrayRegion(prev, tb), tb, step)
+
+	// the template is searched around where the prediction puts it
+	shift := start.Min.Sub(box.Min)
+	center := tb.Min.Add(shift)
+	search := image.Rectangle{Min: center, Max: center.Add(tb.Size())}.Inset(-radius)
+	g := newGrayRegion(curr, search)
+	fits := func(origin image.Point) bool {
+		return image.Rectangle{Min: origin, Max: origin.Add(tb.Size())}.In(g.rect)
+	}
+
+	best, bestScore := image.Point{}, math.Inf(-1)
+	try := func(d image.Point) {
+		origin := center.Add(d)
+		if !fits(origin) {
+			return
+		}
+		if score := tmpl.ncc(g, origin); score > bestScore {
+			best, bestScore = d, score
+		}
+	}
+	// coarse search on the template grid, then refine around the best position
+	for dy := -radius; dy <= radius; dy += step {
+		for dx := -radius; dx <= radius; dx += step {
+			try(image.Pt(dx, dy))
+		}
+	}
+	if step > 1 && !math.IsInf(bestScore, -1) {
+		coarse := best
+		for dy := -step + 1; dy < step; dy++ {
+			for dx := -step + 1; dx < step; dx++ {
+				try(coarse.Add(image.Pt(dx, dy)))
+			}
+		}
+	}
+	if math.IsInf(bestScore, -1) {
+		return start, 0
+	}
+	return box.Add(shift).Add(best), bestScore
+}
+
+// propagateStep moves every current track to where its content moved between the prev and curr
+// images, without running the detector. Tracks that cannot be found keep their last box, and so do
+// all tracks when either image is missing, such as in a replay without images. Tracks are neither
+// hit nor missed, since nothing was detected.
+func (t *myTracker) propagateStep(prev, curr image.Image) {
+	boxes := make(map[string]image.Rectangle, len(t.lastDetections))
+	propagated := make([]*track, 0, len(t.lastDetections))
+	for _, tr := range t.lastDetections {
+		box := *tr.Det.BoundingBox()
+		if prev != nil && curr != nil {
+			if found, score := propagateBox(prev, curr, box, t.predictBox(tr), t.propagationRadius); score >= minPropagationScore {
+				box = found
+			}
+		}
+		newTrack := ReplaceBoundingBox(tr, &box)
+		newTrack.propagated = true
+		t.observe(newTrack)
+		id := getTrackingLabel(newTrack)
+		boxes[id] = box
+		t.lastSeen[id] = newTrack
+		propagated = append(propagated, newTrack)
+	}
+	t.tracks.Propagate(boxes, t.frameTime)
+	t.lastDetections = propagated
+	t.publish(propagated, true)
+	t.advanceFrame()
+}
@@ -0,0 +1,95 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+// texturedFrame returns a frame with a noisy background and a textured square at the given position.
+func texturedFrame(bounds, square image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(bounds)
+	bg := rand.New(rand.NewSource(1))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := uint8(100 + bg.Intn(10))
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	fg := rand.New(rand.NewSource(2))
+	for y := square.Min.Y; y < square.Max.Y; y++ {
+		for x := square.Min.X; x < square.Max.X; x++ {
+			img.Set(x, y, color.RGBA{uint8(fg.Intn(256)), uint8(fg.Intn(256)), uint8(fg.Intn(256)), 255})
+		}
+	}
+	return img
+}
+
+func TestPropagateBox(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 150)
+	box := image.Rect(40, 40, 80, 90)
+	moved := box.Add(image.Pt(9, -6))
+	prev := texturedFrame(bounds, box)
+	curr := texturedFrame(bounds, moved)
+
+	found, score := propagateBox(prev, curr, box, box, DefaultPropagationRadius)
+	test.That(t, found, test.ShouldResemble, moved)
+	test.That(t, score, test.ShouldBeGreaterThan, 0.9)
+
+	// the search starts from the prediction
+	far := box.Add(image.Pt(60, 20))
+	curr = texturedFrame(bounds, far)
+	found, _ = propagateBox(prev, curr, box, box.Add(image.Pt(55, 25)), 10)
+	test.That(t, found, test.ShouldResemble, far)
+
+	// too small to be matched
+	tiny := image.Rect(10, 10, 12, 12)
+	found, score = propagateBox(prev, curr, tiny, tiny, DefaultPropagationRadius)
+	test.That(t, found, test.ShouldResemble, tiny)
+	test.That(t, score, test.ShouldEqual, 0)
+}
+
+func TestPropagateStep(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 150)
+	box := image.Rect(40, 40, 80, 90)
+	moved := box.Add(image.Pt(5, 3))
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+		propagationRadius:   DefaultPropagationRadius,
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box, 0.8, LabelDet0)}))
+	tr := fakeTracker.lastDetections[0]
+
+	fakeTracker.propagateStep(texturedFrame(bounds, box), texturedFrame(bounds, moved))
+	test.That(t, len(fakeTracker.lastDetections), test.ShouldEqual, 1)
+	propagated := fakeTracker.lastDetections[0]
+	test.That(t, propagated.Det.Label(), test.ShouldEqual, tr.Det.Label())
+	test.That(t, *propagated.Det.BoundingBox(), test.ShouldResemble, moved)
+	test.That(t, propagated.Det.Score(), test.ShouldEqual, 0.8)
+	test.That(t, propagated.propagated, test.ShouldBeTrue)
+	// nothing was detected, so the track did not get any closer to stable
+	et, ok := fakeTracker.tracks.Track(getTrackingLabel(tr))
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, et.Hits, test.ShouldEqual, 1)
+	test.That(t, len(et.History), test.ShouldEqual, 2)
+	test.That(t, et.History[1].Box, test.ShouldResemble, moved)
+	test.That(t, fakeTracker.currDetections.propagated, test.ShouldBeTrue)
+
+	// the next detection replaces the propagated box
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, moved.Add(image.Pt(1, 1)), 0.9, LabelDet0)}))
+	test.That(t, fakeTracker.lastDetections[0].propagated, test.ShouldBeFalse)
+	test.That(t, fakeTracker.lastDetections[0].Det.Label(), test.ShouldEqual, tr.Det.Label())
+	test.That(t, fakeTracker.currDetections.propagated, test.ShouldBeFalse)
+}
@@ -0,0 +1,136 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the push mode, where the detections of each frame are computed elsewhere, such
+// as by a remote model, and given to the tracker through DoCommand.
+package object_tracker
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"time"
+
+	"github.com/pkg/errors"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+)
+
+// ModePush tracks the detections given to the push command, and has no background loop.
+const ModePush = "push"
+
+// pushedDetection is a detection given to the push command, or a track returned by it. Boxes are
+// in pixels.
+type pushedDetection struct {
+	XMin       int     `json:"x_min"`
+	YMin       int     `json:"y_min"`
+	XMax       int     `json:"x_max"`
+	YMax       int     `json:"y_max"`
+	ClassName  string  `json:"class_name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// pushedFrame is a frame's worth of detections given to the push command.
+type pushedFrame struct {
+	Detections []pushedDetection `json:"detections"`
+	// ImageSize is the width and height of the frame, if known
+	ImageSize *struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"image_size,omitempty"`
+	// Timestamp is when the frame was captured, in RFC 3339 format, if it was not just now
+	Timestamp string `json:"timestamp,omitempty"`
+	Session   string `json:"session,omitempty"`
+}
+
+// parsePushedFrame converts the argument of the push command.
+func parsePushedFrame(arg interface{}) (*pushedFrame, error) {
+	raw, err := json.Marshal(arg)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid push command")
+	}
+	var frame pushedFrame
+	if err := json.Unmarshal(raw, &frame); err != nil {
+		return nil, errors.Wrap(err, "invalid push command")
+	}
+	for _, d := range frame.Detections {
+		if d.XMax <= d.XMin || d.YMax <= d.YMin {
+			return nil, errors.Errorf("pushed box of class %q must have x_max above x_min and y_max above y_min", d.ClassName)
+		}
+	}
+	if frame.ImageSize != nil && (frame.ImageSize.Width <= 0 || frame.ImageSize.Height <= 0) {
+		return nil, errors.New("pushed image_size must have a positive width and height")
+	}
+	return &frame, nil
+}
+
+// detections returns the pushed detections, in an image with the given bounds if they are known.
+func (f *pushedFrame) detections() ([]objdet.Detection, *image.Rectangle) {
+	var bounds *image.Rectangle
+	if f.ImageSize != nil {
+		r := image.Rect(0, 0, f.ImageSize.Width, f.ImageSize.Height)
+		bounds = &r
+	}
+	out := make([]objdet.Detection, 0, len(f.Detections))
+	for _, d := range f.Detections {
+		box := image.Rect(d.XMin, d.YMin, d.XMax, d.YMax)
+		if bounds != nil {
+			out = append(out, objdet.NewDetection(*bounds, box, d.Confidence, d.ClassName))
+		} else {
+			out = append(out, objdet.NewDetectionWithoutImgBounds(box, d.Confidence, d.ClassName))
+		}
+	}
+	return out, bounds
+}
+
+// push advances the tracker of the session of the frame by one frame with the pushed detections,
+// and returns the stable tracks.
+func (t *myTracker) push(ctx context.Context, arg interface{}) ([]pushedDetection, error) {
+	if t.mode != ModePush && t.mode != ModeOnDemand {
+		return nil, errors.Errorf("push is only available in %q and %q modes", ModePush, ModeOnDemand)
+	}
+	frame, err := parsePushedFrame(arg)
+	if err != nil {
+		return nil, err
+	}
+	var frameTime time.Time
+	if frame.Timestamp != "" {
+		frameTime, err = time.Parse(time.RFC3339, frame.Timestamp)
+		if err != nil {
+			return nil, errors.Wrap(err, "pushed timestamp must be in RFC 3339 format")
+		}
+	}
+	detections, bounds := frame.detections()
+	if bounds == nil && t.regions.enabled() {
+		return nil, errors.New("pushed frames need an image_size when regions are configured")
+	}
+	s, err := t.session(ctx, map[string]interface{}{SessionKey: frame.Session})
+	if err != nil {
+		return nil, err
+	}
+
+	s.stepMutex.Lock()
+	defer s.stepMutex.Unlock()
+	s.frameTime = frameTime
+	var imageBounds image.Rectangle
+	if bounds != nil {
+		imageBounds = *bounds
+	}
+	start := time.Now()
+	at := frameTime
+	if at.IsZero() {
+		at = start
+	}
+	s.recordFrame(at, nil, imageBounds, detections)
+	stable := s.stepDetections(detections, imageBounds, start)
+	out := make([]pushedDetection, 0, len(stable))
+	for _, d := range stable {
+		box := d.BoundingBox()
+		out = append(out, pushedDetection{
+			XMin:       box.Min.X,
+			YMin:       box.Min.Y,
+			XMax:       box.Max.X,
+			YMax:       box.Max.Y,
+			ClassName:  d.Label(),
+			Confidence: d.Score(),
+		})
+	}
+	return out, nil
+}
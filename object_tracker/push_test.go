@@ -0,0 +1,113 @@
+package object_tracker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/test"
+)
+
+func getPushTracker(t *testing.T, cfg *Config) *myTracker {
+	conf := resource.Config{Name: "test-push", API: vision.API, ConvertedAttributes: cfg}
+	svc, err := newTracker(context.Background(), resource.Dependencies{}, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() { svc.Close(context.Background()) })
+	return svc.(*myTracker)
+}
+
+// pushFrame returns the push command of a frame with a person at x.
+func pushFrame(x int, session string) map[string]interface{} {
+	return map[string]interface{}{"push": map[string]interface{}{
+		"detections": []interface{}{
+			map[string]interface{}{"x_min": x, "y_min": 20, "x_max": x + 30, "y_max": 80, "class_name": "person", "confidence": 0.9},
+		},
+		"image_size": map[string]interface{}{"width": 200, "height": 100},
+		"timestamp":  "2024-03-01T12:30:45Z",
+		"session":    session,
+	}}
+}
+
+func TestPushDetections(t *testing.T) {
+	ctx := context.Background()
+	tracker := getPushTracker(t, &Config{Mode: ModePush})
+	test.That(t, tracker.detector, test.ShouldBeNil)
+
+	var tracks []pushedDetection
+	for i := range DefaultMinTrackPersistence + 1 {
+		out, err := tracker.DoCommand(ctx, pushFrame(10+5*i, ""))
+		test.That(t, err, test.ShouldBeNil)
+		tracks = out["push"].([]pushedDetection)
+		if i < DefaultMinTrackPersistence-1 {
+			test.That(t, len(tracks), test.ShouldEqual, 0)
+		}
+	}
+	test.That(t, len(tracks), test.ShouldEqual, 1)
+	// the label is stamped with the time of the frame that started the track
+	test.That(t, tracks[0].ClassName, test.ShouldEqual, "person_0_20240301_123045")
+	test.That(t, tracks[0].XMin, test.ShouldEqual, 10+5*DefaultMinTrackPersistence)
+	test.That(t, tracks[0].Confidence, test.ShouldEqual, 0.9)
+
+	// the new track is in the logs and triggers the classification, as in the other modes
+	out, err := tracker.DoCommand(ctx, map[string]interface{}{"logs": true})
+	test.That(t, err, test.ShouldBeNil)
+	logs := out["logs"].([]trackedObject)
+	test.That(t, len(logs), test.ShouldEqual, 1)
+	test.That(t, logs[0].FullLabel, test.ShouldEqual, "person_0_20240301_123045")
+	test.That(t, tracker.newInstance.Load(), test.ShouldBeTrue)
+
+	// the default session is also returned by Detections
+	dets, err := tracker.Detections(ctx, nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(dets), test.ShouldEqual, 1)
+
+	// other sessions are tracked on their own
+	out, err = tracker.DoCommand(ctx, pushFrame(150, "cam2"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(out["push"].([]pushedDetection)), test.ShouldEqual, 0)
+	test.That(t, len(tracker.sessions), test.ShouldEqual, 1)
+}
+
+func TestPushErrors(t *testing.T) {
+	ctx := context.Background()
+	tracker := getPushTracker(t, &Config{Mode: ModePush})
+
+	badBox := map[string]interface{}{"push": map[string]interface{}{
+		"detections": []interface{}{map[string]interface{}{"x_min": 10, "y_min": 20, "x_max": 5, "y_max": 80, "class_name": "person"}},
+	}}
+	_, err := tracker.DoCommand(ctx, badBox)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	badTime := pushFrame(10, "")
+	badTime["push"].(map[string]interface{})["timestamp"] = "yesterday"
+	_, err = tracker.DoCommand(ctx, badTime)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "RFC 3339")
+
+	// the background loop of the continuous mode cannot be pushed to
+	_, err = (&myTracker{mode: ModeContinuous}).push(ctx, pushFrame(10, "")["push"])
+	test.That(t, err, test.ShouldNotBeNil)
+
+	// regions are in normalized coordinates, so they need the size of the image
+	regions := getPushTracker(t, &Config{Mode: ModePush, IncludeRegions: [][][]float64{{{0, 0}, {1, 0}, {1, 1}}}})
+	noSize := pushFrame(10, "")
+	delete(noSize["push"].(map[string]interface{}), "image_size")
+	_, err = regions.DoCommand(ctx, noSize)
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, strings.Contains(err.Error(), "image_size"), test.ShouldBeTrue)
+}
+
+func TestValidatePush(t *testing.T) {
+	cfg := Config{Mode: ModePush}
+	deps, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(deps), test.ShouldEqual, 0)
+
+	continuous := Config{CameraName: "camera"}
+	_, _, err = continuous.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "detector_name")
+}
@@ -0,0 +1,406 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the recording of the input of the tracker, the detections of each frame before
+// they are filtered, to a rotating set of JSONL files, and their replay through a tracker.
+package object_tracker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+)
+
+const (
+	// DefaultRecordingMaxFrames is the number of frames written to a recording file before the
+	// next one is started.
+	DefaultRecordingMaxFrames = 1000
+	// DefaultRecordingMaxFiles is the number of recording files kept, the oldest being deleted.
+	DefaultRecordingMaxFiles = 10
+
+	recordingPrefix      = "recording_"
+	recordingExt         = ".jsonl"
+	recordingLayout      = "20060102_150405.000000"
+	recordingJPEGQuality = 90
+)
+
+// recordedFrame is a line of a recording: the detections of a frame in the format of the push
+// command, and the frame itself if images are recorded. Propagated frames are the frames between
+// detections with detect_every_n_frames, where the tracks were followed from the previous image.
+type recordedFrame struct {
+	pushedFrame
+	Propagated bool   `json:"propagated,omitempty"`
+	JPEG       []byte `json:"jpeg,omitempty"`
+}
+
+// recorder writes the frames given to the tracker to a rotating set of files. It is safe for
+// concurrent use, and shared by the sessions of a tracker.
+type recorder struct {
+	dir       string
+	images    bool
+	maxFrames int
+	maxFiles  int
+	logger    logging.Logger
+
+	mutex sync.Mutex
+	file  *os.File
+	buf   *bufio.Writer
+	// frames is the number of frames written to the current file
+	frames int
+}
+
+// recordingStatus is the result of the record command.
+type recordingStatus struct {
+	Recording bool   `json:"recording"`
+	File      string `json:"file,omitempty"`
+}
+
+// start opens a new recording file if the recorder is not recording.
+func (r *recorder) start() (recordingStatus, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file == nil {
+		if err := r.rotate(); err != nil {
+			return recordingStatus{}, err
+		}
+	}
+	return recordingStatus{Recording: true, File: r.file.Name()}, nil
+}
+
+// stop closes the current recording file, if any.
+func (r *recorder) stop() (recordingStatus, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return recordingStatus{}, r.closeFile()
+}
+
+func (r *recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.buf.Flush()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	r.file, r.buf = nil, nil
+	return err
+}
+
+// rotate closes the current file, starts a new one, and deletes the oldest files beyond maxFiles.
+func (r *recorder) rotate() error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return errors.Wrapf(err, "unable to create recording directory %v", r.dir)
+	}
+	name := filepath.Join(r.dir, recordingPrefix+time.Now().UTC().Format(recordingLayout)+recordingExt)
+	f, err := os.Create(name)
+	if err != nil {
+		return errors.Wrap(err, "unable to start recording")
+	}
+	r.file, r.buf, r.frames = f, bufio.NewWriter(f), 0
+
+	files, err := recordingFiles(r.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > r.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			r.logger.Warnf("unable to delete old recording %v: %v", files[0], err)
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// record writes a frame, if the recorder is recording. Errors are logged and stop the recording,
+// so that a full disk does not stop the tracker.
+func (r *recorder) record(session string, at time.Time, img image.Image, bounds image.Rectangle, dets []objdet.Detection, propagated bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file == nil {
+		return
+	}
+	if err := r.write(session, at, img, bounds, dets, propagated); err != nil {
+		r.logger.Errorf("stopping recording: %v", err)
+		if err := r.closeFile(); err != nil {
+			r.logger.Errorf("unable to close recording: %v", err)
+		}
+	}
+}
+
+func (r *recorder) write(session string, at time.Time, img image.Image, bounds image.Rectangle, dets []objdet.Detection, propagated bool) error {
+	if r.frames >= r.maxFrames {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	frame := recordedFrame{pushedFrame: pushedFrame{
+		Detections: make([]pushedDetection, 0, len(dets)),
+		Timestamp:  at.UTC().Format(time.RFC3339Nano),
+		Session:    session,
+	}, Propagated: propagated}
+	if !bounds.Empty() {
+		frame.ImageSize = &struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		}{Width: bounds.Dx(), Height: bounds.Dy()}
+	}
+	for _, d := range dets {
+		box := d.BoundingBox()
+		frame.Detections = append(frame.Detections, pushedDetection{
+			XMin: box.Min.X, YMin: box.Min.Y, XMax: box.Max.X, YMax: box.Max.Y,
+			ClassName: d.Label(), Confidence: d.Score(),
+		})
+	}
+	if r.images && img != nil {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: recordingJPEGQuality}); err != nil {
+			return errors.Wrap(err, "unable to encode recorded frame")
+		}
+		frame.JPEG = buf.Bytes()
+	}
+	line, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	if _, err := r.buf.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	r.frames++
+	return nil
+}
+
+// configureRecorder stops the current recorder, and replaces it with the one of the config, started
+// if record_on_start is set.
+func (t *myTracker) configureRecorder(cfg *Config) error {
+	if t.recorder != nil {
+		if _, err := t.recorder.stop(); err != nil {
+			t.logger.Warnf("unable to close recording: %v", err)
+		}
+	}
+	t.recorder = nil
+	if cfg.RecordingDir == "" {
+		return nil
+	}
+	t.recorder = &recorder{
+		dir:       cfg.RecordingDir,
+		images:    cfg.RecordImages,
+		maxFrames: DefaultRecordingMaxFrames,
+		maxFiles:  DefaultRecordingMaxFiles,
+		logger:    t.logger,
+	}
+	if cfg.RecordingMaxFrames > 0 {
+		t.recorder.maxFrames = cfg.RecordingMaxFrames
+	}
+	if cfg.RecordingMaxFiles > 0 {
+		t.recorder.maxFiles = cfg.RecordingMaxFiles
+	}
+	if cfg.RecordOnStart {
+		_, err := t.recorder.start()
+		return err
+	}
+	return nil
+}
+
+// recordFrame records the detections of a frame before they are filtered, if recording is
+// configured. img can be nil when there is no image, such as in push mode.
+func (t *myTracker) recordFrame(at time.Time, img image.Image, bounds image.Rectangle, dets []objdet.Detection) {
+	if t.recorder != nil {
+		t.recorder.record(t.sessionName, at, img, bounds, dets, false)
+	}
+}
+
+// recordPropagatedFrame records a frame where the detector did not run, so that a replay advances
+// on it too.
+func (t *myTracker) recordPropagatedFrame(at time.Time, img image.Image) {
+	if t.recorder != nil {
+		t.recorder.record(t.sessionName, at, img, img.Bounds(), nil, true)
+	}
+}
+
+// record starts or stops recording.
+func (t *myTracker) record(arg interface{}) (recordingStatus, error) {
+	if t.recorder == nil {
+		return recordingStatus{}, errors.New("record needs the recording_dir attribute")
+	}
+	start, ok := arg.(bool)
+	if !ok {
+		return recordingStatus{}, errors.New("record must be true to start recording or false to stop it")
+	}
+	if start {
+		return t.recorder.start()
+	}
+	return t.recorder.stop()
+}
+
+// recordingFiles returns the recording files of a directory, oldest first.
+func recordingFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read recordings in %v", dir)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), recordingPrefix) && strings.HasSuffix(e.Name(), recordingExt) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// ReplayOptions holds the inputs and outputs of a replay.
+type ReplayOptions struct {
+	// Recording is a recording file, or a directory of recording files replayed oldest first
+	Recording string
+	// Session selects the frames of a session, the default session if empty
+	Session string
+	// Config holds the attributes of the tracker. The camera, detectors, mode, pipeline and
+	// recording are ignored, and so are detect_every_n_frames, since the recording marks the
+	// propagated frames, and motion compensation from a movement sensor.
+	Config Config
+	// MOTOutput and JSONLOutput receive the stable tracks of each frame, and can be nil
+	MOTOutput   io.Writer
+	JSONLOutput io.Writer
+}
+
+// Replay runs a tracker on the recorded frames of a session, with their recorded timestamps, and
+// returns the number of frames replayed. Replaying the same recording always gives the same tracks.
+func Replay(ctx context.Context, opts ReplayOptions, logger logging.Logger) (int, error) {
+	files := []string{opts.Recording}
+	if info, err := os.Stat(opts.Recording); err != nil {
+		return 0, errors.Wrapf(err, "unable to read recording %v", opts.Recording)
+	} else if info.IsDir() {
+		if files, err = recordingFiles(opts.Recording); err != nil {
+			return 0, err
+		}
+	}
+
+	t, err := newBatchTracker(ctx, "replay-tracker", opts.Config, logger)
+	if err != nil {
+		return 0, err
+	}
+	defer t.Close(ctx)
+
+	out := newTrackWriter(opts.MOTOutput, opts.JSONLOutput)
+	n := 0
+	for _, path := range files {
+		if err := t.replayFile(ctx, path, opts.Session, out, &n); err != nil {
+			return n, err
+		}
+	}
+	return n, out.flush()
+}
+
+// newBatchTracker returns a tracker in push mode with the attributes of cfg, without a camera,
+// detectors, recording or movement sensor, for replays and tuning. The frames it is given decide
+// which ones are propagated, so the pipeline and detect_every_n_frames are ignored too.
+func newBatchTracker(ctx context.Context, name string, cfg Config, logger logging.Logger) (*myTracker, error) {
+	cfg.CameraName, cfg.DetectorName, cfg.DetectorNames, cfg.Mode = "", "", nil, ModePush
+	cfg.RecordingDir, cfg.RecordOnStart, cfg.RecordImages = "", false, false
+	cfg.Pipelined, cfg.DetectEveryNFrames = false, 0
+	if cfg.MotionCompensation == MotionCompensationMovementSensor {
+		cfg.MotionCompensation = MotionCompensationNone
+	}
+	if _, _, err := cfg.Validate(""); err != nil {
+		return nil, err
+	}
+	conf := resource.Config{Name: name, API: vision.API, Model: Model, ConvertedAttributes: &cfg}
+	svc, err := newTracker(ctx, resource.Dependencies{}, conf, logger)
+	if err != nil {
+		return nil, err
+	}
+	return svc.(*myTracker), nil
+}
+
+// replayFile replays the frames of the session in a recording file.
+func (t *myTracker) replayFile(ctx context.Context, path, session string, out *trackWriter, n *int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read recording %v", path)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	warned := false
+	for line := 1; scanner.Scan(); line++ {
+		var frame recordedFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return errors.Wrapf(err, "line %d of %v", line, path)
+		}
+		if frame.Session != session {
+			continue
+		}
+		// the tracks between detections are followed, and the camera motion estimated, on the images
+		if frame.JPEG == nil && !warned && (frame.Propagated || t.motionCompensation == MotionCompensationImage) {
+			t.logger.Warnf("line %d of %v has no image, so the replay diverges from the live tracks: "+
+				"detect_every_n_frames and image motion compensation need record_images", line, path)
+			warned = true
+		}
+		if err := t.replayFrame(ctx, &frame); err != nil {
+			return errors.Wrapf(err, "line %d of %v", line, path)
+		}
+		*n++
+		if err := out.write(*n, t.stableTracks()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// replayFrame advances the tracker by one recorded frame. Propagated frames move the tracks from the
+// previous image, as they were live, and the others step with the recorded detections.
+func (t *myTracker) replayFrame(ctx context.Context, frame *recordedFrame) error {
+	t.stepMutex.Lock()
+	defer t.stepMutex.Unlock()
+	at, err := time.Parse(time.RFC3339, frame.Timestamp)
+	if err != nil {
+		return errors.Wrap(err, "recorded timestamp must be in RFC 3339 format")
+	}
+	detections, bounds := frame.detections()
+	if bounds == nil && t.regions.enabled() {
+		return errors.New("recorded frames need an image_size when regions are configured")
+	}
+	var imageBounds image.Rectangle
+	if bounds != nil {
+		imageBounds = *bounds
+	}
+	var prev, img image.Image
+	if prevImg := t.currImg.Load(); prevImg != nil {
+		prev = *prevImg
+	}
+	if frame.JPEG != nil {
+		if img, err = jpeg.Decode(bytes.NewReader(frame.JPEG)); err != nil {
+			return errors.Wrap(err, "unable to decode recorded frame")
+		}
+		// the camera motion is estimated from the recorded images, as it was live
+		if prev != nil {
+			t.compensateMotion(t.estimateMotion(ctx, prev, img, at.Sub(t.lastStep)))
+		}
+		t.currImg.Store(&img)
+	}
+	t.lastStep = at
+	t.frameTime = at
+	if frame.Propagated {
+		t.propagateStep(prev, img)
+		return nil
+	}
+	t.stepDetections(detections, imageBounds, time.Now())
+	return nil
+}
@@ -0,0 +1,249 @@
+package object_tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/test"
+
+	"github.com/viam-modules/object-tracking/simulator"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sim := simulator.New(simulator.Scene{
+		Width: 320, Height: 240, Frames: 24, Seed: 5,
+		Objects: []simulator.Object{
+			{ID: 1, Class: "person", Trajectory: walking(10, 50, 4, 1)},
+			{ID: 2, Class: "person", Trajectory: walking(250, 100, -4, 0), Occluded: []simulator.Interval{{From: 10, To: 11}}},
+		},
+		Noise: simulator.Noise{Jitter: 2, DropRate: 0.1, FalsePositiveRate: 0.2, MinScore: 0.3, MaxScore: 0.9},
+	})
+	deps := resource.Dependencies{
+		camera.Named("camera"):   sim.Camera(),
+		vision.Named("detector"): sim.Detector(),
+	}
+	cfg := Config{
+		CameraName:         "camera",
+		DetectorName:       "detector",
+		Mode:               ModeOnDemand,
+		RecordingDir:       dir,
+		RecordImages:       true,
+		RecordingMaxFrames: 5,
+		RecordingMaxFiles:  3,
+	}
+	conf := resource.Config{Name: "test-objtracker", API: vision.API, ConvertedAttributes: &cfg}
+	tracker, err := newTracker(ctx, deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	defer tracker.Close(ctx)
+
+	// the frames before the incident are not recorded
+	for range 4 {
+		_, err := tracker.DetectionsFromCamera(ctx, "camera", nil)
+		test.That(t, err, test.ShouldBeNil)
+	}
+	out, err := tracker.DoCommand(ctx, map[string]interface{}{"record": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out["record"].(recordingStatus).Recording, test.ShouldBeTrue)
+
+	for range 20 {
+		_, err := tracker.DetectionsFromCamera(ctx, "camera", nil)
+		test.That(t, err, test.ShouldBeNil)
+	}
+	out, err = tracker.DoCommand(ctx, map[string]interface{}{"record": false})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out["record"].(recordingStatus).Recording, test.ShouldBeFalse)
+
+	// 20 frames are 4 files of 5 frames, and the oldest one was deleted
+	files, err := recordingFiles(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(files), test.ShouldEqual, 3)
+	data, err := os.ReadFile(files[0])
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, strings.Count(string(data), "\n"), test.ShouldEqual, 5)
+	test.That(t, string(data), test.ShouldContainSubstring, `"jpeg":`)
+
+	// replaying the recording gives the same tracks every time
+	all := t.TempDir()
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, os.WriteFile(filepath.Join(all, filepath.Base(f)), data, 0o600), test.ShouldBeNil)
+	}
+	replay := func() (string, string) {
+		var mot, jsonl bytes.Buffer
+		n, err := Replay(ctx, ReplayOptions{Recording: all, MOTOutput: &mot, JSONLOutput: &jsonl}, logging.NewTestLogger(t))
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, n, test.ShouldEqual, 15)
+		return mot.String(), jsonl.String()
+	}
+	mot, jsonl := replay()
+	test.That(t, mot, test.ShouldNotBeEmpty)
+	mot2, jsonl2 := replay()
+	test.That(t, mot2, test.ShouldEqual, mot)
+	test.That(t, jsonl2, test.ShouldEqual, jsonl)
+
+	// replaying a single file works too
+	n, err := Replay(ctx, ReplayOptions{Recording: files[2]}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 5)
+
+	// recording is off without recording_dir
+	_, err = (&myTracker{}).DoCommand(ctx, map[string]interface{}{"record": true})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestReplayMatchesLive(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := Config{Mode: ModePush, RecordingDir: dir, RecordOnStart: true}
+	conf := resource.Config{Name: "test-objtracker", API: vision.API, ConvertedAttributes: &cfg}
+	tracker, err := newTracker(ctx, resource.Dependencies{}, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+
+	var live []string
+	for frame := range 12 {
+		box := image.Rect(10+6*frame, 20, 40+6*frame, 80)
+		push := map[string]interface{}{
+			"detections": []interface{}{map[string]interface{}{
+				"x_min": box.Min.X, "y_min": box.Min.Y, "x_max": box.Max.X, "y_max": box.Max.Y,
+				"class_name": "person", "confidence": 0.8,
+			}},
+			"timestamp": "2024-03-01T12:00:00Z",
+			"session":   "door",
+		}
+		out, err := tracker.DoCommand(ctx, map[string]interface{}{"push": push})
+		test.That(t, err, test.ShouldBeNil)
+		for _, d := range out["push"].([]pushedDetection) {
+			live = append(live, d.ClassName)
+		}
+	}
+	test.That(t, tracker.Close(ctx), test.ShouldBeNil)
+	test.That(t, live, test.ShouldNotBeEmpty)
+
+	var jsonl bytes.Buffer
+	n, err := Replay(ctx, ReplayOptions{Recording: dir, Session: "door", JSONLOutput: &jsonl}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 12)
+	// the labels have the recorded timestamp, so they are the same as live
+	test.That(t, strings.Count(jsonl.String(), live[0]), test.ShouldEqual, len(live))
+	n, err = Replay(ctx, ReplayOptions{Recording: dir}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 0)
+
+	// the frames were pushed without their size, which regions need
+	regions := Config{IncludeRegions: [][][]float64{{{0, 0}, {1, 0}, {1, 1}}}}
+	_, err = Replay(ctx, ReplayOptions{Recording: dir, Session: "door", Config: regions}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "image_size")
+
+	_, _, err = (&Config{Mode: ModePush, RecordOnStart: true}).Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestReplayPropagatedFrames(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sim := simulator.New(simulator.Scene{
+		Width: 320, Height: 240, Frames: 20, Seed: 6,
+		Objects: []simulator.Object{
+			{ID: 1, Class: "person", Trajectory: walking(10, 50, 4, 1)},
+		},
+		Noise: simulator.Noise{Jitter: 1},
+	})
+	// the scene is over once the loop asks for a frame after the last one
+	var once sync.Once
+	done := make(chan struct{})
+	cam := sim.Camera()
+	images := cam.ImagesFunc
+	cam.ImagesFunc = func(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+		out, meta, err := images(ctx, filterSourceNames, extra)
+		if errors.Is(err, simulator.ErrEndOfScene) {
+			once.Do(func() { close(done) })
+		}
+		return out, meta, err
+	}
+	deps := resource.Dependencies{
+		camera.Named("camera"):   cam,
+		vision.Named("detector"): sim.Detector(),
+	}
+	cfg := Config{
+		CameraName:         "camera",
+		DetectorName:       "detector",
+		MaxFrequency:       1000,
+		DetectEveryNFrames: 3,
+		RecordingDir:       dir,
+		RecordOnStart:      true,
+		RecordImages:       true,
+	}
+	conf := resource.Config{Name: "test-objtracker", API: vision.API, ConvertedAttributes: &cfg}
+	tracker, err := newTracker(ctx, deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the tracker did not play the whole scene")
+	}
+	test.That(t, tracker.Close(ctx), test.ShouldBeNil)
+
+	// every frame is recorded, and the ones between detections are marked
+	files, err := recordingFiles(dir)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(files), test.ShouldEqual, 1)
+	data, err := os.ReadFile(files[0])
+	test.That(t, err, test.ShouldBeNil)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	test.That(t, len(lines), test.ShouldEqual, 20)
+	propagated := 0
+	for _, line := range lines {
+		var frame recordedFrame
+		test.That(t, json.Unmarshal([]byte(line), &frame), test.ShouldBeNil)
+		if frame.Propagated {
+			propagated++
+			test.That(t, frame.Detections, test.ShouldBeEmpty)
+		}
+	}
+	// the loop detects on its first frame, then on every third one
+	test.That(t, propagated, test.ShouldEqual, 12)
+
+	// the replay advances on every frame, following the tracks between detections on the images
+	var jsonl bytes.Buffer
+	n, err := Replay(ctx, ReplayOptions{Recording: dir, Config: cfg, JSONLOutput: &jsonl}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 20)
+	var last offlineFrame
+	out := strings.Split(strings.TrimSpace(jsonl.String()), "\n")
+	test.That(t, json.Unmarshal([]byte(out[len(out)-1]), &last), test.ShouldBeNil)
+	test.That(t, len(last.Detections), test.ShouldEqual, 1)
+
+	// without the images, the tracks cannot be followed, which the replay warns about
+	for i, line := range lines {
+		var frame recordedFrame
+		test.That(t, json.Unmarshal([]byte(line), &frame), test.ShouldBeNil)
+		frame.JPEG = nil
+		raw, err := json.Marshal(frame)
+		test.That(t, err, test.ShouldBeNil)
+		lines[i] = string(raw)
+	}
+	withoutImages := filepath.Join(t.TempDir(), filepath.Base(files[0]))
+	test.That(t, os.WriteFile(withoutImages, []byte(strings.Join(lines, "\n")), 0o600), test.ShouldBeNil)
+	logger, logs := logging.NewObservedTestLogger(t)
+	n, err = Replay(ctx, ReplayOptions{Recording: withoutImages, Config: cfg}, logger)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, n, test.ShouldEqual, 20)
+	test.That(t, logs.FilterMessageSnippet("diverges").Len(), test.ShouldEqual, 1)
+}
@@ -0,0 +1,276 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the regions of interest and exclusion masks, which restrict where objects are tracked.
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/pkg/errors"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+)
+
+const (
+	// RegionRuleCenter keeps a detection based on whether the center of its box is in a region.
+	RegionRuleCenter = "center"
+	// RegionRuleOverlap keeps a detection based on the fraction of its box that is in a region.
+	RegionRuleOverlap = "overlap"
+
+	// DefaultRegionMinOverlap is the fraction of a box that must be in a region for it to count as in it.
+	DefaultRegionMinOverlap = 0.5
+)
+
+// point is a point of a polygon.
+type point struct {
+	x, y float64
+}
+
+// polygon is a region of the image, in normalized coordinates until converted with toPixels.
+type polygon []point
+
+// validateRegions checks that every region is a polygon of at least 3 points in normalized coordinates.
+func validateRegions(attribute string, regions [][][]float64) error {
+	for _, region := range regions {
+		if len(region) < 3 {
+			return errors.Errorf("each region of attribute %s must have at least 3 points", attribute)
+		}
+		for _, p := range region {
+			if len(p) != 2 {
+				return errors.Errorf("each point of attribute %s must be [x, y]", attribute)
+			}
+			if p[0] < 0 || p[0] > 1 || p[1] < 0 || p[1] > 1 {
+				return errors.Errorf("the points of attribute %s must be normalized between 0 and 1", attribute)
+			}
+		}
+	}
+	return nil
+}
+
+// newPolygons converts validated regions from the config.
+func newPolygons(regions [][][]float64) []polygon {
+	out := make([]polygon, 0, len(regions))
+	for _, region := range regions {
+		poly := make(polygon, 0, len(region))
+		for _, p := range region {
+			poly = append(poly, point{p[0], p[1]})
+		}
+		out = append(out, poly)
+	}
+	return out
+}
+
+// toPixels returns the polygon in the pixel coordinates of an image with the given bounds.
+func (p polygon) toPixels(bounds image.Rectangle) polygon {
+	out := make(polygon, len(p))
+	for i, pt := range p {
+		out[i] = point{
+			float64(bounds.Min.X) + pt.x*float64(bounds.Dx()),
+			float64(bounds.Min.Y) + pt.y*float64(bounds.Dy()),
+		}
+	}
+	return out
+}
+
+// contains returns whether the point is inside the polygon, by ray casting.
+func (p polygon) contains(pt point) bool {
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		a, b := p[i], p[j]
+		if (a.y > pt.y) != (b.y > pt.y) && pt.x < (b.x-a.x)*(pt.y-a.y)/(b.y-a.y)+a.x {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// area returns the area of the polygon.
+func (p polygon) area() float64 {
+	sum := 0.0
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		sum += p[j].x*p[i].y - p[i].x*p[j].y
+	}
+	return math.Abs(sum) / 2
+}
+
+// clip returns the part of the polygon that is inside the rectangle (Sutherland-Hodgman).
+func (p polygon) clip(r image.Rectangle) polygon {
+	type edge struct {
+		inside    func(point) bool
+		intersect func(a, b point) point
+	}
+	atX := func(x float64) func(a, b point) point {
+		return func(a, b point) point {
+			return point{x, a.y + (b.y-a.y)*(x-a.x)/(b.x-a.x)}
+		}
+	}
+	atY := func(y float64) func(a, b point) point {
+		return func(a, b point) point {
+			return point{a.x + (b.x-a.x)*(y-a.y)/(b.y-a.y), y}
+		}
+	}
+	x0, y0, x1, y1 := float64(r.Min.X), float64(r.Min.Y), float64(r.Max.X), float64(r.Max.Y)
+	edges := []edge{
+		{func(q point) bool { return q.x >= x0 }, atX(x0)},
+		{func(q point) bool { return q.x <= x1 }, atX(x1)},
+		{func(q point) bool { return q.y >= y0 }, atY(y0)},
+		{func(q point) bool { return q.y <= y1 }, atY(y1)},
+	}
+	out := p
+	for _, e := range edges {
+		in := out
+		out = nil
+		for i := range in {
+			curr, prev := in[i], in[(i+len(in)-1)%len(in)]
+			switch {
+			case e.inside(curr) && e.inside(prev):
+				out = append(out, curr)
+			case e.inside(curr):
+				out = append(out, e.intersect(prev, curr), curr)
+			case e.inside(prev):
+				out = append(out, e.intersect(prev, curr))
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+	}
+	return out
+}
+
+// overlap returns the fraction of the rectangle that is inside the polygon.
+func (p polygon) overlap(r image.Rectangle) float64 {
+	if r.Empty() {
+		return 0
+	}
+	return p.clip(r).area() / float64(r.Dx()*r.Dy())
+}
+
+// bounds returns the smallest rectangle containing the polygon.
+func (p polygon) bounds() image.Rectangle {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, pt := range p {
+		minX, minY = math.Min(minX, pt.x), math.Min(minY, pt.y)
+		maxX, maxY = math.Max(maxX, pt.x), math.Max(maxY, pt.y)
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY)))
+}
+
+// regionFilter keeps the detections that are in one of the include regions, if there are any,
+// and in none of the exclude regions.
+type regionFilter struct {
+	include    []polygon
+	exclude    []polygon
+	rule       string
+	minOverlap float64
+	// crop is true when the image is cropped to the include regions before running the detector
+	crop bool
+}
+
+// enabled returns whether there are any regions to filter with.
+func (f *regionFilter) enabled() bool {
+	return f != nil && (len(f.include) > 0 || len(f.exclude) > 0)
+}
+
+// in returns whether the box counts as in any of the polygons, which are in pixels.
+func (f *regionFilter) in(box image.Rectangle, polygons []polygon) bool {
+	for _, p := range polygons {
+		if f.rule == RegionRuleOverlap {
+			if p.overlap(box) >= f.minOverlap {
+				return true
+			}
+		} else {
+			cx, cy := center(box)
+			if p.contains(point{cx, cy}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Filter returns the detections kept by the regions, in an image with the given bounds.
+func (f *regionFilter) Filter(dets []objdet.Detection, bounds image.Rectangle) []objdet.Detection {
+	if !f.enabled() {
+		return dets
+	}
+	include := make([]polygon, len(f.include))
+	for i, p := range f.include {
+		include[i] = p.toPixels(bounds)
+	}
+	exclude := make([]polygon, len(f.exclude))
+	for i, p := range f.exclude {
+		exclude[i] = p.toPixels(bounds)
+	}
+	out := make([]objdet.Detection, 0, len(dets))
+	for _, d := range dets {
+		box := *d.BoundingBox()
+		if len(include) > 0 && !f.in(box, include) {
+			continue
+		}
+		if f.in(box, exclude) {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// cropRect returns the part of an image with the given bounds the detector should run on: the
+// smallest rectangle containing every include region, or the whole image if cropping is disabled.
+func (f *regionFilter) cropRect(bounds image.Rectangle) image.Rectangle {
+	if f == nil || !f.crop || len(f.include) == 0 {
+		return bounds
+	}
+	var r image.Rectangle
+	for _, p := range f.include {
+		r = r.Union(p.toPixels(bounds).bounds())
+	}
+	return r.Intersect(bounds)
+}
+
+// detect runs the detector on the image, cropped to the regions of interest and split into tiles
+// if configured, and returns the detections in the coordinates of the whole image.
+func (t *myTracker) detect(ctx context.Context, img image.Image) ([]objdet.Detection, error) {
+	crop := t.regions.cropRect(img.Bounds())
+	if crop.Empty() {
+		return nil, nil
+	}
+	return t.detectTiles(ctx, img, crop)
+}
+
+// detectIn runs the detector on a rectangle of the image, and returns the detections in the
+// coordinates of the whole image.
+func (t *myTracker) detectIn(ctx context.Context, img image.Image, rect image.Rectangle) ([]objdet.Detection, error) {
+	bounds := img.Bounds()
+	if rect == bounds {
+		return t.runDetector(ctx, img)
+	}
+	// copy the rectangle to a new image starting at (0, 0), which is what detectors expect
+	cropped := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(cropped, cropped.Bounds(), img, rect.Min, draw.Src)
+	detections, err := t.runDetector(ctx, cropped)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]objdet.Detection, 0, len(detections))
+	for _, d := range detections {
+		out = append(out, objdet.NewDetection(bounds, d.BoundingBox().Add(rect.Min), d.Score(), d.Label()))
+	}
+	return out, nil
+}
+
+// filterDetections renames the detections to their canonical class, and keeps those in the chosen
+// labels, above the minimum confidence, within the limits of the box filters, and in the regions of
+// interest of an image with the given bounds.
+func (t *myTracker) filterDetections(dets []objdet.Detection, bounds image.Rectangle) []objdet.Detection {
+	canonical := NewLabelAliases(t.labelAliases)(dets)
+	filtered := FilterDetectionsWithOptions(canonical, FilterOptions{
+		ChosenLabels:  t.chosenLabels,
+		MinConfidence: t.minConfidence,
+		BoxFilters:    t.boxFilters,
+		Duplicates:    t.duplicates,
+	})
+	return t.regions.Filter(filtered, bounds)
+}
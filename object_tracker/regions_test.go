@@ -0,0 +1,77 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/testutils/inject"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+func TestPolygon(t *testing.T) {
+	// a triangle covering the lower left half of a 100x100 image
+	tri := polygon{{0, 0}, {0, 1}, {1, 1}}.toPixels(image.Rect(0, 0, 100, 100))
+	test.That(t, tri.contains(point{10, 90}), test.ShouldBeTrue)
+	test.That(t, tri.contains(point{90, 10}), test.ShouldBeFalse)
+	test.That(t, tri.area(), test.ShouldAlmostEqual, 5000)
+
+	test.That(t, tri.overlap(image.Rect(0, 50, 50, 100)), test.ShouldAlmostEqual, 1)
+	test.That(t, tri.overlap(image.Rect(50, 0, 100, 50)), test.ShouldAlmostEqual, 0)
+	test.That(t, tri.overlap(image.Rect(0, 0, 100, 100)), test.ShouldAlmostEqual, 0.5)
+	test.That(t, tri.bounds(), test.ShouldResemble, image.Rect(0, 0, 100, 100))
+}
+
+func TestRegionFilter(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	det := func(box image.Rectangle) objdet.Detection {
+		return objdet.NewDetection(bounds, box, 0.9, "person")
+	}
+	// the left half of the image, except a screen in its top left corner
+	filter := &regionFilter{
+		include:    newPolygons([][][]float64{{{0, 0}, {0.5, 0}, {0.5, 1}, {0, 1}}}),
+		exclude:    newPolygons([][][]float64{{{0, 0}, {0.25, 0}, {0.25, 0.5}, {0, 0.5}}}),
+		rule:       RegionRuleCenter,
+		minOverlap: DefaultRegionMinOverlap,
+	}
+	onDock := det(image.Rect(60, 60, 80, 90))
+	onScreen := det(image.Rect(10, 10, 30, 30))
+	outside := det(image.Rect(150, 40, 170, 60))
+	// its center is on the screen, but most of it is not
+	inFront := det(image.Rect(40, 0, 58, 90))
+	dets := []objdet.Detection{onDock, onScreen, outside, inFront}
+	test.That(t, filter.Filter(dets, bounds), test.ShouldResemble, []objdet.Detection{onDock})
+
+	filter.rule = RegionRuleOverlap
+	test.That(t, filter.Filter(dets, bounds), test.ShouldResemble, []objdet.Detection{onDock, inFront})
+
+	// without regions every detection is kept
+	var none *regionFilter
+	test.That(t, none.Filter(dets, bounds), test.ShouldResemble, dets)
+}
+
+func TestDetectCroppedToRegions(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	var seen image.Rectangle
+	fakeTracker := &myTracker{
+		detector: &inject.VisionService{
+			DetectionsFunc: func(ctx context.Context, img image.Image, extra map[string]interface{}) ([]objdet.Detection, error) {
+				seen = img.Bounds()
+				return []objdet.Detection{objdet.NewDetection(img.Bounds(), image.Rect(5, 5, 15, 15), 0.9, "person")}, nil
+			},
+		},
+		regions: &regionFilter{
+			include: newPolygons([][][]float64{{{0.5, 0.5}, {1, 0.5}, {1, 1}}}),
+			rule:    RegionRuleCenter,
+			crop:    true,
+		},
+	}
+	dets, err := fakeTracker.detect(context.Background(), image.NewRGBA(bounds))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, seen, test.ShouldResemble, image.Rect(0, 0, 100, 50))
+	test.That(t, len(dets), test.ShouldEqual, 1)
+	// the detection is moved back to the coordinates of the whole image
+	test.That(t, *dets[0].BoundingBox(), test.ShouldResemble, image.Rect(105, 55, 115, 65))
+	test.That(t, *ImageBoundsFromDet(dets[0]), test.ShouldResemble, bounds)
+}
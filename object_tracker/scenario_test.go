@@ -0,0 +1,187 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/test"
+
+	"github.com/viam-modules/object-tracking/eval"
+	"github.com/viam-modules/object-tracking/simulator"
+)
+
+// runScenario tracks every frame of the scene on demand, with the default attributes, and returns
+// the simulation with the recorded tracks.
+func runScenario(t *testing.T, scene simulator.Scene) *simulator.Simulation {
+	t.Helper()
+	ctx := context.Background()
+	sim := simulator.New(scene)
+	deps := resource.Dependencies{
+		camera.Named("camera"):   sim.Camera(),
+		vision.Named("detector"): sim.Detector(),
+	}
+	conf := resource.Config{
+		Name: "test-objtracker",
+		API:  vision.API,
+		ConvertedAttributes: &Config{
+			CameraName:   "camera",
+			DetectorName: "detector",
+			Mode:         ModeOnDemand,
+		},
+	}
+	tracker, err := newTracker(ctx, deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	defer tracker.Close(ctx)
+	for range scene.Frames {
+		dets, err := tracker.DetectionsFromCamera(ctx, "camera", nil)
+		test.That(t, err, test.ShouldBeNil)
+		sim.Record(dets)
+	}
+	m := eval.Evaluate(sim.GroundTruth(), sim.Tracks())
+	t.Logf("MOTA %.3f IDF1 %.3f HOTA %.3f IDSW %d Frag %d", m.MOTA, m.IDF1, m.HOTA, m.IDSwitches, m.Fragmentations)
+	return sim
+}
+
+// runContinuousScenario plays the scene to a tracker in continuous mode, and returns the simulation
+// with the recorded tracks. The loop only asks for a new frame once it is done with the last one,
+// so the camera records what the tracker returns for a frame before serving the next one.
+func runContinuousScenario(t *testing.T, scene simulator.Scene) *simulator.Simulation {
+	t.Helper()
+	ctx := context.Background()
+	sim := simulator.New(scene)
+	var tracker atomic.Pointer[myTracker]
+	var once sync.Once
+	done := make(chan struct{})
+	cam := sim.Camera()
+	images := cam.ImagesFunc
+	cam.ImagesFunc = func(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+		select {
+		case <-done:
+			return nil, resource.ResponseMetadata{}, simulator.ErrEndOfScene
+		default:
+		}
+		if tr := tracker.Load(); tr != nil {
+			tr.currDetections.mutex.RLock()
+			sim.Record(getStableDetections(tr.currDetections.detections))
+			tr.currDetections.mutex.RUnlock()
+		}
+		out, meta, err := images(ctx, filterSourceNames, extra)
+		if errors.Is(err, simulator.ErrEndOfScene) {
+			once.Do(func() { close(done) })
+		}
+		return out, meta, err
+	}
+	deps := resource.Dependencies{
+		camera.Named("camera"):   cam,
+		vision.Named("detector"): sim.Detector(),
+	}
+	conf := resource.Config{
+		Name: "test-objtracker",
+		API:  vision.API,
+		ConvertedAttributes: &Config{
+			CameraName:   "camera",
+			DetectorName: "detector",
+			MaxFrequency: 1000,
+		},
+	}
+	svc, err := newTracker(ctx, deps, conf, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	defer svc.Close(ctx)
+	tracker.Store(svc.(*myTracker))
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the tracker did not play the whole scene")
+	}
+	m := eval.Evaluate(sim.GroundTruth(), sim.Tracks())
+	t.Logf("MOTA %.3f IDF1 %.3f HOTA %.3f IDSW %d Frag %d", m.MOTA, m.IDF1, m.HOTA, m.IDSwitches, m.Fragmentations)
+	return sim
+}
+
+// walking returns the trajectory of a pedestrian 40x90 pixels, starting at x, y.
+func walking(x, y int, vx, vy float64) simulator.Trajectory {
+	return simulator.Linear(image.Rect(x, y, x+40, y+90), vx, vy)
+}
+
+func TestScenarioOcclusion(t *testing.T) {
+	sim := runScenario(t, simulator.Scene{
+		Width: 640, Height: 480, Frames: 50, Seed: 1,
+		Objects: []simulator.Object{
+			{ID: 1, Class: "person", Trajectory: walking(20, 200, 3, 0), Occluded: []simulator.Interval{{From: 20, To: 25}}},
+		},
+		Noise: simulator.Noise{Jitter: 2, DropRate: 0.05},
+	})
+	test.That(t, len(sim.Labels(1)), test.ShouldEqual, 1)
+}
+
+func TestScenarioCrossing(t *testing.T) {
+	sim := runScenario(t, simulator.Scene{
+		Width: 640, Height: 480, Frames: 60, Seed: 2,
+		Objects: []simulator.Object{
+			{ID: 1, Class: "person", Trajectory: walking(220, 200, 3, 0)},
+			{ID: 2, Class: "person", Trajectory: walking(400, 210, -3, 0), Occluded: []simulator.Interval{{From: 29, To: 33}}},
+		},
+		Noise: simulator.Noise{Jitter: 2},
+	})
+	test.That(t, len(sim.Labels(1)), test.ShouldEqual, 1)
+	test.That(t, len(sim.Labels(2)), test.ShouldEqual, 1)
+}
+
+func TestScenarioContinuous(t *testing.T) {
+	sim := runContinuousScenario(t, simulator.Scene{
+		Width: 640, Height: 480, Frames: 60, Seed: 2,
+		Objects: []simulator.Object{
+			{ID: 1, Class: "person", Trajectory: walking(220, 200, 3, 0)},
+			{ID: 2, Class: "person", Trajectory: walking(400, 210, -3, 0), Occluded: []simulator.Interval{{From: 29, To: 33}}},
+		},
+		Noise: simulator.Noise{Jitter: 2},
+	})
+	test.That(t, len(sim.Labels(1)), test.ShouldEqual, 1)
+	test.That(t, len(sim.Labels(2)), test.ShouldEqual, 1)
+	// the loop tracked every frame after the two the tracker starts with
+	test.That(t, len(sim.Tracks()), test.ShouldBeGreaterThanOrEqualTo, 50)
+}
+
+func TestScenarioReentry(t *testing.T) {
+	sim := runScenario(t, simulator.Scene{
+		Width: 640, Height: 480, Frames: 60, Seed: 3,
+		Objects: []simulator.Object{
+			// a dog walks out of the frame and comes back where it left
+			{ID: 1, Class: "dog", Trajectory: func(frame int) image.Rectangle {
+				x := 500 + 6*min(frame, 30) - 6*max(0, frame-30)
+				return image.Rect(x, 300, x+60, 340)
+			}},
+		},
+		Noise: simulator.Noise{Jitter: 1},
+	})
+	// there is no appearance model to recognize it once it left the frame, so it comes back as a
+	// new track, which keeps its ID from then on
+	labels := sim.Labels(1)
+	test.That(t, len(labels), test.ShouldEqual, 2)
+	test.That(t, labels[1], test.ShouldStartWith, "dog_1_")
+}
+
+func TestScenarioCrowd(t *testing.T) {
+	var objects []simulator.Object
+	for i := range 12 {
+		x, y := 20+(i%6)*100, 40+(i/6)*200
+		objects = append(objects, simulator.Object{ID: i + 1, Class: "person", Trajectory: walking(x, y, 2, 1)})
+	}
+	sim := runScenario(t, simulator.Scene{
+		Width: 640, Height: 480, Frames: 40, Seed: 4,
+		Objects: objects,
+		Noise:   simulator.Noise{Jitter: 3, DropRate: 0.1, FalsePositiveRate: 0.2, MinScore: 0.5, MaxScore: 0.95},
+	})
+	for i := range objects {
+		test.That(t, len(sim.Labels(i+1)), test.ShouldEqual, 1)
+	}
+}
@@ -0,0 +1,251 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the smoothing of the boxes, scores and classes reported for each track.
+// Tracks keep the raw values from the detector, and the smoothed values are only used for output.
+package object_tracker
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// BoxSmoothingNone reports the boxes as detected.
+	BoxSmoothingNone = "none"
+	// BoxSmoothingEMA reports an exponential moving average of the boxes.
+	BoxSmoothingEMA = "ema"
+	// BoxSmoothingKalman reports the boxes filtered by a constant velocity Kalman filter.
+	BoxSmoothingKalman = "kalman"
+
+	// SmoothedOutputDetections smooths the detections returned by the API.
+	SmoothedOutputDetections = "detections"
+	// SmoothedOutputLogs smooths the classes of the objects returned by the logs command.
+	SmoothedOutputLogs = "logs"
+
+	// DefaultSmoothingAlpha is the weight of the latest value in the moving averages.
+	DefaultSmoothingAlpha = 0.5
+
+	// kalmanProcessNoise is the variance of the change in velocity of a box coordinate per frame.
+	kalmanProcessNoise = 0.1
+	// kalmanMeasurementNoise is the variance, in squared pixels, of a detected box coordinate.
+	kalmanMeasurementNoise = 10.0
+)
+
+// smoothing holds which values are smoothed, and for which outputs.
+type smoothing struct {
+	boxes      string
+	alpha      float64
+	scores     bool
+	vote       bool
+	detections bool
+	logs       bool
+}
+
+// enabled returns whether anything is smoothed for any output.
+func (s smoothing) enabled() bool {
+	return (s.detections || s.logs) && (s.boxes != BoxSmoothingNone || s.scores || s.vote)
+}
+
+// boxFilter smooths the successive boxes of a track.
+type boxFilter interface {
+	// update adds the box observed frames frames after the previous one, and returns the smoothed box.
+	update(box image.Rectangle, frames int) image.Rectangle
+}
+
+// boxState is a box as its center and size, which are smoothed independently.
+type boxState [4]float64
+
+func newBoxState(r image.Rectangle) boxState {
+	cx, cy := center(r)
+	return boxState{cx, cy, float64(r.Dx()), float64(r.Dy())}
+}
+
+func (b boxState) rect() image.Rectangle {
+	x0, y0 := math.Round(b[0]-b[2]/2), math.Round(b[1]-b[3]/2)
+	return image.Rect(int(x0), int(y0), int(x0+math.Round(b[2])), int(y0+math.Round(b[3])))
+}
+
+// emaFilter is an exponential moving average of the boxes.
+type emaFilter struct {
+	alpha  float64
+	state  boxState
+	primed bool
+}
+
+func (f *emaFilter) update(box image.Rectangle, frames int) image.Rectangle {
+	obs := newBoxState(box)
+	if !f.primed {
+		f.state, f.primed = obs, true
+		return box
+	}
+	// after a gap, the average moves as if the box had been seen on every missed frame
+	alpha := 1 - math.Pow(1-f.alpha, float64(max(frames, 1)))
+	for i := range f.state {
+		f.state[i] = alpha*obs[i] + (1-alpha)*f.state[i]
+	}
+	return f.state.rect()
+}
+
+// kalman1D is a constant velocity Kalman filter of a single coordinate, with one frame time steps.
+type kalman1D struct {
+	pos, vel float64
+	// cov is the covariance of the position and velocity
+	cov [2][2]float64
+}
+
+func newKalman1D(pos float64) kalman1D {
+	return kalman1D{pos: pos, cov: [2][2]float64{{kalmanMeasurementNoise, 0}, {0, 10 * kalmanMeasurementNoise}}}
+}
+
+func (k *kalman1D) predict() {
+	k.pos += k.vel
+	c := k.cov
+	// F P F^T with F = [[1, 1], [0, 1]], plus the noise of a random acceleration
+	k.cov[0][0] = c[0][0] + c[0][1] + c[1][0] + c[1][1] + kalmanProcessNoise/4
+	k.cov[0][1] = c[0][1] + c[1][1] + kalmanProcessNoise/2
+	k.cov[1][0] = c[1][0] + c[1][1] + kalmanProcessNoise/2
+	k.cov[1][1] = c[1][1] + kalmanProcessNoise
+}
+
+func (k *kalman1D) correct(z float64) {
+	c := k.cov
+	s := c[0][0] + kalmanMeasurementNoise
+	gainPos, gainVel := c[0][0]/s, c[1][0]/s
+	residual := z - k.pos
+	k.pos += gainPos * residual
+	k.vel += gainVel * residual
+	k.cov[0][0] = (1 - gainPos) * c[0][0]
+	k.cov[0][1] = (1 - gainPos) * c[0][1]
+	k.cov[1][0] = c[1][0] - gainVel*c[0][0]
+	k.cov[1][1] = c[1][1] - gainVel*c[0][1]
+}
+
+// kalmanFilter filters the center and size of the boxes.
+type kalmanFilter struct {
+	axes   [4]kalman1D
+	primed bool
+}
+
+func (f *kalmanFilter) update(box image.Rectangle, frames int) image.Rectangle {
+	obs := newBoxState(box)
+	if !f.primed {
+		for i := range f.axes {
+			f.axes[i] = newKalman1D(obs[i])
+		}
+		f.primed = true
+		return box
+	}
+	var state boxState
+	for i := range f.axes {
+		// the track may not have been seen for a few frames
+		for range max(frames, 1) {
+			f.axes[i].predict()
+		}
+		f.axes[i].correct(obs[i])
+		state[i] = f.axes[i].pos
+	}
+	return state.rect()
+}
+
+// trackSmoother holds the smoothed values of a single track.
+type trackSmoother struct {
+	boxes boxFilter
+	box   image.Rectangle
+	score float64
+	// label is the label of the class with the highest total score, given out while stepping
+	label     string
+	lastFrame int
+}
+
+// newTrackSmoother returns a smoother for a track first seen on frame.
+func (s smoothing) newTrackSmoother(frame int) *trackSmoother {
+	ts := &trackSmoother{lastFrame: frame}
+	switch s.boxes {
+	case BoxSmoothingEMA:
+		ts.boxes = &emaFilter{alpha: s.alpha}
+	case BoxSmoothingKalman:
+		ts.boxes = &kalmanFilter{}
+	}
+	return ts
+}
+
+// observe adds the latest state of the track to its smoothed values.
+func (t *myTracker) observe(tr *track) {
+	if t.smoothers == nil {
+		return
+	}
+	id := getTrackingLabel(tr)
+	ts, ok := t.smoothers[id]
+	if !ok {
+		ts = t.smoothing.newTrackSmoother(t.frame)
+		ts.score = tr.score
+		t.smoothers[id] = ts
+	}
+	if ts.boxes != nil {
+		ts.box = ts.boxes.update(*tr.Det.BoundingBox(), t.frame-ts.lastFrame)
+	}
+	if ok {
+		ts.score = t.smoothing.alpha*tr.score + (1-t.smoothing.alpha)*ts.score
+	}
+	if t.smoothing.vote {
+		if class := t.votedClass(id); class != "" {
+			ts.label = t.classLabel(tr, class)
+		}
+	}
+	ts.lastFrame = t.frame
+}
+
+// smoothedTrack returns a copy of the track with its smoothed box, score and class, or the track
+// itself if it was never observed. Coasting tracks keep their predicted box.
+func (t *myTracker) smoothedTrack(tr *track) *track {
+	id := getTrackingLabel(tr)
+	ts, ok := t.smoothers[id]
+	if !ok {
+		return tr
+	}
+	box, score := *tr.Det.BoundingBox(), tr.Det.Score()
+	if ts.boxes != nil && !tr.coasting {
+		box = ts.box
+	}
+	if t.smoothing.scores {
+		score = ts.score
+	}
+	label := tr.Det.Label()
+	if t.smoothing.vote && ts.label != "" {
+		label = ts.label
+	}
+	return replaceDetection(tr, &box, score, label)
+}
+
+// smoothedTracks returns the smoothed copy of every track.
+func (t *myTracker) smoothedTracks(tracks []*track) []*track {
+	out := make([]*track, 0, len(tracks))
+	for _, tr := range tracks {
+		out = append(out, t.smoothedTrack(tr))
+	}
+	return out
+}
+
+// rawDetection is the raw state of a track, as detected on the latest frame, returned by DoCommand.
+type rawDetection struct {
+	Label string
+	Class string
+	Score float64
+	Box   [4]int
+}
+
+func getRawDetections(tracks []*track) []rawDetection {
+	out := make([]rawDetection, 0, len(tracks))
+	for _, tr := range tracks {
+		if !tr.stable {
+			continue
+		}
+		box := tr.Det.BoundingBox()
+		out = append(out, rawDetection{
+			Label: tr.Det.Label(),
+			Class: tr.class,
+			Score: tr.score,
+			Box:   [4]int{box.Min.X, box.Min.Y, box.Max.X, box.Max.Y},
+		})
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"math"
+	"math/rand"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/vision"
+	"go.viam.com/rdk/testutils/inject"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+func TestEMAFilter(t *testing.T) {
+	f := &emaFilter{alpha: 0.5}
+	test.That(t, f.update(image.Rect(0, 0, 10, 10), 1), test.ShouldResemble, image.Rect(0, 0, 10, 10))
+	test.That(t, f.update(image.Rect(10, 0, 20, 10), 1), test.ShouldResemble, image.Rect(5, 0, 15, 10))
+	test.That(t, f.update(image.Rect(5, 0, 15, 10), 1), test.ShouldResemble, image.Rect(5, 0, 15, 10))
+	// after a missed frame, the latest box weighs as much as two frames of it would have
+	test.That(t, f.update(image.Rect(15, 0, 25, 10), 2), test.ShouldResemble, image.Rect(13, 0, 23, 10))
+}
+
+func TestScoresWithoutSmoothing(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	for _, score := range []float64{0.9, 0.5} {
+		fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, image.Rect(10, 0, 30, 20), score, "dog")}))
+	}
+	// the returned score is the one the track was first seen with, and the latest one is raw
+	dets, err := fakeTracker.Detections(context.Background(), nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(dets), test.ShouldEqual, 1)
+	test.That(t, dets[0].Score(), test.ShouldEqual, 0.9)
+	out, err := fakeTracker.DoCommand(context.Background(), map[string]interface{}{"raw_detections": true})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, out["raw_detections"].([]rawDetection)[0].Score, test.ShouldEqual, 0.5)
+}
+
+func TestKalmanFilterReducesJitter(t *testing.T) {
+	f := &kalmanFilter{}
+	rng := rand.New(rand.NewSource(4))
+	var rawErr, smoothErr float64
+	for frame := range 100 {
+		truth := image.Rect(3*frame, 50, 3*frame+40, 90)
+		noisy := truth.Add(image.Pt(rng.Intn(9)-4, rng.Intn(9)-4))
+		smoothed := f.update(noisy, 1)
+		if frame >= 20 {
+			rawErr += math.Abs(float64(noisy.Min.X - truth.Min.X))
+			smoothErr += math.Abs(float64(smoothed.Min.X - truth.Min.X))
+		}
+	}
+	test.That(t, smoothErr, test.ShouldBeLessThan, rawErr/2)
+
+	// after a few missed frames, the filter keeps up with the motion
+	smoothed := f.update(image.Rect(3*104, 50, 3*104+40, 90), 5)
+	test.That(t, math.Abs(float64(smoothed.Min.X-3*104)), test.ShouldBeLessThan, 3)
+}
+
+func TestSmoothedOutputs(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+		smoothing: smoothing{
+			boxes:      BoxSmoothingEMA,
+			alpha:      0.5,
+			scores:     true,
+			vote:       true,
+			detections: true,
+		},
+		smoothers: make(map[string]*trackSmoother),
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	frames := []struct {
+		box   image.Rectangle
+		score float64
+		class string
+	}{
+		{image.Rect(0, 0, 20, 20), 0.9, "dog"},
+		{image.Rect(10, 0, 30, 20), 0.5, "dog"},
+		{image.Rect(10, 0, 30, 20), 0.7, "cat"},
+	}
+	for _, f := range frames {
+		fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, f.box, f.score, f.class)}))
+	}
+
+	dets, err := fakeTracker.Detections(context.Background(), nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(dets), test.ShouldEqual, 1)
+	test.That(t, *dets[0].BoundingBox(), test.ShouldResemble, image.Rect(8, 0, 28, 20))
+	test.That(t, dets[0].Score(), test.ShouldAlmostEqual, 0.7)
+	checkLabel(t, &track{Det: dets[0]}, "dog_0")
+
+	// the raw values are still available
+	out, err := fakeTracker.DoCommand(context.Background(), map[string]interface{}{"raw_detections": true})
+	test.That(t, err, test.ShouldBeNil)
+	raw := out["raw_detections"].([]rawDetection)
+	test.That(t, len(raw), test.ShouldEqual, 1)
+	test.That(t, raw[0].Box, test.ShouldResemble, [4]int{10, 0, 30, 20})
+	test.That(t, raw[0].Score, test.ShouldEqual, 0.7)
+	test.That(t, raw[0].Class, test.ShouldEqual, "cat")
+	test.That(t, raw[0].Label, test.ShouldEqual, dets[0].Label())
+
+	// the class with the highest total score wins
+	test.That(t, fakeTracker.votedClass("dog_0"), test.ShouldEqual, "dog")
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, image.Rect(10, 0, 30, 20), 0.8, "cat")}))
+	test.That(t, fakeTracker.votedClass("dog_0"), test.ShouldEqual, "cat")
+	dets, err = fakeTracker.Detections(context.Background(), nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	checkLabel(t, &track{Det: dets[0]}, "cat_0")
+	// the voted label is given out while stepping, so reading the detections does not give out counters
+	test.That(t, fakeTracker.classCounter, test.ShouldResemble, map[string]int{"dog": 0, "cat": 0})
+	_, err = fakeTracker.Detections(context.Background(), nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, fakeTracker.classCounter, test.ShouldResemble, map[string]int{"dog": 0, "cat": 0})
+
+	// forgotten tracks lose their smoothed values
+	fakeTracker.forgetTrack("dog_0")
+	test.That(t, fakeTracker.smoothers, test.ShouldBeEmpty)
+	test.That(t, fakeTracker.classVotes, test.ShouldBeEmpty)
+}
+
+func TestSmoothedLogs(t *testing.T) {
+	bounds := image.Rect(0, 0, 200, 100)
+	fakeTracker := &myTracker{
+		logger:              logging.NewTestLogger(t),
+		cancelContext:       context.Background(),
+		classCounter:        make(map[string]int),
+		bufferSize:          10,
+		minTrackPersistence: 1,
+		maxTrackHistory:     DefaultMaxTrackHistory,
+		smoothing:           smoothing{boxes: BoxSmoothingNone, vote: true, logs: true},
+		smoothers:           make(map[string]*trackSmoother),
+	}
+	test.That(t, fakeTracker.configureTracks(), test.ShouldBeNil)
+	box := image.Rect(10, 0, 30, 20)
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box, 0.3, "dog")}))
+	fakeTracker.step(newTracks([]objdet.Detection{objdet.NewDetection(bounds, box, 0.9, "cat")}))
+
+	// the logs get the voted class of the newly stable track
+	test.That(t, len(fakeTracker.allFreshObjects.objects), test.ShouldEqual, 1)
+	test.That(t, fakeTracker.allFreshObjects.objects[0].Label, test.ShouldEqual, "cat")
+
+	// while the detections keep the class of the class policy
+	dets, err := fakeTracker.Detections(context.Background(), nil, nil)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(dets), test.ShouldEqual, 1)
+	checkLabel(t, &track{Det: dets[0]}, "dog_0")
+}
+
+func TestSmoothedOutputsConfig(t *testing.T) {
+	cfg := &Config{CameraName: "camera", DetectorName: "detector", SmoothedOutputs: []string{SmoothedOutputLogs, SmoothedOutputDetections}}
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	cfg.SmoothedOutputs = []string{"classifications"}
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldNotBeNil)
+
+	tracker := getOnDemandTracker(t)
+	reconfigure := func(cfg *Config) smoothing {
+		err := tracker.Reconfigure(context.Background(), resource.Dependencies{
+			vision.Named("detector"): &inject.VisionService{},
+		}, resource.Config{Name: "test", API: vision.API, ConvertedAttributes: cfg})
+		test.That(t, err, test.ShouldBeNil)
+		return tracker.smoothing
+	}
+	// the detections are smoothed by default
+	s := reconfigure(&Config{DetectorName: "detector", Mode: ModeOnDemand, MajorityVoteLabels: true})
+	test.That(t, s.vote, test.ShouldBeTrue)
+	test.That(t, s.detections, test.ShouldBeTrue)
+	test.That(t, s.logs, test.ShouldBeFalse)
+	test.That(t, tracker.classPolicy, test.ShouldEqual, ClassPolicyLocked)
+	s = reconfigure(&Config{DetectorName: "detector", Mode: ModeOnDemand, MajorityVoteLabels: true, SmoothedOutputs: []string{SmoothedOutputLogs}})
+	test.That(t, s.detections, test.ShouldBeFalse)
+	test.That(t, s.logs, test.ShouldBeTrue)
+}
@@ -3,17 +3,14 @@ package object_tracker
 
 import (
 	"image"
-	"strings"
+	"time"
+
+	"github.com/viam-modules/object-tracking/tracking"
 )
 
 // IOU returns the intersection over union of 2 rectangles
 func IOU(r1, r2 *image.Rectangle) float64 {
-	intersection := r1.Intersect(*r2)
-	if intersection.Empty() {
-		return 0
-	}
-	union := r1.Union(*r2)
-	return float64(intersection.Dx()*intersection.Dy()) / float64(union.Dx()*union.Dy())
+	return tracking.IOU(*r1, *r2)
 }
 
 // PredictNextFrame assumes we have two rectangles on frames n-1 and n. We use those
@@ -30,32 +27,65 @@ func PredictNextFrame(old, curr image.Rectangle) image.Rectangle {
 	return image.Rect(int(x0), int(y0), int(x1), int(y1))
 }
 
-// BuildMatchingMatrix sets up a cost matrix for the Hungarian algorithm.
-// We compare the predicted location (if enough track info available) to detected location
-// In this implementation, cost is -IOU between bboxes (b/c solver will find min)
-func (t *myTracker) BuildMatchingMatrix(oldDetections, newDetections []*track) [][]float64 {
-	h, w := len(oldDetections), len(newDetections)
-	matchMtx := make([][]float64, h)
-
-	for i, oldD := range oldDetections {
-		row := make([]float64, w)
-		// Find track. If long enough, make prediction and use that. Otherwise use self
-		label := strings.Join(strings.Split(oldD.Det.Label(), "_")[0:2], "_")
-		track := t.tracks[label]
-		if len(track) >= 2 {
-			pred := PredictNextFrame(
-				*track[len(track)-2].Det.BoundingBox(),
-				*track[len(track)-1].Det.BoundingBox(),
-			)
-			for j, newD := range newDetections {
-				row[j] = -IOU(&pred, newD.Det.BoundingBox())
-			}
-		} else {
-			for j, newD := range newDetections {
-				row[j] = -IOU(oldD.Det.BoundingBox(), newD.Det.BoundingBox())
-			}
+// sortMotion is the motion model of the tracker, which predicts the box of a track from the last two
+// boxes of its history like SORT. The history of a track is not extended while it is missed, so a
+// lost track keeps moving at the velocity it had when it was lost, one step for each missed frame.
+type sortMotion struct{}
+
+// Predict implements tracking.MotionModel.
+func (sortMotion) Predict(tr *tracking.Track, at time.Time) image.Rectangle {
+	return predictAfter(tr, 1+tr.Misses)
+}
+
+// predictAfter returns where the track is expected to be the given number of frames after the last
+// box of its history. If not enough track info is available, the track's own box is returned, which
+// Warp already moved along with the camera.
+func predictAfter(tr *tracking.Track, steps int) image.Rectangle {
+	n := len(tr.History)
+	if n < 2 {
+		return tr.Box
+	}
+	last := tr.History[n-1].Box
+	next := PredictNextFrame(tr.History[n-2].Box, last)
+	return next.Add(next.Min.Sub(last.Min).Mul(steps - 1))
+}
+
+// predictBox returns where the track is expected to be on the next frame. The history of the track,
+// which compensateMotion keeps in the coordinates of the latest frame, is the one of the tracking
+// package. Tracks it does not know are moved along with the camera, if it moved.
+func (t *myTracker) predictBox(tr *track) image.Rectangle {
+	if t.tracks != nil {
+		if et, ok := t.tracks.Track(getTrackingLabel(tr)); ok {
+			return sortMotion{}.Predict(&et, t.frameTime)
+		}
+	}
+	pred := *tr.Det.BoundingBox()
+	if t.motion != nil {
+		pred = t.motion.warpRect(pred)
+	}
+	return pred
+}
+
+// coastingTracks returns the stable tracks missed on the last coast_frames frames at most, moved to
+// where they are expected to be on this frame and clipped to the image. Tracks that left the image
+// are not returned.
+func (t *myTracker) coastingTracks() []*track {
+	var out []*track
+	for _, et := range t.tracks.Tracks() {
+		tr, ok := t.lastSeen[et.ID]
+		if !ok || et.Misses == 0 || et.Misses > t.coastFrames {
+			continue
+		}
+		box := predictAfter(&et, et.Misses)
+		if bounds := ImageBoundsFromDet(tr.Det); bounds != nil {
+			box = box.Intersect(*bounds)
+		}
+		if box.Empty() {
+			continue
 		}
-		matchMtx[i] = row
+		coasting := ReplaceBoundingBox(tr, &box)
+		coasting.coasting = true
+		out = append(out, coasting)
 	}
-	return matchMtx
+	return out
 }
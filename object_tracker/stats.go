@@ -0,0 +1,112 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains a fixed-size latency histogram used to benchmark the tracking loop.
+package object_tracker
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyBucketBounds are the upper bounds of the histogram buckets. The last bucket
+// catches everything above the last bound.
+var latencyBucketBounds = [...]time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// latencyHistogram records durations in a constant amount of memory, regardless of how long
+// the tracker has been running.
+type latencyHistogram struct {
+	mutex   sync.Mutex
+	buckets [len(latencyBucketBounds) + 1]int64
+	count   int64
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+}
+
+// newLatencyHistogram returns an empty histogram.
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{}
+}
+
+// Record adds a duration to the histogram.
+func (h *latencyHistogram) Record(d time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	idx := len(latencyBucketBounds)
+	for i, bound := range latencyBucketBounds {
+		if d <= bound {
+			idx = i
+			break
+		}
+	}
+	h.buckets[idx]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Reset empties the histogram.
+func (h *latencyHistogram) Reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.buckets = [len(latencyBucketBounds) + 1]int64{}
+	h.count = 0
+	h.sum = 0
+	h.min = 0
+	h.max = 0
+}
+
+// Benchmark summarizes the histogram. Percentiles are the upper bound of the bucket
+// the percentile falls into, capped by the slowest recorded run.
+func (h *latencyHistogram) Benchmark() benchmark {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.count == 0 {
+		return benchmark{}
+	}
+	return benchmark{
+		Slowest:      float64(h.max),
+		Fastest:      float64(h.min),
+		Average:      float64(time.Duration(int64(h.sum) / h.count)),
+		P50:          float64(h.percentile(0.50)),
+		P90:          float64(h.percentile(0.90)),
+		P99:          float64(h.percentile(0.99)),
+		NumberOfRuns: int(h.count),
+	}
+}
+
+// percentile must be called with the mutex held.
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	target := int64(p * float64(h.count))
+	if target < 1 {
+		target = 1
+	}
+	var seen int64
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= target {
+			if i < len(latencyBucketBounds) && latencyBucketBounds[i] < h.max {
+				return latencyBucketBounds[i]
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
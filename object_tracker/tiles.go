@@ -0,0 +1,163 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the tiled detection mode, where the detector runs on overlapping tiles of the
+// frame so that small objects are not downscaled away on high resolution cameras.
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"sort"
+	"sync"
+
+	objdet "go.viam.com/rdk/vision/objectdetection"
+)
+
+const (
+	// DefaultTileOverlap is the fraction of a tile that overlaps its neighbours.
+	DefaultTileOverlap = 0.2
+	// DefaultTileMergeThreshold is the fraction of the smaller of two boxes of the same class that
+	// must be covered by the other for them to be merged as one object cut by a seam.
+	DefaultTileMergeThreshold = 0.5
+	// maxParallelTiles is the number of tiles sent to the detector at the same time.
+	maxParallelTiles = 8
+)
+
+// tiling holds how the frame is split into tiles. A zero size disables tiling.
+type tiling struct {
+	size    int
+	overlap float64
+	// fullFrame is true when the detector also runs on the whole frame, for the large objects
+	fullFrame bool
+	// mergeThreshold is the intersection over the smaller box above which two boxes are merged
+	mergeThreshold float64
+}
+
+// enabled returns whether the frame is split into tiles.
+func (tl *tiling) enabled() bool {
+	return tl != nil && tl.size > 0
+}
+
+// tileStarts returns the start of every tile along an axis from lo to hi. The last tile is
+// aligned with hi so that every tile has the full size.
+func (tl *tiling) tileStarts(lo, hi int) []int {
+	if hi-lo <= tl.size {
+		return []int{lo}
+	}
+	step := max(int(float64(tl.size)*(1-tl.overlap)), 1)
+	var starts []int
+	for s := lo; ; s += step {
+		if s+tl.size >= hi {
+			starts = append(starts, hi-tl.size)
+			return starts
+		}
+		starts = append(starts, s)
+	}
+}
+
+// tiles returns the rectangles of the area the detector should run on, which is the area itself
+// when tiling is disabled.
+func (tl *tiling) tiles(area image.Rectangle) []image.Rectangle {
+	if !tl.enabled() {
+		return []image.Rectangle{area}
+	}
+	var out []image.Rectangle
+	if tl.fullFrame {
+		out = append(out, area)
+	}
+	for _, y := range tl.tileStarts(area.Min.Y, area.Max.Y) {
+		for _, x := range tl.tileStarts(area.Min.X, area.Max.X) {
+			out = append(out, image.Rect(x, y, x+tl.size, y+tl.size).Intersect(area))
+		}
+	}
+	return out
+}
+
+// intersectionOverSmaller returns the area of the intersection of the boxes over the area of the
+// smaller one.
+func intersectionOverSmaller(a, b image.Rectangle) float64 {
+	inter := a.Intersect(b)
+	smaller := min(a.Dx()*a.Dy(), b.Dx()*b.Dy())
+	if inter.Empty() || smaller == 0 {
+		return 0
+	}
+	return float64(inter.Dx()*inter.Dy()) / float64(smaller)
+}
+
+// tileDetection is a detection in the coordinates of the whole image, with the tile it was found in.
+type tileDetection struct {
+	det  objdet.Detection
+	tile image.Rectangle
+}
+
+// mergeSeams merges the boxes of the same class found in several tiles for the same object. An
+// object cut by a seam is seen whole in one tile and in part in the other, so boxes of different
+// tiles are merged when one mostly covers the other, and they overlap where both tiles see the
+// image. They are merged into the box around both with the highest score. Boxes of the same tile
+// are separate objects, and are left to duplicate suppression.
+func (tl *tiling) mergeSeams(dets []tileDetection, bounds image.Rectangle) []objdet.Detection {
+	sorted := make([]tileDetection, len(dets))
+	copy(sorted, dets)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].det.Score() > sorted[j].det.Score()
+	})
+	merged := make([]bool, len(sorted))
+	out := make([]objdet.Detection, 0, len(sorted))
+	for i, d := range sorted {
+		if merged[i] {
+			continue
+		}
+		box := *d.det.BoundingBox()
+		for j := i + 1; j < len(sorted); j++ {
+			other := sorted[j]
+			if merged[j] || other.tile == d.tile || other.det.Label() != d.det.Label() {
+				continue
+			}
+			otherBox := *other.det.BoundingBox()
+			seam := d.tile.Intersect(other.tile)
+			if !d.det.BoundingBox().Intersect(otherBox).In(seam) {
+				continue
+			}
+			if intersectionOverSmaller(box, otherBox) > tl.mergeThreshold {
+				merged[j] = true
+				box = box.Union(otherBox)
+			}
+		}
+		out = append(out, objdet.NewDetection(bounds, box, d.det.Score(), d.det.Label()))
+	}
+	return out
+}
+
+// detectTiles runs the detector on every tile of the area of the image in parallel, and returns
+// the detections in the coordinates of the whole image.
+func (t *myTracker) detectTiles(ctx context.Context, img image.Image, area image.Rectangle) ([]objdet.Detection, error) {
+	tiles := t.tiling.tiles(area)
+	if len(tiles) == 1 {
+		return t.detectIn(ctx, img, tiles[0])
+	}
+	results := make([][]objdet.Detection, len(tiles))
+	errs := make([]error, len(tiles))
+	workers := make(chan struct{}, maxParallelTiles)
+	var wg sync.WaitGroup
+	for i, tile := range tiles {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			results[i], errs[i] = t.detectIn(ctx, img, tile)
+		}()
+	}
+	wg.Wait()
+	var all []tileDetection
+	for i, tile := range tiles {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		for _, d := range results[i] {
+			all = append(all, tileDetection{det: d, tile: tile})
+		}
+	}
+	return t.tiling.mergeSeams(all, img.Bounds()), nil
+}
@@ -0,0 +1,118 @@
+package object_tracker
+
+import (
+	"context"
+	"image"
+	"sync"
+	"testing"
+
+	"go.viam.com/rdk/testutils/inject"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+func TestTiles(t *testing.T) {
+	tl := &tiling{size: 100, overlap: 0.2}
+	area := image.Rect(0, 0, 250, 100)
+	// tiles start every 80 pixels, and the last one is aligned with the edge
+	test.That(t, tl.tiles(area), test.ShouldResemble, []image.Rectangle{
+		image.Rect(0, 0, 100, 100),
+		image.Rect(80, 0, 180, 100),
+		image.Rect(150, 0, 250, 100),
+	})
+
+	// an area smaller than a tile is a single tile
+	small := image.Rect(10, 10, 60, 40)
+	test.That(t, tl.tiles(small), test.ShouldResemble, []image.Rectangle{small})
+
+	tl.fullFrame = true
+	test.That(t, tl.tiles(area)[0], test.ShouldResemble, area)
+	test.That(t, len(tl.tiles(area)), test.ShouldEqual, 4)
+
+	var disabled *tiling
+	test.That(t, disabled.tiles(area), test.ShouldResemble, []image.Rectangle{area})
+}
+
+func TestDetectTiles(t *testing.T) {
+	bounds := image.Rect(0, 0, 250, 100)
+	img := image.NewRGBA(bounds)
+	// a person at (70, 20)-(95, 80) in the whole frame, seen whole by the first tile and cut by the
+	// edge of the second one, and a small dog only in the last tile
+	var mutex sync.Mutex
+	var seen []image.Rectangle
+	detector := &inject.VisionService{
+		DetectionsFunc: func(ctx context.Context, tile image.Image, extra map[string]interface{}) ([]objdet.Detection, error) {
+			test.That(t, tile.Bounds().Min, test.ShouldResemble, image.Point{})
+			mutex.Lock()
+			defer mutex.Unlock()
+			seen = append(seen, tile.Bounds())
+			// find which tile this is from the pixel written at its origin
+			switch {
+			case isTile(tile, 0):
+				return []objdet.Detection{objdet.NewDetection(tile.Bounds(), image.Rect(70, 20, 95, 80), 0.9, "person")}, nil
+			case isTile(tile, 80):
+				return []objdet.Detection{objdet.NewDetection(tile.Bounds(), image.Rect(0, 20, 15, 80), 0.6, "person")}, nil
+			default:
+				return []objdet.Detection{objdet.NewDetection(tile.Bounds(), image.Rect(50, 50, 60, 60), 0.8, "dog")}, nil
+			}
+		},
+	}
+	// mark the origin of every tile with its x coordinate
+	for _, x := range []int{0, 80, 150} {
+		img.Pix[img.PixOffset(x, 0)] = uint8(x)
+	}
+	fakeTracker := &myTracker{
+		detector: detector,
+		tiling:   &tiling{size: 100, overlap: 0.2, mergeThreshold: DefaultTileMergeThreshold},
+	}
+	dets, err := fakeTracker.detect(context.Background(), img)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(seen), test.ShouldEqual, 3)
+	for _, s := range seen {
+		test.That(t, s, test.ShouldResemble, image.Rect(0, 0, 100, 100))
+	}
+	test.That(t, len(dets), test.ShouldEqual, 2)
+	// the two parts of the person are merged, in the coordinates of the whole frame
+	test.That(t, dets[0].Label(), test.ShouldEqual, "person")
+	test.That(t, dets[0].Score(), test.ShouldEqual, 0.9)
+	test.That(t, *dets[0].BoundingBox(), test.ShouldResemble, image.Rect(70, 20, 95, 80))
+	test.That(t, *ImageBoundsFromDet(dets[0]), test.ShouldResemble, bounds)
+	test.That(t, dets[1].Label(), test.ShouldEqual, "dog")
+	test.That(t, *dets[1].BoundingBox(), test.ShouldResemble, image.Rect(200, 50, 210, 60))
+}
+
+func TestMergeSeams(t *testing.T) {
+	bounds := image.Rect(0, 0, 250, 100)
+	tl := &tiling{size: 100, overlap: 0.2, mergeThreshold: DefaultTileMergeThreshold}
+	left, right := image.Rect(0, 0, 100, 100), image.Rect(80, 0, 180, 100)
+	det := func(box image.Rectangle, score float64) objdet.Detection {
+		return objdet.NewDetection(bounds, box, score, "person")
+	}
+	// two people standing close together in the same tile are not merged
+	out := tl.mergeSeams([]tileDetection{
+		{det(image.Rect(10, 20, 40, 80), 0.9), left},
+		{det(image.Rect(20, 20, 45, 80), 0.8), left},
+	}, bounds)
+	test.That(t, out, test.ShouldHaveLength, 2)
+
+	// boxes of different tiles that overlap outside of the seam are not merged either
+	out = tl.mergeSeams([]tileDetection{
+		{det(image.Rect(40, 20, 75, 80), 0.9), left},
+		{det(image.Rect(50, 20, 90, 80), 0.8), right},
+	}, bounds)
+	test.That(t, out, test.ShouldHaveLength, 2)
+
+	// a person cut by the seam is one box
+	out = tl.mergeSeams([]tileDetection{
+		{det(image.Rect(70, 20, 95, 80), 0.9), left},
+		{det(image.Rect(80, 20, 95, 80), 0.6), right},
+	}, bounds)
+	test.That(t, out, test.ShouldHaveLength, 1)
+	test.That(t, *out[0].BoundingBox(), test.ShouldResemble, image.Rect(70, 20, 95, 80))
+}
+
+// isTile returns whether the tile was copied from the given x coordinate of the marked image.
+func isTile(tile image.Image, x int) bool {
+	r, _, _, _ := tile.At(0, 0).RGBA()
+	return int(r>>8) == x
+}
@@ -8,25 +8,34 @@ import (
 	objdet "go.viam.com/rdk/vision/objectdetection"
 )
 
-// A track stores information about the bounding box as well as its persistence properties
-// across frames
+// A track stores how a track of the tracking package is presented on a frame: its detection, with
+// the label of the track, and whether it is stable
 type track struct {
-	Det              objdet.Detection
-	persistenceLimit int
-	persistenceCount int
-	stable           bool
+	Det objdet.Detection
+	id  string
+	// class and score are the class and score given by the detector on the latest frame,
+	// while the detection keeps the score it was first seen with
+	class  string
+	score  float64
+	stable bool
+	// propagated is true when the box was moved by template matching rather than detected
+	propagated bool
+	// coasting is true when the track is lost and reported at its predicted box
+	coasting bool
+	// truncated is true when the box touches the image border, and may not cover the whole object
+	truncated bool
 }
 
-// newTrack turns a bounding box into a new track with a fresh persistence counter
-func newTrack(det objdet.Detection, lim int) *track {
-	return &track{det, lim, 0, false}
+// newTrack turns a bounding box into a new track, which is not stable
+func newTrack(det objdet.Detection) *track {
+	return &track{det, "", strings.ToLower(det.Label()), det.Score(), false, false, false, false}
 }
 
-// newTracks turns a slice of bounding boxes into a track with a fresh persistence counter
-func newTracks(dets []objdet.Detection, lim int) []*track {
+// newTracks turns a slice of bounding boxes into new tracks
+func newTracks(dets []objdet.Detection) []*track {
 	tracks := make([]*track, 0, len(dets))
 	for _, d := range dets {
-		tracks = append(tracks, newTrack(d, lim))
+		tracks = append(tracks, newTrack(d))
 	}
 	return tracks
 }
@@ -35,9 +44,13 @@ func newTracks(dets []objdet.Detection, lim int) []*track {
 func (tr *track) clone() *track {
 	return &track{
 		tr.Det,
-		tr.persistenceLimit,
-		tr.persistenceCount,
+		tr.id,
+		tr.class,
+		tr.score,
 		tr.stable,
+		tr.propagated,
+		tr.coasting,
+		tr.truncated,
 	}
 }
 
@@ -46,17 +59,6 @@ func (tr *track) isStable() bool {
 	return tr.stable
 }
 
-// addPersistence add to the persistence counter
-func (tr *track) addPersistence() {
-	if tr.stable {
-		return
-	}
-	tr.persistenceCount += 1
-	if tr.persistenceCount >= tr.persistenceLimit {
-		tr.stable = true
-	}
-}
-
 // return only the bounding boxes associated with stable tracks
 func getStableDetections(tracks []*track) []objdet.Detection {
 	dets := make([]objdet.Detection, 0, len(tracks))
@@ -68,12 +70,27 @@ func getStableDetections(tracks []*track) []objdet.Detection {
 	return dets
 }
 
+// stableTracks returns the stable tracks of the current frame.
+func (t *myTracker) stableTracks() []*track {
+	t.currDetections.mutex.RLock()
+	defer t.currDetections.mutex.RUnlock()
+	tracks := make([]*track, 0, len(t.currDetections.detections))
+	for _, tr := range t.currDetections.detections {
+		if tr.stable {
+			tracks = append(tracks, tr)
+		}
+	}
+	return tracks
+}
+
 // trackedObject is the log info associated with the track that is stable
 type trackedObject struct {
 	FullLabel string
 	Label     string
 	Id        int
 	Time      string
+	// PreviousLabel is the label the track had before it changed class, empty for new objects
+	PreviousLabel string `json:",omitempty"`
 }
 
 func newTrackedObjectFromLabel(label string) (trackedObject, error) {
@@ -0,0 +1,529 @@
+// Package object_tracker implements an object tracker as a Viam vision service
+// This file contains the tuning of the attributes of the tracker, which runs the tracker in batch on
+// sequences of precomputed detections with ground truth, and keeps the attributes that score best.
+package object_tracker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"maps"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/logging"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+
+	"github.com/viam-modules/object-tracking/eval"
+)
+
+const (
+	// DefaultTuneTrials is the number of attribute sets a random search tries.
+	DefaultTuneTrials = 50
+	// TuneMetricHOTA, TuneMetricMOTA and TuneMetricIDF1 are the metrics a tuning can maximize.
+	TuneMetricHOTA = "hota"
+	TuneMetricMOTA = "mota"
+	TuneMetricIDF1 = "idf1"
+	// tuneIDStride separates the IDs of the sequences scored together.
+	tuneIDStride = 1000000
+)
+
+// TuneSequence is a sequence of precomputed detections with ground truth.
+type TuneSequence struct {
+	Name string
+	// Detections is a JSONL file with one {"frame": N, "detections": [...]} object per frame, or a
+	// MOTChallenge det.txt file
+	Detections string
+	// GroundTruth is a MOTChallenge gt.txt file
+	GroundTruth string
+	// Width and Height are the size of the frames, 0 if unknown. Regions need them.
+	Width, Height int
+	// Frames is the number of frames, 0 to stop at the last frame with detections or ground truth
+	Frames int
+}
+
+// FindTuneSequences returns the sequence in dir, or the sequences in its subdirectories if it has
+// none. A sequence directory has a gt.txt or gt/gt.txt ground truth, det.txt, det/det.txt or
+// detections.jsonl detections, and optionally the seqinfo.ini file of MOTChallenge for the size of
+// the frames.
+func FindTuneSequences(dir string) ([]TuneSequence, error) {
+	if seq, ok := findTuneSequence(dir); ok {
+		return []TuneSequence{seq}, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read sequences %v", dir)
+	}
+	var out []TuneSequence
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if seq, ok := findTuneSequence(filepath.Join(dir, e.Name())); ok {
+			out = append(out, seq)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.Errorf("no sequence with ground truth and detections in %v", dir)
+	}
+	return out, nil
+}
+
+// findTuneSequence returns the sequence in dir, if it has one.
+func findTuneSequence(dir string) (TuneSequence, bool) {
+	first := func(names ...string) string {
+		for _, name := range names {
+			if path := filepath.Join(dir, name); fileExists(path) {
+				return path
+			}
+		}
+		return ""
+	}
+	seq := TuneSequence{
+		Name:        filepath.Base(dir),
+		GroundTruth: first("gt.txt", filepath.Join("gt", "gt.txt")),
+		Detections:  first("det.txt", filepath.Join("det", "det.txt"), "detections.jsonl"),
+	}
+	if seq.GroundTruth == "" || seq.Detections == "" {
+		return TuneSequence{}, false
+	}
+	if info, err := readSeqInfo(filepath.Join(dir, "seqinfo.ini")); err == nil {
+		seq.Width, seq.Height, seq.Frames = info["imWidth"], info["imHeight"], info["seqLength"]
+	}
+	return seq, true
+}
+
+// fileExists returns true if path is a file.
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// readSeqInfo returns the integer values of a MOTChallenge seqinfo.ini file.
+func readSeqInfo(path string) (map[string]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			out[strings.TrimSpace(key)] = n
+		}
+	}
+	return out, scanner.Err()
+}
+
+// TuneParameter holds the values an attribute can take.
+type TuneParameter struct {
+	// Values are all tried by a grid search, and drawn from by a random search
+	Values []interface{}
+	// Min and Max bound the values drawn by a random search when there are no Values. The values
+	// are integers if Integer is set, and are rounded to 3 decimals otherwise.
+	Min, Max float64
+	Integer  bool
+}
+
+// UnmarshalJSON reads a list of values, or a {"min": ..., "max": ...} range, which is a range of
+// integers if both bounds are written without a decimal point.
+func (p *TuneParameter) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			return errors.New("tuned values must not be empty")
+		}
+		p.Values = make([]interface{}, len(v))
+		for i, value := range v {
+			p.Values[i] = tuneNumber(value)
+		}
+		return nil
+	case map[string]interface{}:
+		lo, okMin := v["min"].(json.Number)
+		hi, okMax := v["max"].(json.Number)
+		if !okMin || !okMax || len(v) != 2 {
+			return errors.New(`tuned range must be {"min": ..., "max": ...}`)
+		}
+		var err error
+		if p.Min, err = lo.Float64(); err != nil {
+			return err
+		}
+		if p.Max, err = hi.Float64(); err != nil {
+			return err
+		}
+		if p.Max < p.Min {
+			return errors.New("tuned range must have max at least min")
+		}
+		p.Integer = !strings.ContainsAny(lo.String()+hi.String(), ".eE")
+		return nil
+	default:
+		return errors.New(`tuned attribute must be a list of values or a {"min": ..., "max": ...} range`)
+	}
+}
+
+// tuneNumber converts the JSON numbers of a value to integers or floats, so that they are written
+// back as they were read.
+func tuneNumber(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, x := range v {
+			out[i] = tuneNumber(x)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, x := range v {
+			out[k] = tuneNumber(x)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// draw returns a random value of the parameter.
+func (p TuneParameter) draw(rng *rand.Rand) interface{} {
+	if len(p.Values) > 0 {
+		return p.Values[rng.Intn(len(p.Values))]
+	}
+	if p.Integer {
+		return int64(p.Min) + rng.Int63n(int64(p.Max)-int64(p.Min)+1)
+	}
+	return math.Round((p.Min+rng.Float64()*(p.Max-p.Min))*1000) / 1000
+}
+
+// TuneSpace holds the values of the tuned attributes, by their name in the config of the service.
+type TuneSpace map[string]TuneParameter
+
+// isGrid returns true if every attribute has a list of values.
+func (s TuneSpace) isGrid() bool {
+	for _, p := range s {
+		if len(p.Values) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// candidates returns every combination of values if the space is a grid, or trials combinations
+// drawn at random otherwise.
+func (s TuneSpace) candidates(trials int, seed int64) []map[string]interface{} {
+	names := slices.Sorted(maps.Keys(s))
+	var out []map[string]interface{}
+	if !s.isGrid() {
+		rng := rand.New(rand.NewSource(seed))
+		for range trials {
+			values := make(map[string]interface{}, len(names))
+			for _, name := range names {
+				values[name] = s[name].draw(rng)
+			}
+			out = append(out, values)
+		}
+		return out
+	}
+	// the combinations are counted in a mixed radix, the last attribute changing fastest
+	total := 1
+	for _, name := range names {
+		total *= len(s[name].Values)
+	}
+	for k := range total {
+		values := make(map[string]interface{}, len(names))
+		for i := len(names) - 1; i >= 0; i-- {
+			p := s[names[i]]
+			values[names[i]] = p.Values[k%len(p.Values)]
+			k /= len(p.Values)
+		}
+		out = append(out, values)
+	}
+	return out
+}
+
+// TuneOptions holds the inputs of a tuning.
+type TuneOptions struct {
+	Sequences []TuneSequence
+	// Base holds the attributes that are not tuned, as in the config of the service, and can be nil
+	Base  map[string]interface{}
+	Space TuneSpace
+	// Trials is the number of attribute sets tried when an attribute has a range, DefaultTuneTrials
+	// if 0. A grid search tries every combination instead.
+	Trials int
+	// Seed makes a random search reproducible
+	Seed int64
+	// Metric is the metric maximized over all sequences, TuneMetricHOTA if empty
+	Metric string
+	// MOTClass is the class of the detections of MOTChallenge files, which do not have any
+	MOTClass string
+	// Workers is the number of trials run at once, the number of CPUs if 0
+	Workers int
+}
+
+// TuneTrial is the score of a set of values of the tuned attributes.
+type TuneTrial struct {
+	// Values holds the values of the tuned attributes
+	Values map[string]interface{}
+	// Score is the tuned metric, over all sequences
+	Score float64
+	// Metrics are the metrics over all sequences, and Sequences the metrics of each, by name
+	Metrics   eval.Metrics
+	Sequences map[string]eval.Metrics
+	// Err is why the tracker could not run with the values, in which case the trial has no score
+	Err error
+}
+
+// TuneResult is the outcome of a tuning.
+type TuneResult struct {
+	// Attributes are the base attributes with the values of the best trial, ready to paste in the
+	// config of the service
+	Attributes map[string]interface{}
+	// Trials holds every trial, best first and failed ones last. The first one is the best.
+	Trials []TuneTrial
+}
+
+// tuneData is a sequence read in memory.
+type tuneData struct {
+	name   string
+	frames int
+	// bounds are the bounds of the frames, empty if unknown
+	bounds     image.Rectangle
+	detections map[int][]objdet.Detection
+	gt         eval.Sequence
+}
+
+// Tune runs the tracker on every sequence with each set of values of the space, and returns the
+// trials sorted by the metric over all sequences. Sequences are scored together, so longer and more
+// crowded ones weigh more.
+func Tune(ctx context.Context, opts TuneOptions, logger logging.Logger) (*TuneResult, error) {
+	if len(opts.Sequences) == 0 {
+		return nil, errors.New("tuning needs at least one sequence")
+	}
+	if len(opts.Space) == 0 {
+		return nil, errors.New("tuning needs at least one tuned attribute")
+	}
+	if opts.Metric == "" {
+		opts.Metric = TuneMetricHOTA
+	}
+	if _, err := tuneScore(opts.Metric, eval.Metrics{}); err != nil {
+		return nil, err
+	}
+	if opts.Trials <= 0 {
+		opts.Trials = DefaultTuneTrials
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+	data := make([]*tuneData, 0, len(opts.Sequences))
+	for _, seq := range opts.Sequences {
+		d, err := readTuneSequence(seq, opts.MOTClass)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, d)
+	}
+
+	candidates := opts.Space.candidates(opts.Trials, opts.Seed)
+	trials := make([]TuneTrial, len(candidates))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(opts.Workers, len(candidates)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range next {
+				trials[k] = runTuneTrial(ctx, opts, data, candidates[k], logger)
+			}
+		}()
+	}
+	for k := range candidates {
+		next <- k
+	}
+	close(next)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(trials, func(i, j int) bool {
+		if (trials[i].Err == nil) != (trials[j].Err == nil) {
+			return trials[i].Err == nil
+		}
+		return trials[i].Score > trials[j].Score
+	})
+	if trials[0].Err != nil {
+		return nil, errors.Wrap(trials[0].Err, "no tuned attributes were valid")
+	}
+	attrs, _, err := tuneConfig(opts.Base, trials[0].Values)
+	if err != nil {
+		return nil, err
+	}
+	return &TuneResult{Attributes: attrs, Trials: trials}, nil
+}
+
+// readTuneSequence reads the detections and ground truth of a sequence.
+func readTuneSequence(seq TuneSequence, motClass string) (*tuneData, error) {
+	pushed, err := readDetections(seq.Detections, motClass)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(seq.GroundTruth)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read ground truth %v", seq.GroundTruth)
+	}
+	defer f.Close()
+	gt, err := eval.ReadGroundTruth(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ground truth %v", seq.GroundTruth)
+	}
+
+	d := &tuneData{name: seq.Name, frames: seq.Frames, detections: make(map[int][]objdet.Detection), gt: gt}
+	if d.frames <= 0 {
+		d.frames = slices.Max(append(gt.Frames(), slices.Collect(maps.Keys(pushed))...))
+	}
+	d.bounds = image.Rect(0, 0, seq.Width, seq.Height)
+	for n, dets := range pushed {
+		for _, p := range dets {
+			box := image.Rect(p.XMin, p.YMin, p.XMax, p.YMax)
+			if d.bounds.Empty() {
+				d.detections[n] = append(d.detections[n], objdet.NewDetectionWithoutImgBounds(box, p.Confidence, p.ClassName))
+			} else {
+				d.detections[n] = append(d.detections[n], objdet.NewDetection(d.bounds, box, p.Confidence, p.ClassName))
+			}
+		}
+	}
+	return d, nil
+}
+
+// tuneConfig returns the base attributes with the tuned values, and the config they decode to.
+// Attributes the config does not have are rejected, to catch typos in the names.
+func tuneConfig(base, values map[string]interface{}) (map[string]interface{}, Config, error) {
+	attrs := maps.Clone(base)
+	if attrs == nil {
+		attrs = make(map[string]interface{}, len(values))
+	}
+	maps.Copy(attrs, values)
+	raw, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, Config{}, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, Config{}, errors.Wrap(err, "invalid tuned attributes")
+	}
+	return attrs, cfg, nil
+}
+
+// runTuneTrial scores the tracker on every sequence with the values of the tuned attributes.
+func runTuneTrial(ctx context.Context, opts TuneOptions, data []*tuneData, values map[string]interface{}, logger logging.Logger) TuneTrial {
+	trial := TuneTrial{Values: values, Sequences: make(map[string]eval.Metrics, len(data))}
+	_, cfg, err := tuneConfig(opts.Base, values)
+	if err != nil {
+		trial.Err = err
+		return trial
+	}
+	// the sequences are scored together by shifting their frames and IDs apart
+	allGT, allTracks := make(eval.Sequence), make(eval.Sequence)
+	offset := 0
+	for i, d := range data {
+		tracks, err := d.track(ctx, cfg, logger)
+		if err != nil {
+			trial.Err = errors.Wrapf(err, "sequence %v", d.name)
+			return trial
+		}
+		trial.Sequences[d.name] = eval.Evaluate(d.gt, tracks)
+		shiftSequence(allGT, d.gt, offset, i*tuneIDStride)
+		shiftSequence(allTracks, tracks, offset, i*tuneIDStride)
+		offset += d.frames
+	}
+	trial.Metrics = eval.Evaluate(allGT, allTracks)
+	trial.Score, _ = tuneScore(opts.Metric, trial.Metrics)
+	return trial
+}
+
+// shiftSequence adds the objects of seq to out, with their frames and IDs shifted.
+func shiftSequence(out, seq eval.Sequence, frames, ids int) {
+	for n, objs := range seq {
+		for _, o := range objs {
+			o.ID += ids
+			out[n+frames] = append(out[n+frames], o)
+		}
+	}
+}
+
+// track runs a new tracker on every frame of the sequence, and returns the stable tracks.
+func (d *tuneData) track(ctx context.Context, cfg Config, logger logging.Logger) (eval.Sequence, error) {
+	t, err := newBatchTracker(ctx, "tune-tracker", cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close(ctx)
+	if d.bounds.Empty() && t.regions.enabled() {
+		return nil, errors.New("sequences need a frame size when regions are configured")
+	}
+	out := make(eval.Sequence)
+	ids := make(map[string]int)
+	for n := 1; n <= d.frames; n++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		t.stepMutex.Lock()
+		t.stepDetections(slices.Clone(d.detections[n]), d.bounds, time.Now())
+		t.stepMutex.Unlock()
+		for _, tr := range t.stableTracks() {
+			id, ok := ids[getTrackingLabel(tr)]
+			if !ok {
+				id = len(ids) + 1
+				ids[getTrackingLabel(tr)] = id
+			}
+			box := tr.Det.BoundingBox()
+			out[n] = append(out[n], eval.Object{
+				ID: id, Left: float64(box.Min.X), Top: float64(box.Min.Y),
+				Width: float64(box.Dx()), Height: float64(box.Dy()),
+			})
+		}
+	}
+	return out, nil
+}
+
+// tuneScore returns the metric of a tuning.
+func tuneScore(metric string, m eval.Metrics) (float64, error) {
+	switch metric {
+	case TuneMetricHOTA:
+		return m.HOTA, nil
+	case TuneMetricMOTA:
+		return m.MOTA, nil
+	case TuneMetricIDF1:
+		return m.IDF1, nil
+	default:
+		return 0, errors.Errorf("tuned metric must be %v, %v or %v, not %q", TuneMetricHOTA, TuneMetricMOTA, TuneMetricIDF1, metric)
+	}
+}
@@ -0,0 +1,111 @@
+package object_tracker
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+)
+
+func TestTuneSpace(t *testing.T) {
+	var space TuneSpace
+	err := json.Unmarshal([]byte(`{
+		"min_confidence": [0.3, 0.5],
+		"buffer_size": [10, 20, 30],
+		"min_track_persistence": {"min": 1, "max": 4},
+		"direction_consistency_weight": {"min": 0, "max": 1.0}
+	}`), &space)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, space["buffer_size"].Values, test.ShouldResemble, []interface{}{int64(10), int64(20), int64(30)})
+	test.That(t, space["min_track_persistence"].Integer, test.ShouldBeTrue)
+	test.That(t, space["direction_consistency_weight"].Integer, test.ShouldBeFalse)
+
+	// a range makes a random search, which is reproducible
+	candidates := space.candidates(25, 3)
+	test.That(t, len(candidates), test.ShouldEqual, 25)
+	test.That(t, space.candidates(25, 3), test.ShouldResemble, candidates)
+	for _, c := range candidates {
+		test.That(t, c["min_track_persistence"], test.ShouldBeBetweenOrEqual, int64(1), int64(4))
+		test.That(t, c["direction_consistency_weight"], test.ShouldBeBetweenOrEqual, 0.0, 1.0)
+	}
+
+	// lists only make a grid of every combination
+	delete(space, "min_track_persistence")
+	delete(space, "direction_consistency_weight")
+	candidates = space.candidates(25, 3)
+	test.That(t, len(candidates), test.ShouldEqual, 6)
+	test.That(t, candidates[0], test.ShouldResemble, map[string]interface{}{"buffer_size": int64(10), "min_confidence": 0.3})
+	test.That(t, candidates[1], test.ShouldResemble, map[string]interface{}{"buffer_size": int64(10), "min_confidence": 0.5})
+
+	for _, bad := range []string{`[]`, `{"min": 2}`, `{"min": 2, "max": 1}`, `0.5`} {
+		var p TuneParameter
+		test.That(t, json.Unmarshal([]byte(bad), &p), test.ShouldNotBeNil)
+	}
+}
+
+func TestTune(t *testing.T) {
+	seqs, err := FindTuneSequences(filepath.Join("..", "test_files", "mot"))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(seqs), test.ShouldEqual, 3)
+
+	var space TuneSpace
+	test.That(t, json.Unmarshal([]byte(`{"min_confidence": [0.8, 0.5], "min_track_persistence": [3, 1]}`), &space), test.ShouldBeNil)
+	opts := TuneOptions{
+		Sequences: seqs,
+		Base:      map[string]interface{}{"camera_name": "camera", "detector_name": "detector"},
+		Space:     space,
+	}
+	result, err := Tune(context.Background(), opts, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, len(result.Trials), test.ShouldEqual, 4)
+	// the detections of the sequences score from 0.6, so a high threshold misses people
+	best := result.Trials[0]
+	test.That(t, best.Values, test.ShouldResemble, map[string]interface{}{"min_confidence": 0.5, "min_track_persistence": int64(1)})
+	test.That(t, len(best.Sequences), test.ShouldEqual, 3)
+	test.That(t, best.Score, test.ShouldEqual, best.Metrics.HOTA)
+	for k := 1; k < len(result.Trials); k++ {
+		test.That(t, result.Trials[k].Score, test.ShouldBeLessThanOrEqualTo, result.Trials[k-1].Score)
+	}
+	test.That(t, result.Attributes, test.ShouldResemble, map[string]interface{}{
+		"camera_name": "camera", "detector_name": "detector", "min_confidence": 0.5, "min_track_persistence": int64(1),
+	})
+
+	// the best attributes are a valid config of the service
+	raw, err := json.Marshal(result.Attributes)
+	test.That(t, err, test.ShouldBeNil)
+	var cfg Config
+	test.That(t, json.Unmarshal(raw, &cfg), test.ShouldBeNil)
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+
+	// tuning again gives the same scores
+	again, err := Tune(context.Background(), opts, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, again.Trials[0].Metrics, test.ShouldResemble, best.Metrics)
+
+	// attributes the config does not have are rejected, and so are invalid values
+	opts.Space = TuneSpace{"min_confidance": {Values: []interface{}{0.5}}}
+	_, err = Tune(context.Background(), opts, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "min_confidance")
+	opts.Space = TuneSpace{"coast_frames": {Values: []interface{}{-1, 2}}}
+	result, err = Tune(context.Background(), opts, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, result.Trials[0].Values["coast_frames"], test.ShouldEqual, 2)
+	test.That(t, result.Trials[1].Err, test.ShouldNotBeNil)
+
+	// regions need the frame size, which the sequences do not have
+	regions := opts
+	regions.Base = map[string]interface{}{"include_regions": [][][]float64{{{0, 0}, {1, 0}, {1, 1}}}}
+	regions.Space = TuneSpace{"coast_frames": {Values: []interface{}{2}}}
+	_, err = Tune(context.Background(), regions, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "frame size")
+
+	opts.Metric = "accuracy"
+	_, err = Tune(context.Background(), opts, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldNotBeNil)
+}
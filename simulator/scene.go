@@ -0,0 +1,197 @@
+// Package simulator generates scripted scenes for deterministic integration tests of trackers.
+//
+// A Scene lists objects with a trajectory, the intervals they are occluded on, and the noise of
+// the detector. A Simulation plays the scene one frame at a time to an inject.Camera and an
+// inject.VisionService, records what the tracker returns, and matches it with the ground truth so
+// tests can assert that each object keeps its ID:
+//
+//	sim := simulator.New(simulator.Scene{
+//		Width: 640, Height: 480, Frames: 60, Seed: 1,
+//		Objects: []simulator.Object{
+//			{ID: 1, Class: "person", Trajectory: simulator.Linear(image.Rect(0, 200, 40, 290), 8, 0),
+//				Occluded: []simulator.Interval{{From: 20, To: 25}}},
+//		},
+//		Noise: simulator.Noise{Jitter: 2, DropRate: 0.05},
+//	})
+//	deps := resource.Dependencies{
+//		camera.Named("camera"):   sim.Camera(),
+//		vision.Named("detector"): sim.Detector(),
+//	}
+//	...
+//	for range sim.Scene().Frames {
+//		dets, err := tracker.DetectionsFromCamera(ctx, "camera", nil)
+//		...
+//		sim.Record(dets)
+//	}
+//	labels := sim.Labels(1) // one label if the person kept its ID
+package simulator
+
+import (
+	"image"
+	"math/rand"
+	"slices"
+
+	objdet "go.viam.com/rdk/vision/objectdetection"
+)
+
+// DefaultScore is the score of the detections when Noise sets no range of scores.
+const DefaultScore = 0.9
+
+// Trajectory returns the box of an object on a frame, numbered from 1.
+type Trajectory func(frame int) image.Rectangle
+
+// Linear returns the trajectory of an object that is at start on the first frame, and moves by vx
+// and vy pixels each frame.
+func Linear(start image.Rectangle, vx, vy float64) Trajectory {
+	return func(frame int) image.Rectangle {
+		steps := float64(frame - 1)
+		return start.Add(image.Pt(int(vx*steps), int(vy*steps)))
+	}
+}
+
+// Stationary returns the trajectory of an object that does not move.
+func Stationary(box image.Rectangle) Trajectory {
+	return func(int) image.Rectangle { return box }
+}
+
+// Interval is a range of frames, From and To included.
+type Interval struct {
+	From, To int
+}
+
+// Contains returns true if the frame is within the interval.
+func (i Interval) Contains(frame int) bool {
+	return frame >= i.From && frame <= i.To
+}
+
+// Object is an object of the scene.
+type Object struct {
+	// ID identifies the object in the ground truth
+	ID    int
+	Class string
+	// Trajectory gives the box of the object on each frame. The parts outside of the frame are cut.
+	Trajectory Trajectory
+	// Present are the frames the object is in the scene. All frames if empty, so objects that leave
+	// and come back have several intervals.
+	Present []Interval
+	// Occluded are the frames the object is hidden on. It is neither drawn nor detected, but is
+	// still in the ground truth.
+	Occluded []Interval
+}
+
+// present returns true if the object is in the scene on the frame.
+func (o Object) present(frame int) bool {
+	return len(o.Present) == 0 || slices.ContainsFunc(o.Present, func(i Interval) bool { return i.Contains(frame) })
+}
+
+// occluded returns true if the object is hidden on the frame.
+func (o Object) occluded(frame int) bool {
+	return slices.ContainsFunc(o.Occluded, func(i Interval) bool { return i.Contains(frame) })
+}
+
+// Noise describes the errors of the simulated detector.
+type Noise struct {
+	// Jitter is the most each side of a detected box is moved from the true box, in pixels
+	Jitter int
+	// DropRate is the probability that a visible object is not detected on a frame
+	DropRate float64
+	// FalsePositiveRate is the probability that a frame has a detection of no object, of
+	// FalsePositiveClass, or of the class of the first object if it is empty
+	FalsePositiveRate  float64
+	FalsePositiveClass string
+	// MinScore and MaxScore bound the scores of the detections, which are DefaultScore if both
+	// are 0
+	MinScore, MaxScore float64
+}
+
+// Scene is a scripted scene.
+type Scene struct {
+	Width, Height int
+	// Frames is the number of frames of the scene, numbered from 1
+	Frames  int
+	Objects []Object
+	Noise   Noise
+	// Seed makes the noise of the scene reproducible
+	Seed int64
+}
+
+// Truth is the true box of an object on a frame.
+type Truth struct {
+	ID       int
+	Class    string
+	Box      image.Rectangle
+	Occluded bool
+}
+
+// Frame is a simulated frame.
+type Frame struct {
+	Number int
+	// Truth holds the objects in the frame, occluded or not
+	Truth []Truth
+	// Detections are what the simulated detector finds on the frame
+	Detections []objdet.Detection
+}
+
+// Render returns every frame of the scene. The same scene always renders the same frames.
+func (s Scene) Render() []Frame {
+	rng := rand.New(rand.NewSource(s.Seed))
+	bounds := image.Rect(0, 0, s.Width, s.Height)
+	frames := make([]Frame, s.Frames)
+	for k := range frames {
+		f := &frames[k]
+		f.Number = k + 1
+		for _, o := range s.Objects {
+			if !o.present(f.Number) {
+				continue
+			}
+			box := o.Trajectory(f.Number).Intersect(bounds)
+			if box.Empty() {
+				continue
+			}
+			occluded := o.occluded(f.Number)
+			f.Truth = append(f.Truth, Truth{ID: o.ID, Class: o.Class, Box: box, Occluded: occluded})
+			// the random numbers are drawn whether the object is detected or not, so that
+			// changing an occlusion does not change the noise of the other objects
+			dropped := rng.Float64() < s.Noise.DropRate
+			detected := s.jitter(rng, box).Intersect(bounds)
+			score := s.score(rng)
+			if occluded || dropped || detected.Empty() {
+				continue
+			}
+			f.Detections = append(f.Detections, objdet.NewDetection(bounds, detected, score, o.Class))
+		}
+		if rng.Float64() < s.Noise.FalsePositiveRate {
+			f.Detections = append(f.Detections, s.falsePositive(rng, bounds))
+		}
+	}
+	return frames
+}
+
+// jitter moves each side of the box by up to Jitter pixels.
+func (s Scene) jitter(rng *rand.Rand, box image.Rectangle) image.Rectangle {
+	j := func() int {
+		if s.Noise.Jitter <= 0 {
+			return 0
+		}
+		return rng.Intn(2*s.Noise.Jitter+1) - s.Noise.Jitter
+	}
+	return image.Rect(box.Min.X+j(), box.Min.Y+j(), box.Max.X+j(), box.Max.Y+j())
+}
+
+func (s Scene) score(rng *rand.Rand) float64 {
+	if s.Noise.MinScore == 0 && s.Noise.MaxScore == 0 {
+		return DefaultScore
+	}
+	return s.Noise.MinScore + rng.Float64()*(s.Noise.MaxScore-s.Noise.MinScore)
+}
+
+// falsePositive returns a detection of a random box between 20 and 60 pixels wide and high.
+func (s Scene) falsePositive(rng *rand.Rand, bounds image.Rectangle) objdet.Detection {
+	class := s.Noise.FalsePositiveClass
+	if class == "" && len(s.Objects) > 0 {
+		class = s.Objects[0].Class
+	}
+	w, h := 20+rng.Intn(41), 20+rng.Intn(41)
+	x, y := rng.Intn(max(1, s.Width-w)), rng.Intn(max(1, s.Height-h))
+	return objdet.NewDetection(bounds, image.Rect(x, y, x+w, y+h).Intersect(bounds), s.score(rng), class)
+}
@@ -0,0 +1,199 @@
+// This file contains the playback of a scene to fake resources, and the recording of what a
+// tracker returns.
+
+package simulator
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.viam.com/rdk/components/camera"
+	"go.viam.com/rdk/data"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/testutils/inject"
+	"go.viam.com/rdk/utils"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+
+	"github.com/viam-modules/object-tracking/eval"
+	"github.com/viam-modules/object-tracking/tracking"
+)
+
+// ErrEndOfScene is returned by the camera once every frame was served.
+var ErrEndOfScene = errors.New("end of the scene")
+
+// Simulation plays a scene one frame at a time. It is safe for concurrent use.
+type Simulation struct {
+	scene  Scene
+	frames []Frame
+
+	mutex sync.Mutex
+	// current is the index of the frame last served, -1 before the first one
+	current int
+	// ids gives the recorded labels an ID in tracks, in the order they first appear
+	ids    map[string]int
+	tracks eval.Sequence
+	// labels holds the labels each object was matched with, in the order they first appear
+	labels map[int][]string
+}
+
+// New returns a simulation of the scene, before its first frame.
+func New(scene Scene) *Simulation {
+	return &Simulation{
+		scene:   scene,
+		frames:  scene.Render(),
+		current: -1,
+		ids:     make(map[string]int),
+		tracks:  make(eval.Sequence),
+		labels:  make(map[int][]string),
+	}
+}
+
+// Scene returns the simulated scene.
+func (s *Simulation) Scene() Scene {
+	return s.scene
+}
+
+// Frames returns every frame of the scene.
+func (s *Simulation) Frames() []Frame {
+	return s.frames
+}
+
+// Next advances to the next frame and returns it with its image, or false after the last frame.
+func (s *Simulation) Next() (Frame, image.Image, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.current+1 >= len(s.frames) {
+		return Frame{}, nil, false
+	}
+	s.current++
+	f := s.frames[s.current]
+	return f, s.Image(f), true
+}
+
+// Current returns the frame last returned by Next, or an empty frame before the first one.
+func (s *Simulation) Current() Frame {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.current < 0 {
+		return Frame{}
+	}
+	return s.frames[s.current]
+}
+
+// Image draws the objects of the frame that are not occluded as boxes of a color of their own, on
+// a gray background.
+func (s *Simulation) Image(f Frame) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, s.scene.Width, s.scene.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 128}), image.Point{}, draw.Src)
+	for _, t := range f.Truth {
+		if t.Occluded {
+			continue
+		}
+		c := color.RGBA{R: uint8(37 * t.ID), G: uint8(91 * t.ID), B: uint8(173 * t.ID), A: 255}
+		draw.Draw(img, t.Box, image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	return img
+}
+
+// Camera returns a camera that serves the next frame of the scene each time it is asked for an
+// image, and ErrEndOfScene after the last one.
+func (s *Simulation) Camera() *inject.Camera {
+	return &inject.Camera{
+		ImagesFunc: func(ctx context.Context, filterSourceNames []string, extra map[string]interface{}) ([]camera.NamedImage, resource.ResponseMetadata, error) {
+			_, img, ok := s.Next()
+			if !ok {
+				return nil, resource.ResponseMetadata{}, ErrEndOfScene
+			}
+			namedImage, err := camera.NamedImageFromImage(img, "color", utils.MimeTypeRawRGBA, data.Annotations{})
+			if err != nil {
+				return nil, resource.ResponseMetadata{}, err
+			}
+			return []camera.NamedImage{namedImage}, resource.ResponseMetadata{}, nil
+		},
+	}
+}
+
+// Detector returns a detector that finds the detections of the frame last served, whatever the
+// image it is given. It is meant to run on whole frames, right after they are served.
+func (s *Simulation) Detector() *inject.VisionService {
+	return &inject.VisionService{
+		DetectionsFunc: func(ctx context.Context, img image.Image, extra map[string]interface{}) ([]objdet.Detection, error) {
+			return s.Current().Detections, nil
+		},
+	}
+}
+
+// Record stores the detections a tracker returned for the frame last served, and matches them
+// with the objects of the frame.
+func (s *Simulation) Record(dets []objdet.Detection) {
+	f := s.Current()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, d := range dets {
+		id, ok := s.ids[d.Label()]
+		if !ok {
+			id = len(s.ids) + 1
+			s.ids[d.Label()] = id
+		}
+		s.tracks[f.Number] = append(s.tracks[f.Number], object(id, *d.BoundingBox()))
+	}
+	if len(f.Truth) == 0 || len(dets) == 0 {
+		return
+	}
+	cost := make([][]float64, len(f.Truth))
+	for i, t := range f.Truth {
+		cost[i] = make([]float64, len(dets))
+		for j, d := range dets {
+			cost[i][j] = -tracking.IOU(t.Box, *d.BoundingBox())
+		}
+	}
+	for i, j := range tracking.SolveAssignment(cost) {
+		if j < 0 || -cost[i][j] < eval.MatchThreshold {
+			continue
+		}
+		id, label := f.Truth[i].ID, dets[j].Label()
+		if !slices.Contains(s.labels[id], label) {
+			s.labels[id] = append(s.labels[id], label)
+		}
+	}
+}
+
+// Labels returns the labels the object was matched with, in the order they were first recorded.
+// An object that kept its ID has a single label.
+func (s *Simulation) Labels(id int) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.labels[id]...)
+}
+
+// GroundTruth returns the objects of every frame, occluded or not.
+func (s *Simulation) GroundTruth() eval.Sequence {
+	out := make(eval.Sequence)
+	for _, f := range s.frames {
+		for _, t := range f.Truth {
+			out[f.Number] = append(out[f.Number], object(t.ID, t.Box))
+		}
+	}
+	return out
+}
+
+// Tracks returns the recorded detections, where each label has an ID of its own.
+func (s *Simulation) Tracks() eval.Sequence {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	out := make(eval.Sequence, len(s.tracks))
+	for f, objs := range s.tracks {
+		out[f] = append([]eval.Object(nil), objs...)
+	}
+	return out
+}
+
+// object returns the box as an object of a sequence.
+func object(id int, box image.Rectangle) eval.Object {
+	return eval.Object{ID: id, Left: float64(box.Min.X), Top: float64(box.Min.Y), Width: float64(box.Dx()), Height: float64(box.Dy())}
+}
@@ -0,0 +1,104 @@
+package simulator
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"go.viam.com/rdk/components/camera"
+	objdet "go.viam.com/rdk/vision/objectdetection"
+	"go.viam.com/test"
+)
+
+func TestRender(t *testing.T) {
+	scene := Scene{
+		Width: 200, Height: 100, Frames: 20, Seed: 7,
+		Objects: []Object{
+			{ID: 1, Class: "person", Trajectory: Linear(image.Rect(0, 10, 20, 50), 10, 0), Occluded: []Interval{{From: 5, To: 6}}},
+			{ID: 2, Class: "dog", Trajectory: Stationary(image.Rect(100, 60, 130, 90)), Present: []Interval{{From: 1, To: 3}, {From: 10, To: 12}}},
+		},
+		Noise: Noise{Jitter: 2, DropRate: 0.2, FalsePositiveRate: 0.3, MinScore: 0.5, MaxScore: 0.8},
+	}
+	frames := scene.Render()
+	test.That(t, len(frames), test.ShouldEqual, 20)
+	test.That(t, scene.Render(), test.ShouldResemble, frames)
+
+	var dogs, falsePositives int
+	for _, f := range frames {
+		for _, d := range f.Detections {
+			test.That(t, d.Score(), test.ShouldBeBetweenOrEqual, 0.5, 0.8)
+			if d.Label() == "dog" {
+				dogs++
+			}
+		}
+		var visible int
+		for _, tr := range f.Truth {
+			if !tr.Occluded {
+				visible++
+			}
+			if tr.ID == 1 {
+				test.That(t, tr.Box.Min.X, test.ShouldEqual, 10*(f.Number-1))
+				test.That(t, tr.Occluded, test.ShouldEqual, f.Number == 5 || f.Number == 6)
+			}
+		}
+		falsePositives += max(0, len(f.Detections)-visible)
+		test.That(t, len(f.Detections), test.ShouldBeLessThanOrEqualTo, visible+1)
+	}
+	test.That(t, dogs, test.ShouldBeBetweenOrEqual, 1, 6)
+	test.That(t, falsePositives, test.ShouldBeGreaterThan, 0)
+	// the person is cut on the way out of the frame, after the dog left
+	test.That(t, len(frames[19].Truth), test.ShouldEqual, 1)
+	test.That(t, frames[19].Truth[0].Box, test.ShouldResemble, image.Rect(190, 10, 200, 50))
+
+	// changing the occlusions does not change the noise of the other objects
+	scene.Objects[0].Occluded = nil
+	for k, f := range scene.Render() {
+		var before, after []objdet.Detection
+		for _, d := range frames[k].Detections {
+			if d.Label() == "dog" {
+				before = append(before, d)
+			}
+		}
+		for _, d := range f.Detections {
+			if d.Label() == "dog" {
+				after = append(after, d)
+			}
+		}
+		test.That(t, after, test.ShouldResemble, before)
+	}
+}
+
+func TestSimulation(t *testing.T) {
+	ctx := context.Background()
+	sim := New(Scene{
+		Width: 100, Height: 100, Frames: 3,
+		Objects: []Object{{ID: 4, Class: "cat", Trajectory: Linear(image.Rect(10, 10, 30, 30), 5, 5)}},
+	})
+	cam, detector := sim.Camera(), sim.Detector()
+	test.That(t, sim.Current().Number, test.ShouldEqual, 0)
+
+	for n := 1; n <= 3; n++ {
+		img, err := camera.DecodeImageFromCamera(ctx, cam, nil, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, img.Bounds(), test.ShouldResemble, image.Rect(0, 0, 100, 100))
+		test.That(t, sim.Current().Number, test.ShouldEqual, n)
+		dets, err := detector.Detections(ctx, img, nil)
+		test.That(t, err, test.ShouldBeNil)
+		test.That(t, len(dets), test.ShouldEqual, 1)
+		test.That(t, dets[0].Score(), test.ShouldEqual, DefaultScore)
+		// the tracker lost the cat on the second frame and gave it a new label
+		label := "cat_0"
+		if n > 1 {
+			label = "cat_1"
+		}
+		sim.Record([]objdet.Detection{objdet.NewDetection(img.Bounds(), *dets[0].BoundingBox(), 0.9, label)})
+	}
+	_, err := camera.DecodeImageFromCamera(ctx, cam, nil, nil)
+	test.That(t, err, test.ShouldNotBeNil)
+
+	test.That(t, sim.Labels(4), test.ShouldResemble, []string{"cat_0", "cat_1"})
+	test.That(t, len(sim.GroundTruth().Frames()), test.ShouldEqual, 3)
+	tracks := sim.Tracks()
+	test.That(t, tracks[1][0].ID, test.ShouldEqual, 1)
+	test.That(t, tracks[3][0].ID, test.ShouldEqual, 2)
+}
@@ -0,0 +1,221 @@
+// This file contains the association of tracks with new detections. Only pairs of boxes that overlap
+// can be matched by the default associator, so the pairs are found with a spatial grid, and the
+// resulting sparse graph is split into connected components that are solved independently.
+
+package tracking
+
+import (
+	"image"
+)
+
+// minGridCellSize is the smallest side, in pixels, of a cell of the gating grid.
+const minGridCellSize = 16
+
+// Associator matches the boxes predicted for the tracks with the detected boxes.
+type Associator interface {
+	// Associate returns, for each predicted box, the index of the detected box it matches, or -1
+	// if it matches none. Pairs with a cost that is not negative are never matched, and a detected
+	// box is matched at most once.
+	Associate(preds, boxes []image.Rectangle, cost func(i, j int) float64) []int
+}
+
+// GatedAssociator only evaluates the cost of the pairs of boxes that overlap, found with a spatial
+// grid, and solves each connected group of overlapping boxes separately. It is the default, and
+// scales to crowded scenes as long as the cost of boxes that do not overlap is never negative.
+type GatedAssociator struct{}
+
+// Associate implements Associator.
+func (GatedAssociator) Associate(preds, boxes []image.Rectangle, cost func(i, j int) float64) []int {
+	return associateBoxes(preds, boxes, cost)
+}
+
+// DenseAssociator evaluates the cost of every pair of boxes and solves a single assignment. It is
+// needed by cost functions that match boxes that do not overlap, such as distance based ones.
+type DenseAssociator struct{}
+
+// Associate implements Associator.
+func (DenseAssociator) Associate(preds, boxes []image.Rectangle, cost func(i, j int) float64) []int {
+	matches := fillInts(len(preds), -1)
+	if len(preds) == 0 || len(boxes) == 0 {
+		return matches
+	}
+	mtx := make([][]float64, len(preds))
+	for i := range preds {
+		mtx[i] = make([]float64, len(boxes))
+		for j := range boxes {
+			// pairs that cannot match cost 0, which is the same as not being matched
+			mtx[i][j] = min(cost(i, j), 0)
+		}
+	}
+	for i, j := range solveLAP(mtx) {
+		if j != -1 && mtx[i][j] < 0 {
+			matches[i] = j
+		}
+	}
+	return matches
+}
+
+// edge is a possible match between an old box and a new box, with its cost.
+type edge struct {
+	old, new int
+	cost     float64
+}
+
+// associateBoxes matches predicted boxes with detected boxes. cost is only evaluated for pairs of
+// boxes that overlap, and pairs with a cost that is not negative are never matched.
+func associateBoxes(preds, boxes []image.Rectangle, cost func(i, j int) float64) []int {
+	matches := fillInts(len(preds), -1)
+	edges := gateBoxes(preds, boxes, cost)
+	for _, component := range connectedComponents(len(preds), len(boxes), edges) {
+		solveComponent(component, matches)
+	}
+	return matches
+}
+
+// gateBoxes returns the edges between every predicted box and the detected boxes it overlaps,
+// using a uniform grid sized after the detected boxes to avoid comparing every pair.
+func gateBoxes(preds, boxes []image.Rectangle, cost func(i, j int) float64) []edge {
+	if len(preds) == 0 || len(boxes) == 0 {
+		return nil
+	}
+	cellSize := 0
+	for _, b := range boxes {
+		cellSize += max(b.Dx(), b.Dy())
+	}
+	cellSize = max(cellSize/len(boxes), minGridCellSize)
+
+	grid := make(map[image.Point][]int)
+	for j, b := range boxes {
+		minCell, maxCell := cellRange(b, cellSize)
+		for cx := minCell.X; cx <= maxCell.X; cx++ {
+			for cy := minCell.Y; cy <= maxCell.Y; cy++ {
+				cell := image.Pt(cx, cy)
+				grid[cell] = append(grid[cell], j)
+			}
+		}
+	}
+
+	var edges []edge
+	// seen[j] == i+1 when box j was already compared with prediction i
+	seen := make([]int, len(boxes))
+	for i, p := range preds {
+		minCell, maxCell := cellRange(p, cellSize)
+		for cx := minCell.X; cx <= maxCell.X; cx++ {
+			for cy := minCell.Y; cy <= maxCell.Y; cy++ {
+				for _, j := range grid[image.Pt(cx, cy)] {
+					if seen[j] == i+1 {
+						continue
+					}
+					seen[j] = i + 1
+					if !p.Overlaps(boxes[j]) {
+						continue
+					}
+					if c := cost(i, j); c < 0 {
+						edges = append(edges, edge{old: i, new: j, cost: c})
+					}
+				}
+			}
+		}
+	}
+	return edges
+}
+
+// cellRange returns the first and last grid cells covered by the rectangle.
+func cellRange(r image.Rectangle, cellSize int) (image.Point, image.Point) {
+	return image.Pt(floorDiv(r.Min.X, cellSize), floorDiv(r.Min.Y, cellSize)),
+		image.Pt(floorDiv(r.Max.X, cellSize), floorDiv(r.Max.Y, cellSize))
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// component is a connected set of old and new boxes, along with the edges between them.
+type component struct {
+	olds, news []int
+	edges      []edge
+}
+
+// connectedComponents splits the bipartite graph into connected components. Boxes without any
+// edge are left out, since they cannot be matched.
+func connectedComponents(nOld, nNew int, edges []edge) []*component {
+	// old boxes are nodes [0, nOld), new boxes are nodes [nOld, nOld+nNew)
+	parent := make([]int, nOld+nNew)
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(x int) int {
+		for parent[x] != x {
+			parent[x] = parent[parent[x]]
+			x = parent[x]
+		}
+		return x
+	}
+	for _, e := range edges {
+		a, b := find(e.old), find(nOld+e.new)
+		if a != b {
+			parent[a] = b
+		}
+	}
+
+	byRoot := make(map[int]*component)
+	var components []*component
+	get := func(node int) *component {
+		root := find(node)
+		c, ok := byRoot[root]
+		if !ok {
+			c = &component{}
+			byRoot[root] = c
+			components = append(components, c)
+		}
+		return c
+	}
+	for _, e := range edges {
+		c := get(e.old)
+		c.edges = append(c.edges, e)
+	}
+	for i := range nOld {
+		if c, ok := byRoot[find(i)]; ok {
+			c.olds = append(c.olds, i)
+		}
+	}
+	for j := range nNew {
+		if c, ok := byRoot[find(nOld+j)]; ok {
+			c.news = append(c.news, j)
+		}
+	}
+	return components
+}
+
+// solveComponent solves the assignment within one component and writes the result into matches.
+func solveComponent(c *component, matches []int) {
+	if len(c.edges) == 1 {
+		matches[c.edges[0].old] = c.edges[0].new
+		return
+	}
+	oldIdx := make(map[int]int, len(c.olds))
+	for k, i := range c.olds {
+		oldIdx[i] = k
+	}
+	newIdx := make(map[int]int, len(c.news))
+	for k, j := range c.news {
+		newIdx[j] = k
+	}
+	// pairs without an edge cost 0, which is the same as not being matched
+	cost := make([][]float64, len(c.olds))
+	for k := range cost {
+		cost[k] = make([]float64, len(c.news))
+	}
+	for _, e := range c.edges {
+		cost[oldIdx[e.old]][newIdx[e.new]] = e.cost
+	}
+	for k, col := range solveLAP(cost) {
+		if col != -1 && cost[k][col] < 0 {
+			matches[c.olds[k]] = c.news[col]
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package tracking
+
+import (
+	"fmt"
+	"image"
+	"math/rand"
+	"testing"
+
+	hg "github.com/charles-haynes/munkres"
+	"go.viam.com/test"
+)
+
+// crowdedScene returns nOld predicted boxes laid out on a grid over a 1920x1080 frame, and as many
+// detected boxes, each one jittered from a prediction.
+func crowdedScene(rng *rand.Rand, nOld int) ([]image.Rectangle, []image.Rectangle) {
+	cols := 40
+	preds := make([]image.Rectangle, nOld)
+	boxes := make([]image.Rectangle, nOld)
+	for i := range nOld {
+		x, y := (i%cols)*48, (i/cols)*90%1000
+		preds[i] = image.Rect(x, y, x+40, y+80)
+		dx, dy := rng.Intn(21)-10, rng.Intn(21)-10
+		boxes[i] = preds[i].Add(image.Pt(dx, dy))
+	}
+	rng.Shuffle(len(boxes), func(i, j int) { boxes[i], boxes[j] = boxes[j], boxes[i] })
+	return preds, boxes
+}
+
+func iouCost(preds, boxes []image.Rectangle) func(i, j int) float64 {
+	return func(i, j int) float64 {
+		return -IOU(preds[i], boxes[j])
+	}
+}
+
+func denseMatches(t testing.TB, preds, boxes []image.Rectangle) []int {
+	mtx := make([][]float64, len(preds))
+	cost := iouCost(preds, boxes)
+	for i := range preds {
+		mtx[i] = make([]float64, len(boxes))
+		for j := range boxes {
+			mtx[i][j] = cost(i, j)
+		}
+	}
+	HA, err := hg.NewHungarianAlgorithm(mtx)
+	test.That(t, err, test.ShouldBeNil)
+	return HA.Execute()
+}
+
+func totalCost(matches []int, cost func(i, j int) float64) float64 {
+	sum := 0.0
+	for i, j := range matches {
+		if j != -1 {
+			sum += cost(i, j)
+		}
+	}
+	return sum
+}
+
+func TestSolveLAP(t *testing.T) {
+	test.That(t, solveLAP(nil), test.ShouldBeEmpty)
+	test.That(t, solveLAP([][]float64{{}, {}}), test.ShouldResemble, []int{-1, -1})
+
+	// square
+	matches := solveLAP([][]float64{
+		{4, 1, 3},
+		{2, 0, 5},
+		{3, 2, 2},
+	})
+	test.That(t, matches, test.ShouldResemble, []int{1, 0, 2})
+
+	// more columns than rows
+	matches = solveLAP([][]float64{
+		{-0.1, -0.9, 0},
+		{-0.8, -0.7, 0},
+	})
+	test.That(t, matches, test.ShouldResemble, []int{1, 0})
+
+	// more rows than columns
+	matches = solveLAP([][]float64{
+		{-0.9},
+		{-0.5},
+		{-1},
+	})
+	test.That(t, matches, test.ShouldResemble, []int{-1, -1, 0})
+}
+
+func TestAssociateBoxesMatchesDenseSolver(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for range 20 {
+		nOld, nNew := 1+rng.Intn(30), 1+rng.Intn(30)
+		preds := make([]image.Rectangle, nOld)
+		boxes := make([]image.Rectangle, nNew)
+		for i := range preds {
+			x, y := rng.Intn(300), rng.Intn(300)
+			preds[i] = image.Rect(x, y, x+20+rng.Intn(40), y+20+rng.Intn(40))
+		}
+		for j := range boxes {
+			x, y := rng.Intn(300), rng.Intn(300)
+			boxes[j] = image.Rect(x, y, x+20+rng.Intn(40), y+20+rng.Intn(40))
+		}
+		cost := iouCost(preds, boxes)
+		sparse := associateBoxes(preds, boxes, cost)
+		dense := denseMatches(t, preds, boxes)
+
+		test.That(t, totalCost(sparse, cost), test.ShouldAlmostEqual, totalCost(dense, cost), 1e-9)
+		used := make(map[int]bool)
+		for i, j := range sparse {
+			if j == -1 {
+				continue
+			}
+			test.That(t, used[j], test.ShouldBeFalse)
+			used[j] = true
+			test.That(t, cost(i, j), test.ShouldBeLessThan, 0)
+		}
+	}
+}
+
+func TestAssociateBoxesCrowd(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	preds, boxes := crowdedScene(rng, 200)
+	matches := associateBoxes(preds, boxes, iouCost(preds, boxes))
+	for i, j := range matches {
+		test.That(t, j, test.ShouldNotEqual, -1)
+		// every detection was jittered by at most 10 pixels from its own prediction
+		offset := boxes[j].Min.Sub(preds[i].Min)
+		test.That(t, offset.X, test.ShouldBeBetweenOrEqual, -10, 10)
+		test.That(t, offset.Y, test.ShouldBeBetweenOrEqual, -10, 10)
+	}
+
+	// non overlapping boxes are never matched
+	matches = associateBoxes(
+		[]image.Rectangle{image.Rect(0, 0, 10, 10)},
+		[]image.Rectangle{image.Rect(100, 100, 110, 110)},
+		func(i, j int) float64 { return -1 },
+	)
+	test.That(t, matches, test.ShouldResemble, []int{-1})
+}
+
+func BenchmarkAssociation(b *testing.B) {
+	for _, n := range []int{10, 50, 100, 200, 400} {
+		rng := rand.New(rand.NewSource(3))
+		// every object is tracked, and as many tracks are waiting in the lost track store
+		preds, boxes := crowdedScene(rng, 2*n)
+		boxes = boxes[:n]
+		b.Run(fmt.Sprintf("sparse/%d", n), func(b *testing.B) {
+			cost := iouCost(preds, boxes)
+			for b.Loop() {
+				associateBoxes(preds, boxes, cost)
+			}
+		})
+		if n > 200 {
+			// the dense solver takes seconds per iteration past this point
+			continue
+		}
+		b.Run(fmt.Sprintf("dense/%d", n), func(b *testing.B) {
+			for b.Loop() {
+				denseMatches(b, preds, boxes)
+			}
+		})
+	}
+}
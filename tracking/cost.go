@@ -0,0 +1,38 @@
+// This file contains the cost functions, which score how well a detection continues a track.
+
+package tracking
+
+import (
+	"image"
+)
+
+// CostFunction scores how well a detection continues a track. Lower is better, and pairs with a
+// cost that is not negative are never matched.
+type CostFunction interface {
+	Cost(tr *Track, predicted image.Rectangle, det Detection) float64
+}
+
+// IOU returns the intersection over union of 2 rectangles.
+func IOU(r1, r2 image.Rectangle) float64 {
+	intersection := r1.Intersect(r2)
+	if intersection.Empty() {
+		return 0
+	}
+	union := r1.Union(r2)
+	return float64(intersection.Dx()*intersection.Dy()) / float64(union.Dx()*union.Dy())
+}
+
+// IOUCost is the opposite of the intersection over union of the predicted box of the track and the
+// detected box. It is the default.
+type IOUCost struct {
+	// SameClass only matches detections of the class of the track
+	SameClass bool
+}
+
+// Cost implements CostFunction.
+func (c IOUCost) Cost(tr *Track, predicted image.Rectangle, det Detection) float64 {
+	if c.SameClass && det.Class != tr.Class {
+		return 0
+	}
+	return -IOU(predicted, det.Box)
+}
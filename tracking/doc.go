@@ -0,0 +1,28 @@
+// Package tracking is a library to track objects across frames, independent of any Viam resource.
+//
+// A Tracker is given the detections of each frame with Update, and returns the tracks detected on
+// that frame with a stable ID:
+//
+//	tracker, err := tracking.New(tracking.DefaultConfig())
+//	...
+//	for frame := range frames {
+//		for _, tr := range tracker.Update(frame.Detections, frame.Time) {
+//			if tr.Stable {
+//				fmt.Println(tr.ID, tr.Box)
+//			}
+//		}
+//	}
+//
+// How tracks are predicted, scored against detections and matched can be replaced with the
+// MotionModel, CostFunction and Associator interfaces, through WithMotionModel, WithCostFunction
+// and WithAssociator. The object-tracker vision service is built on a Tracker: it moves the tracks
+// with Warp when the camera pans, and with Propagate on the frames it does not run the detector on.
+//
+// # Compatibility
+//
+// This package follows semantic versioning along with the module. Within a major version, the
+// exported identifiers are not removed or changed in an incompatible way, the interfaces do not
+// gain methods, and new fields of Config default to the current behavior when left at their zero
+// value. The IDs given to tracks and which detection a track is matched with may change between
+// minor versions when the defaults are improved.
+package tracking
@@ -0,0 +1,130 @@
+// This file contains a solver for the rectangular linear assignment problem.
+
+package tracking
+
+import (
+	"math"
+)
+
+// solveLAP solves the rectangular linear assignment problem with the shortest augmenting path
+// variant of the Jonker-Volgenant algorithm. cost has one row per worker and one column per job,
+// and every row must have the same length. It returns, for each row, the column assigned to it,
+// or -1 if there are more rows than columns and the row was left out.
+func solveLAP(cost [][]float64) []int {
+	nr := len(cost)
+	if nr == 0 {
+		return []int{}
+	}
+	nc := len(cost[0])
+	if nc == 0 {
+		return fillInts(nr, -1)
+	}
+	if nr > nc {
+		// assign every column to a row instead, and invert the result
+		transposed := make([][]float64, nc)
+		for j := range transposed {
+			transposed[j] = make([]float64, nr)
+			for i := range nr {
+				transposed[j][i] = cost[i][j]
+			}
+		}
+		colToRow := solveLAP(transposed)
+		rowToCol := fillInts(nr, -1)
+		for j, i := range colToRow {
+			rowToCol[i] = j
+		}
+		return rowToCol
+	}
+
+	u := make([]float64, nr)
+	v := make([]float64, nc)
+	shortest := make([]float64, nc)
+	path := fillInts(nc, -1)
+	col4row := fillInts(nr, -1)
+	row4col := fillInts(nc, -1)
+	visitedRows := make([]bool, nr)
+	visitedCols := make([]bool, nc)
+	remaining := make([]int, nc)
+
+	for curRow := range nr {
+		// Dijkstra-like search for the shortest augmenting path starting at curRow
+		for j := range nc {
+			remaining[j] = nc - j - 1
+			visitedCols[j] = false
+			shortest[j] = math.Inf(1)
+		}
+		for i := range nr {
+			visitedRows[i] = false
+		}
+		numRemaining := nc
+		minVal := 0.0
+		sink := -1
+		i := curRow
+		for sink == -1 {
+			index := -1
+			lowest := math.Inf(1)
+			visitedRows[i] = true
+			for it := range numRemaining {
+				j := remaining[it]
+				r := minVal + cost[i][j] - u[i] - v[j]
+				if r < shortest[j] {
+					path[j] = i
+					shortest[j] = r
+				}
+				if shortest[j] < lowest || (shortest[j] == lowest && row4col[j] == -1) {
+					lowest = shortest[j]
+					index = it
+				}
+			}
+			minVal = lowest
+			j := remaining[index]
+			if row4col[j] == -1 {
+				sink = j
+			} else {
+				i = row4col[j]
+			}
+			visitedCols[j] = true
+			numRemaining--
+			remaining[index] = remaining[numRemaining]
+		}
+
+		// update the dual variables
+		u[curRow] += minVal
+		for i := range nr {
+			if visitedRows[i] && i != curRow {
+				u[i] += minVal - shortest[col4row[i]]
+			}
+		}
+		for j := range nc {
+			if visitedCols[j] {
+				v[j] -= minVal - shortest[j]
+			}
+		}
+
+		// augment the previous solution along the path
+		for j := sink; ; {
+			i := path[j]
+			row4col[j] = i
+			col4row[i], j = j, col4row[i]
+			if i == curRow {
+				break
+			}
+		}
+	}
+	return col4row
+}
+
+// SolveAssignment returns, for each row of the cost matrix, the column assigned to it so that the
+// total cost is minimal, or -1 if there are more rows than columns and the row was left out. Every
+// row must have the same length.
+func SolveAssignment(cost [][]float64) []int {
+	return solveLAP(cost)
+}
+
+func fillInts(n, value int) []int {
+	out := make([]int, n)
+	for i := range out {
+		out[i] = value
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+// This file contains the motion models, which predict where a track will be on the next frame.
+
+package tracking
+
+import (
+	"image"
+	"math"
+	"time"
+)
+
+// MotionModel predicts where a track will be on a frame.
+type MotionModel interface {
+	// Predict returns the expected box of the track on the frame at the given time.
+	Predict(tr *Track, at time.Time) image.Rectangle
+}
+
+// StaticModel expects tracks to stay where they were last seen.
+type StaticModel struct{}
+
+// Predict implements MotionModel.
+func (StaticModel) Predict(tr *Track, at time.Time) image.Rectangle {
+	return tr.Box
+}
+
+// ConstantVelocityModel extrapolates the last two observations of a track. It is the default. The
+// velocity is per second when the frames have timestamps, and per frame otherwise.
+type ConstantVelocityModel struct{}
+
+// Predict implements MotionModel.
+func (ConstantVelocityModel) Predict(tr *Track, at time.Time) image.Rectangle {
+	n := len(tr.History)
+	if n < 2 {
+		return tr.Box
+	}
+	prev, last := tr.History[n-2], tr.History[n-1]
+	// the track moves by one step per frame, unless the frames have usable timestamps
+	steps := 1.0 + float64(tr.Misses)
+	if dt := last.Time.Sub(prev.Time); dt > 0 && !at.IsZero() {
+		steps = at.Sub(last.Time).Seconds() / dt.Seconds()
+	}
+	dx := float64(center(last.Box).X-center(prev.Box).X) * steps
+	dy := float64(center(last.Box).Y-center(prev.Box).Y) * steps
+	return last.Box.Add(image.Pt(int(math.Round(dx)), int(math.Round(dy))))
+}
+
+func center(r image.Rectangle) image.Point {
+	return image.Pt((r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2)
+}
@@ -0,0 +1,347 @@
+// This file contains the tracker, which links the detections of successive frames into tracks.
+
+package tracking
+
+import (
+	"image"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMinHits is the number of frames a track must be detected on to become stable.
+	DefaultMinHits = 3
+	// DefaultMaxMisses is the number of frames a stable track is kept after it was last detected.
+	DefaultMaxMisses = 30
+	// DefaultMaxHistory is the number of observations kept for each track.
+	DefaultMaxHistory = 30
+)
+
+// Detection is an object detected on a frame.
+type Detection struct {
+	Box   image.Rectangle
+	Class string
+	Score float64
+	// Truncated is true when the box is cut by the border of the image, and may not cover the whole
+	// object. A truncated box can extend a track detected on the previous frame, but is never
+	// matched with a track that was missed.
+	Truncated bool
+}
+
+// Observation is the box of a track on a frame.
+type Observation struct {
+	Box  image.Rectangle
+	Time time.Time
+	// Virtual is true when the track was not detected on the frame: the box was interpolated once
+	// the track was found again after it was missed, or given to Propagate
+	Virtual bool
+}
+
+// Track is an object followed across frames.
+type Track struct {
+	// ID is unique among the tracks of a Tracker, and of the form <class>_<N> where the track is
+	// the Nth of the class it was first detected as, unless WithIDFunc names the tracks
+	ID    string
+	Class string
+	// Box and Score are those of the latest detection of the track, or the box given to Propagate
+	Box   image.Rectangle
+	Score float64
+	// Stable is true once the track was detected on enough frames, and stays true until it is dropped
+	Stable bool
+	// Hits is the number of frames the track was detected on
+	Hits int
+	// Misses is the number of frames since the track was last detected
+	Misses int
+	// Detection is the index of the detection of the track among the detections given to the latest
+	// Update, or -1 if the track was not detected on the latest frame
+	Detection int
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// History holds the latest observations of the track, oldest first
+	History []Observation
+}
+
+// clone returns a copy of the track that does not share its history.
+func (tr *Track) clone() Track {
+	out := *tr
+	out.History = append([]Observation(nil), tr.History...)
+	return out
+}
+
+// Config holds the lifecycle settings of a Tracker.
+type Config struct {
+	// MinHits is the number of frames a track must be detected on to become stable
+	MinHits int
+	// MaxMisses is the number of frames a stable track is kept after it was last detected. Tracks
+	// that are not stable are dropped as soon as they are missed.
+	MaxMisses int
+	// MaxHistory is the number of observations kept for each track, at least 2
+	MaxHistory int
+}
+
+// DefaultConfig returns the settings the vision service uses by default.
+func DefaultConfig() Config {
+	return Config{MinHits: DefaultMinHits, MaxMisses: DefaultMaxMisses, MaxHistory: DefaultMaxHistory}
+}
+
+// Validate checks that the settings are usable.
+func (c Config) Validate() error {
+	if c.MinHits < 1 {
+		return errors.New("MinHits must be at least 1")
+	}
+	if c.MaxMisses < 0 {
+		return errors.New("MaxMisses cannot be less than 0")
+	}
+	if c.MaxHistory < 2 {
+		return errors.New("MaxHistory must be at least 2")
+	}
+	return nil
+}
+
+// Option customizes a Tracker.
+type Option func(*Tracker)
+
+// WithMotionModel replaces the default ConstantVelocityModel.
+func WithMotionModel(m MotionModel) Option {
+	return func(t *Tracker) { t.motion = m }
+}
+
+// WithCostFunction replaces the default IOUCost.
+func WithCostFunction(c CostFunction) Option {
+	return func(t *Tracker) { t.cost = c }
+}
+
+// WithAssociator replaces the default GatedAssociator.
+func WithAssociator(a Associator) Option {
+	return func(t *Tracker) { t.associator = a }
+}
+
+// WithIDFunc replaces how new tracks are named. f is given the class of the first detection of
+// the track, and must return an ID that no other track of the Tracker has.
+func WithIDFunc(f func(class string) string) Option {
+	return func(t *Tracker) { t.nextID = f }
+}
+
+// Tracker links the detections of successive frames into tracks. It is not safe for concurrent use.
+type Tracker struct {
+	config     Config
+	motion     MotionModel
+	cost       CostFunction
+	associator Associator
+	nextID     func(class string) string
+	// tracks holds the detected and the missed tracks, in the order they were first seen
+	tracks       []*Track
+	byID         map[string]*Track
+	classCounter map[string]int
+}
+
+// New returns a Tracker without any track.
+func New(config Config, opts ...Option) (*Tracker, error) {
+	t := &Tracker{
+		byID:         make(map[string]*Track),
+		classCounter: make(map[string]int),
+	}
+	if err := t.Reconfigure(config, opts...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reconfigure replaces the settings and the options of the tracker, and keeps its tracks. Options
+// that are not given go back to their default.
+func (t *Tracker) Reconfigure(config Config, opts ...Option) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	t.config = config
+	t.motion = ConstantVelocityModel{}
+	t.cost = IOUCost{}
+	t.associator = GatedAssociator{}
+	t.nextID = t.classID
+	for _, opt := range opts {
+		opt(t)
+	}
+	return nil
+}
+
+// Update advances the tracker by one frame with the detections of the frame captured at timestamp,
+// which can be zero if it is unknown. It returns the tracks detected on the frame, stable or not,
+// in the order they were first seen.
+func (t *Tracker) Update(detections []Detection, timestamp time.Time) []Track {
+	preds := make([]image.Rectangle, len(t.tracks))
+	for i, tr := range t.tracks {
+		preds[i] = t.motion.Predict(tr, timestamp)
+	}
+	boxes := make([]image.Rectangle, len(detections))
+	for j, d := range detections {
+		boxes[j] = d.Box
+	}
+	matches := t.associator.Associate(preds, boxes, func(i, j int) float64 {
+		if t.tracks[i].Misses > 0 && detections[j].Truncated {
+			return 0
+		}
+		return t.cost.Cost(t.tracks[i], preds[i], detections[j])
+	})
+
+	used := make([]bool, len(detections))
+	kept := make([]*Track, 0, len(t.tracks)+len(detections))
+	for i, tr := range t.tracks {
+		if j := matches[i]; j >= 0 && j < len(detections) && !used[j] {
+			used[j] = true
+			if tr.Misses > 0 {
+				t.backfill(tr, detections[j].Box, timestamp)
+			}
+			t.observe(tr, j, detections[j], timestamp)
+			kept = append(kept, tr)
+			continue
+		}
+		if t.miss(tr) {
+			kept = append(kept, tr)
+		}
+	}
+	for j, d := range detections {
+		if used[j] {
+			continue
+		}
+		tr := &Track{ID: t.nextID(d.Class), Class: d.Class, FirstSeen: timestamp}
+		t.observe(tr, j, d, timestamp)
+		t.byID[tr.ID] = tr
+		kept = append(kept, tr)
+	}
+	t.tracks = kept
+	return t.detected()
+}
+
+// Propagate advances the tracker by one frame on which the detector did not run. The tracks
+// detected on the previous frame are moved to the boxes given by ID, or stay where they are, and are
+// neither hit nor missed. The tracks that were already missed are missed once more. It returns the
+// tracks that were detected on the previous frame, like Update.
+func (t *Tracker) Propagate(boxes map[string]image.Rectangle, timestamp time.Time) []Track {
+	kept := t.tracks[:0]
+	for _, tr := range t.tracks {
+		if tr.Misses > 0 {
+			if t.miss(tr) {
+				kept = append(kept, tr)
+			}
+			continue
+		}
+		if box, ok := boxes[tr.ID]; ok {
+			tr.Box = box
+		}
+		tr.Detection = -1
+		t.appendHistory(tr, Observation{Box: tr.Box, Time: timestamp, Virtual: true})
+		kept = append(kept, tr)
+	}
+	clear(t.tracks[len(kept):])
+	t.tracks = kept
+	return t.detected()
+}
+
+// Warp moves the box and the history of every track with f, such as when the camera moved between
+// two frames, so that the tracks are predicted in the coordinates of the next frame.
+func (t *Tracker) Warp(f func(image.Rectangle) image.Rectangle) {
+	for _, tr := range t.tracks {
+		tr.Box = f(tr.Box)
+		for k := range tr.History {
+			tr.History[k].Box = f(tr.History[k].Box)
+		}
+	}
+}
+
+// Tracks returns every track, including the stable tracks that were missed on the latest frames.
+func (t *Tracker) Tracks() []Track {
+	out := make([]Track, 0, len(t.tracks))
+	for _, tr := range t.tracks {
+		out = append(out, tr.clone())
+	}
+	return out
+}
+
+// Track returns the track with the given ID, and false if the tracker has no such track.
+func (t *Tracker) Track(id string) (Track, bool) {
+	tr, ok := t.byID[id]
+	if !ok {
+		return Track{}, false
+	}
+	return tr.clone(), true
+}
+
+// detected returns a copy of the tracks that were not missed on the latest frame.
+func (t *Tracker) detected() []Track {
+	out := make([]Track, 0, len(t.tracks))
+	for _, tr := range t.tracks {
+		if tr.Misses == 0 {
+			out = append(out, tr.clone())
+		}
+	}
+	return out
+}
+
+// observe adds the detection of index j of the frame at timestamp to the track.
+func (t *Tracker) observe(tr *Track, j int, d Detection, timestamp time.Time) {
+	tr.Box, tr.Score = d.Box, d.Score
+	tr.Hits++
+	tr.Misses = 0
+	tr.Detection = j
+	tr.LastSeen = timestamp
+	t.appendHistory(tr, Observation{Box: d.Box, Time: timestamp})
+	if tr.Hits >= t.config.MinHits {
+		tr.Stable = true
+	}
+}
+
+// miss counts a frame the track was not detected on, and returns whether the track is kept. Only
+// stable tracks are kept while they are missed, for up to MaxMisses frames.
+func (t *Tracker) miss(tr *Track) bool {
+	tr.Misses++
+	tr.Detection = -1
+	if tr.Stable && tr.Misses <= t.config.MaxMisses {
+		return true
+	}
+	delete(t.byID, tr.ID)
+	return false
+}
+
+// backfill fills the history of a missed track that was detected again at box with a virtual
+// trajectory, moving at constant velocity from its last observation. The motion of the track is
+// then estimated from the virtual trajectory instead of from the stale box seen before it was
+// missed, which would make the next prediction overshoot.
+func (t *Tracker) backfill(tr *Track, box image.Rectangle, timestamp time.Time) {
+	last := tr.History[len(tr.History)-1]
+	for k := 1; k <= tr.Misses; k++ {
+		f := float64(k) / float64(tr.Misses+1)
+		obs := Observation{Box: interpolateBox(last.Box, box, f), Virtual: true}
+		if !last.Time.IsZero() && !timestamp.IsZero() {
+			obs.Time = last.Time.Add(time.Duration(f * float64(timestamp.Sub(last.Time))))
+		}
+		t.appendHistory(tr, obs)
+	}
+}
+
+// appendHistory adds an observation to the track, and keeps at most MaxHistory of them.
+func (t *Tracker) appendHistory(tr *Track, obs Observation) {
+	if len(tr.History) >= t.config.MaxHistory {
+		// reuse the backing array once it is full
+		n := copy(tr.History, tr.History[len(tr.History)-t.config.MaxHistory+1:])
+		tr.History = tr.History[:n]
+	}
+	tr.History = append(tr.History, obs)
+}
+
+// classID returns the ID of the next track of the class.
+func (t *Tracker) classID(class string) string {
+	n := t.classCounter[class]
+	t.classCounter[class] = n + 1
+	return class + "_" + strconv.Itoa(n)
+}
+
+// interpolateBox returns the box a fraction f of the way from a to b.
+func interpolateBox(a, b image.Rectangle, f float64) image.Rectangle {
+	lerp := func(x, y int) int {
+		return int(math.Round(float64(x) + f*float64(y-x)))
+	}
+	return image.Rect(lerp(a.Min.X, b.Min.X), lerp(a.Min.Y, b.Min.Y), lerp(a.Max.X, b.Max.X), lerp(a.Max.Y, b.Max.Y))
+}
@@ -0,0 +1,257 @@
+package tracking
+
+import (
+	"image"
+	"math"
+	"testing"
+	"time"
+
+	"go.viam.com/test"
+)
+
+// walker returns the detection of a person walking right by 5 pixels a frame.
+func walker(frame int) Detection {
+	return Detection{Box: image.Rect(10+5*frame, 20, 40+5*frame, 80), Class: "person", Score: 0.9}
+}
+
+func TestTrackerUpdate(t *testing.T) {
+	tracker, err := New(Config{MinHits: 3, MaxMisses: 2, MaxHistory: 5})
+	test.That(t, err, test.ShouldBeNil)
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(frame int) time.Time { return start.Add(time.Duration(frame) * 100 * time.Millisecond) }
+
+	for frame := range 6 {
+		tracks := tracker.Update([]Detection{walker(frame)}, at(frame))
+		test.That(t, len(tracks), test.ShouldEqual, 1)
+		test.That(t, tracks[0].ID, test.ShouldEqual, "person_0")
+		test.That(t, tracks[0].Box, test.ShouldResemble, walker(frame).Box)
+		test.That(t, tracks[0].Stable, test.ShouldEqual, frame >= 2)
+		test.That(t, tracks[0].Hits, test.ShouldEqual, frame+1)
+		test.That(t, len(tracks[0].History), test.ShouldEqual, min(frame+1, 5))
+		test.That(t, tracks[0].FirstSeen, test.ShouldEqual, start)
+	}
+
+	// a stable track is kept while it is missed, and found again where it was heading
+	for frame := 6; frame < 8; frame++ {
+		test.That(t, tracker.Update(nil, at(frame)), test.ShouldBeEmpty)
+		test.That(t, len(tracker.Tracks()), test.ShouldEqual, 1)
+	}
+	tracks := tracker.Update([]Detection{walker(8)}, at(8))
+	test.That(t, len(tracks), test.ShouldEqual, 1)
+	test.That(t, tracks[0].ID, test.ShouldEqual, "person_0")
+
+	// until it is missed for too long
+	for frame := 9; frame < 12; frame++ {
+		tracker.Update(nil, at(frame))
+	}
+	test.That(t, tracker.Tracks(), test.ShouldBeEmpty)
+	tracks = tracker.Update([]Detection{walker(12)}, at(12))
+	test.That(t, tracks[0].ID, test.ShouldEqual, "person_1")
+	test.That(t, tracks[0].Stable, test.ShouldBeFalse)
+
+	// tracks that are not stable are dropped as soon as they are missed
+	tracker.Update(nil, at(13))
+	test.That(t, tracker.Tracks(), test.ShouldBeEmpty)
+}
+
+func TestTrackerReturnsCopies(t *testing.T) {
+	tracker, err := New(DefaultConfig())
+	test.That(t, err, test.ShouldBeNil)
+	tracks := tracker.Update([]Detection{walker(0)}, time.Time{})
+	tracks[0].History[0].Box = image.Rectangle{}
+	tracks[0].ID = "changed"
+	test.That(t, tracker.Tracks()[0].ID, test.ShouldEqual, "person_0")
+	test.That(t, tracker.Tracks()[0].History[0].Box, test.ShouldResemble, walker(0).Box)
+}
+
+func TestConfigValidate(t *testing.T) {
+	test.That(t, DefaultConfig().Validate(), test.ShouldBeNil)
+	_, err := New(Config{MinHits: 0, MaxHistory: 2})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = New(Config{MinHits: 1, MaxHistory: 1})
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = New(Config{MinHits: 1, MaxMisses: -1, MaxHistory: 2})
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestConstantVelocityModel(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tr := &Track{
+		Box: image.Rect(20, 0, 30, 10),
+		History: []Observation{
+			{Box: image.Rect(10, 0, 20, 10), Time: start},
+			{Box: image.Rect(20, 0, 30, 10), Time: start.Add(time.Second)},
+		},
+	}
+	m := ConstantVelocityModel{}
+	// 10 pixels a second
+	test.That(t, m.Predict(tr, start.Add(3*time.Second)), test.ShouldResemble, image.Rect(40, 0, 50, 10))
+	// 10 pixels a frame without timestamps
+	test.That(t, m.Predict(tr, time.Time{}), test.ShouldResemble, image.Rect(30, 0, 40, 10))
+	tr.Misses = 1
+	test.That(t, m.Predict(tr, time.Time{}), test.ShouldResemble, image.Rect(40, 0, 50, 10))
+
+	test.That(t, StaticModel{}.Predict(tr, time.Time{}), test.ShouldResemble, tr.Box)
+}
+
+// distanceCost matches the detections within 50 pixels of the predicted center, whether they
+// overlap or not.
+type distanceCost struct{}
+
+func (distanceCost) Cost(tr *Track, predicted image.Rectangle, det Detection) float64 {
+	p, d := center(predicted), center(det.Box)
+	return math.Hypot(float64(p.X-d.X), float64(p.Y-d.Y)) - 50
+}
+
+func TestPluggableTracker(t *testing.T) {
+	// a small, fast object that never overlaps itself from one frame to the next
+	fast := func(frame int) Detection {
+		return Detection{Box: image.Rect(30*frame, 0, 30*frame+10, 10), Class: "ball", Score: 0.8}
+	}
+
+	tracker, err := New(DefaultConfig())
+	test.That(t, err, test.ShouldBeNil)
+	tracker.Update([]Detection{fast(0)}, time.Time{})
+	tracks := tracker.Update([]Detection{fast(1)}, time.Time{})
+	// the default IOU cost cannot match boxes that do not overlap
+	test.That(t, tracks[0].ID, test.ShouldEqual, "ball_1")
+
+	tracker, err = New(DefaultConfig(),
+		WithCostFunction(distanceCost{}),
+		WithAssociator(DenseAssociator{}),
+		WithMotionModel(StaticModel{}),
+	)
+	test.That(t, err, test.ShouldBeNil)
+	for frame := range 4 {
+		tracks = tracker.Update([]Detection{fast(frame)}, time.Time{})
+		test.That(t, tracks[0].ID, test.ShouldEqual, "ball_0")
+	}
+	test.That(t, tracks[0].Stable, test.ShouldBeTrue)
+}
+
+func TestIOUCostSameClass(t *testing.T) {
+	tr := &Track{Class: "dog"}
+	box := image.Rect(0, 0, 10, 10)
+	test.That(t, IOUCost{}.Cost(tr, box, Detection{Box: box, Class: "cat"}), test.ShouldEqual, -1)
+	test.That(t, IOUCost{SameClass: true}.Cost(tr, box, Detection{Box: box, Class: "cat"}), test.ShouldEqual, 0)
+}
+
+func TestTrackerDetectionIndex(t *testing.T) {
+	tracker, err := New(DefaultConfig())
+	test.That(t, err, test.ShouldBeNil)
+	other := Detection{Box: image.Rect(200, 200, 240, 260), Class: "person", Score: 0.7}
+	tracker.Update([]Detection{walker(0), other}, time.Time{})
+	tracks := tracker.Update([]Detection{other, walker(1)}, time.Time{})
+	test.That(t, len(tracks), test.ShouldEqual, 2)
+	test.That(t, tracks[0].ID, test.ShouldEqual, "person_0")
+	test.That(t, tracks[0].Detection, test.ShouldEqual, 1)
+	test.That(t, tracks[1].ID, test.ShouldEqual, "person_1")
+	test.That(t, tracks[1].Detection, test.ShouldEqual, 0)
+}
+
+func TestTrackerBackfill(t *testing.T) {
+	tracker, err := New(Config{MinHits: 1, MaxMisses: 5, MaxHistory: 10})
+	test.That(t, err, test.ShouldBeNil)
+	tracker.Update([]Detection{walker(0)}, time.Time{})
+	tracker.Update([]Detection{walker(1)}, time.Time{})
+	tracker.Update(nil, time.Time{})
+	tracker.Update(nil, time.Time{})
+	tracks := tracker.Update([]Detection{walker(4)}, time.Time{})
+	test.That(t, tracks[0].ID, test.ShouldEqual, "person_0")
+	test.That(t, tracks[0].Misses, test.ShouldEqual, 0)
+
+	// the frames the track was missed on are interpolated, so its velocity is not overestimated
+	history := tracks[0].History
+	test.That(t, len(history), test.ShouldEqual, 5)
+	for frame, obs := range history {
+		test.That(t, obs.Box, test.ShouldResemble, walker(frame).Box)
+		test.That(t, obs.Virtual, test.ShouldEqual, frame == 2 || frame == 3)
+	}
+}
+
+func TestTrackerTruncatedDetections(t *testing.T) {
+	tracker, err := New(Config{MinHits: 1, MaxMisses: 5, MaxHistory: 10})
+	test.That(t, err, test.ShouldBeNil)
+	tracker.Update([]Detection{walker(0)}, time.Time{})
+	truncated := walker(1)
+	truncated.Truncated = true
+	// a truncated box extends a track seen on the previous frame
+	tracks := tracker.Update([]Detection{truncated}, time.Time{})
+	test.That(t, tracks[0].ID, test.ShouldEqual, "person_0")
+
+	// but cannot bring back a missed track
+	tracker.Update(nil, time.Time{})
+	truncated = walker(3)
+	truncated.Truncated = true
+	tracks = tracker.Update([]Detection{truncated}, time.Time{})
+	test.That(t, tracks[0].ID, test.ShouldEqual, "person_1")
+	missed, ok := tracker.Track("person_0")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, missed.Misses, test.ShouldEqual, 2)
+}
+
+func TestTrackerPropagate(t *testing.T) {
+	tracker, err := New(Config{MinHits: 1, MaxMisses: 1, MaxHistory: 10})
+	test.That(t, err, test.ShouldBeNil)
+	tracker.Update([]Detection{walker(0), {Box: image.Rect(200, 200, 240, 260), Class: "dog"}}, time.Time{})
+	tracker.Update([]Detection{walker(1)}, time.Time{})
+
+	// the detected track is moved without being hit, and the missed one is missed again
+	tracks := tracker.Propagate(map[string]image.Rectangle{"person_0": walker(2).Box}, time.Time{})
+	test.That(t, len(tracks), test.ShouldEqual, 1)
+	test.That(t, tracks[0].Box, test.ShouldResemble, walker(2).Box)
+	test.That(t, tracks[0].Hits, test.ShouldEqual, 2)
+	test.That(t, tracks[0].Detection, test.ShouldEqual, -1)
+	test.That(t, tracks[0].History[2].Virtual, test.ShouldBeTrue)
+	_, ok := tracker.Track("dog_0")
+	test.That(t, ok, test.ShouldBeFalse)
+
+	tracks = tracker.Update([]Detection{walker(3)}, time.Time{})
+	test.That(t, tracks[0].ID, test.ShouldEqual, "person_0")
+	test.That(t, tracks[0].Hits, test.ShouldEqual, 3)
+}
+
+func TestTrackerWarp(t *testing.T) {
+	tracker, err := New(DefaultConfig())
+	test.That(t, err, test.ShouldBeNil)
+	tracker.Update([]Detection{walker(0)}, time.Time{})
+	tracker.Update([]Detection{walker(1)}, time.Time{})
+
+	// the camera panned right by 100 pixels, so the walker is 100 pixels further left
+	pan := image.Pt(-100, 0)
+	tracker.Warp(func(r image.Rectangle) image.Rectangle { return r.Add(pan) })
+	tr := tracker.Tracks()[0]
+	test.That(t, tr.Box, test.ShouldResemble, walker(1).Box.Add(pan))
+	test.That(t, tr.History[0].Box, test.ShouldResemble, walker(0).Box.Add(pan))
+	tracks := tracker.Update([]Detection{{Box: walker(2).Box.Add(pan), Class: "person"}}, time.Time{})
+	test.That(t, tracks[0].ID, test.ShouldEqual, "person_0")
+}
+
+func TestTrackerIDFunc(t *testing.T) {
+	n := 0
+	tracker, err := New(DefaultConfig(), WithIDFunc(func(class string) string {
+		n++
+		return class + "-" + string(rune('a'+n-1))
+	}))
+	test.That(t, err, test.ShouldBeNil)
+	tracks := tracker.Update([]Detection{walker(0), {Box: image.Rect(200, 200, 240, 260), Class: "dog"}}, time.Time{})
+	test.That(t, tracks[0].ID, test.ShouldEqual, "person-a")
+	test.That(t, tracks[1].ID, test.ShouldEqual, "dog-b")
+	tr, ok := tracker.Track("dog-b")
+	test.That(t, ok, test.ShouldBeTrue)
+	test.That(t, tr.Class, test.ShouldEqual, "dog")
+}
+
+func TestTrackerReconfigure(t *testing.T) {
+	tracker, err := New(Config{MinHits: 5, MaxMisses: 2, MaxHistory: 5})
+	test.That(t, err, test.ShouldBeNil)
+	tracker.Update([]Detection{walker(0)}, time.Time{})
+	tracker.Update([]Detection{walker(1)}, time.Time{})
+
+	test.That(t, tracker.Reconfigure(Config{MinHits: 0, MaxHistory: 5}), test.ShouldNotBeNil)
+	// the tracks are kept, and judged with the new settings
+	test.That(t, tracker.Reconfigure(Config{MinHits: 3, MaxMisses: 2, MaxHistory: 5}), test.ShouldBeNil)
+	tracks := tracker.Update([]Detection{walker(2)}, time.Time{})
+	test.That(t, tracks[0].ID, test.ShouldEqual, "person_0")
+	test.That(t, tracks[0].Stable, test.ShouldBeTrue)
+}